@@ -10,7 +10,6 @@ import (
     "errors"
     "fmt"
     "io"
-    "log"
 )
 
 const IOBUFLEN = 1024
@@ -104,16 +103,14 @@ func (b *Reader) Read(p []byte) (n int, e error) {
         return 0, nil
     }
 
-    if b.w == b.r {
-        // read request is larger then current window size
-        if n >= len(b.buf[b.w:IOBUFLEN]) {
-            //log.Println("Read directly from IO")
+    if b.Reset() {
+        // read request is larger then the window of a single fill
+        if n >= IOBUFLEN {
             n, e = b.rd.Read(p)
             b.reads++
             return n, e
         }
 
-        log.Println("End of buffer")
         if e = b.fill(); e != nil {
             return 0, e
         }
@@ -1,7 +1,9 @@
 package bufin
 
 import (
+    "bytes"
     "io"
+    "strconv"
     "testing"
 )
 
@@ -117,3 +119,67 @@ func TestReadSlice(t *testing.T) {
         }
     }
 }
+
+func TestReadAfterLongLine(t *testing.T) {
+    // the line ends at the end of the buffered data, beyond IOBUFLEN
+    line := bytes.Repeat([]byte("x"), 3*IOBUFLEN-1)
+    line = append(line, '\n')
+    p := &IOReader{append(line, "data"...), 0}
+    r := NewReader(p)
+
+    if l, err := r.ReadSlice('\n'); err != nil || len(l) != len(line) {
+        t.Fatalf("expected a line of %d bytes got %d, %v", len(line), len(l), err)
+    }
+
+    data := make([]byte, 4)
+
+    if n, err := io.ReadFull(r, data); n != 4 || err != nil || string(data) != "data" {
+        t.Errorf("expected `data` got `%s`, %v", data[:n], err)
+    }
+}
+
+// loopReader returns data over and over again.
+type loopReader struct {
+    data []byte
+    off  int
+}
+
+func (l *loopReader) Read(p []byte) (int, error) {
+    n := copy(p, l.data[l.off:])
+    l.off = (l.off + n) % len(l.data)
+    return n, nil
+}
+
+func BenchmarkReadSlice(b *testing.B) {
+    for _, size := range []int{16, 256, 4096} {
+        b.Run(strconv.Itoa(size), func(b *testing.B) {
+            line := append(bytes.Repeat([]byte("x"), size-1), '\n')
+            r := NewReader(&loopReader{data: line})
+            b.SetBytes(int64(size))
+            b.ReportAllocs()
+
+            for i := 0; i < b.N; i++ {
+                if _, err := r.ReadSlice('\n'); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
+
+func BenchmarkRead(b *testing.B) {
+    for _, size := range []int{16, 1024, 64 << 10} {
+        b.Run(strconv.Itoa(size), func(b *testing.B) {
+            r := NewReader(&loopReader{data: bytes.Repeat([]byte("x"), size*3+1)})
+            p := make([]byte, size)
+            b.SetBytes(int64(size))
+            b.ReportAllocs()
+
+            for i := 0; i < b.N; i++ {
+                if _, err := io.ReadFull(r, p); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
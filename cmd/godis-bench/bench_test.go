@@ -0,0 +1,154 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "insmo.com/godis/redistest"
+)
+
+func TestHistogram(t *testing.T) {
+    h := new(histogram)
+
+    for v := int64(1); v <= 10000; v++ {
+        h.record(v)
+    }
+
+    tests := []struct {
+        q        float64
+        expected int64
+    }{
+        {0, 1},
+        {0.5, 5000},
+        {0.99, 9900},
+        {1, 10000},
+    }
+
+    for _, test := range tests {
+        v := h.quantile(test.q)
+
+        if v < test.expected || float64(v) > float64(test.expected)*1.01 {
+            t.Errorf("q%v: expected %d got %d", test.q, test.expected, v)
+        }
+    }
+
+    if h.min != 1 || h.max != 10000 || h.mean() != 5000.5 {
+        t.Errorf("expected min 1, max 10000 and mean 5000.5 got %d, %d and %v", h.min, h.max, h.mean())
+    }
+
+    for i := 0; i < 5000; i++ {
+        if lowest(bucket(int64(i))) > int64(i) || lowest(bucket(int64(i))+1) <= int64(i) {
+            t.Fatalf("%d: not in bucket %d", i, bucket(int64(i)))
+        }
+    }
+
+    o := new(histogram)
+    o.record(1 << 40)
+    h.merge(o)
+    h.merge(new(histogram))
+
+    if h.total != 10001 || h.max != 1<<40 || h.quantile(1) != 1<<40 {
+        t.Errorf("expected the merged value got total %d max %d", h.total, h.max)
+    }
+}
+
+func TestParse(t *testing.T) {
+    ops, e := parseMix("get=80, SET=20,ping=0,incr")
+
+    if e != nil || len(ops) != 3 || ops[0] != (op{"get", 80}) || ops[1] != (op{"set", 20}) || ops[2] != (op{"incr", 1}) {
+        t.Errorf("expected get, set and incr got %v, %v", ops, e)
+    }
+
+    for _, bad := range []string{"", "foo=1", "get=x", "get=-1", "get=0"} {
+        if _, e := parseMix(bad); e == nil {
+            t.Errorf("mix %q: expected error", bad)
+        }
+    }
+
+    sizes := map[string]dist{
+        "64":      {kind: "fixed", min: 64, max: 64},
+        "16-1024": {kind: "uniform", min: 16, max: 1024},
+        "exp:100": {kind: "exp", max: 2000, param: 100},
+    }
+
+    for s, expected := range sizes {
+        if d, e := parseSize(s); e != nil || d != expected {
+            t.Errorf("size %q: expected %+v got %+v, %v", s, expected, d, e)
+        }
+    }
+
+    for _, bad := range []string{"", "x", "10-5", "exp:0"} {
+        if _, e := parseSize(bad); e == nil {
+            t.Errorf("size %q: expected error", bad)
+        }
+    }
+
+    if d, e := parseKeys("zipf:1.5", 100); e != nil || d.kind != "zipf" || d.max != 99 || d.param != 1.5 {
+        t.Errorf("expected zipf got %+v, %v", d, e)
+    }
+
+    for _, bad := range []string{"zipf:1", "normal"} {
+        if _, e := parseKeys(bad, 100); e == nil {
+            t.Errorf("keys %q: expected error", bad)
+        }
+    }
+}
+
+func TestGenerator(t *testing.T) {
+    ops, _ := parseMix("set=1,mget=1")
+    keys, _ := parseKeys("zipf", 10)
+    sizes, _ := parseSize("exp:8")
+    w := newWorkload(ops, keys, sizes, "b:")
+    g := w.generator(1)
+
+    for i := 0; i < 1000; i++ {
+        name, args := g.next()
+
+        if k := args[1].(string); !strings.HasPrefix(k, "b:string:") {
+            t.Fatalf("%s: unexpected key %q", name, k)
+        }
+
+        if name == "set" && len(args[2].([]byte)) > sizes.max {
+            t.Fatalf("value larger than %d", sizes.max)
+        }
+
+        if name == "mget" && len(args) != 11 {
+            t.Fatalf("expected 10 keys got %d", len(args)-1)
+        }
+    }
+}
+
+func TestRun(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    ops, _ := parseMix("get=1,set=1,incr=1,rpush=1,hset=1,zadd=1")
+    keys, _ := parseKeys("uniform", 100)
+    sizes, _ := parseSize("1-32")
+
+    for _, depth := range []int{1, 8} {
+        cfg := &config{
+            Addr:     s.NetAddr(),
+            Mock:     true,
+            Requests: 800,
+            Clients:  4,
+            Depth:    depth,
+            seed:     1,
+            workload: newWorkload(ops, keys, sizes, "bench:"),
+        }
+
+        rep := run(cfg)
+
+        if rep.Requests < 800 || rep.Errors != 0 || len(rep.Commands) != 6 {
+            t.Errorf("P%d: expected 800 requests in 6 commands got %d in %d, %d errors", depth, rep.Requests, len(rep.Commands), rep.Errors)
+        }
+
+        var out bytes.Buffer
+        rep.print(&out)
+
+        if !strings.Contains(out.String(), "total") {
+            t.Errorf("expected a total line got\n%s", out.String())
+        }
+    }
+}
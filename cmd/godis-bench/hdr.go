@@ -0,0 +1,135 @@
+package main
+
+import (
+    "math"
+    "math/bits"
+)
+
+// subBits sets the precision of the histogram: every power of two is split
+// into 1<<subBits linear buckets, so recorded values are off by less than
+// 1%.
+const (
+    subBits  = 7
+    subCount = 1 << subBits
+)
+
+// histogram is a high dynamic range histogram of int64 values, such as
+// latencies in nanoseconds. Its size only depends on the largest value,
+// not on the number of values.
+type histogram struct {
+    counts   []int64
+    total    int64
+    min, max int64
+    sum      float64
+}
+
+// bucket returns the index of the bucket of v.
+func bucket(v int64) int {
+    if v < subCount {
+        return int(v)
+    }
+
+    shift := bits.Len64(uint64(v)) - subBits - 1
+    return (shift+1)*subCount + int(v>>uint(shift)) - subCount
+}
+
+// lowest returns the smallest value of bucket i.
+func lowest(i int) int64 {
+    if i < subCount {
+        return int64(i)
+    }
+
+    shift := i/subCount - 1
+    return int64(i%subCount+subCount) << uint(shift)
+}
+
+func (h *histogram) record(v int64) {
+    if v < 0 {
+        v = 0
+    }
+
+    i := bucket(v)
+
+    if i >= len(h.counts) {
+        counts := make([]int64, i+1)
+        copy(counts, h.counts)
+        h.counts = counts
+    }
+
+    if h.total == 0 || v < h.min {
+        h.min = v
+    }
+
+    if v > h.max {
+        h.max = v
+    }
+
+    h.counts[i]++
+    h.total++
+    h.sum += float64(v)
+}
+
+func (h *histogram) merge(o *histogram) {
+    if o.total == 0 {
+        return
+    }
+
+    if len(o.counts) > len(h.counts) {
+        counts := make([]int64, len(o.counts))
+        copy(counts, h.counts)
+        h.counts = counts
+    }
+
+    for i, n := range o.counts {
+        h.counts[i] += n
+    }
+
+    if h.total == 0 || o.min < h.min {
+        h.min = o.min
+    }
+
+    if o.max > h.max {
+        h.max = o.max
+    }
+
+    h.total += o.total
+    h.sum += o.sum
+}
+
+// quantile returns the highest value equivalent to the value at quantile
+// q, e.g. 0.99 for the 99th percentile.
+func (h *histogram) quantile(q float64) int64 {
+    if h.total == 0 {
+        return 0
+    }
+
+    target := int64(math.Ceil(q * float64(h.total)))
+
+    if target < 1 {
+        target = 1
+    }
+
+    var n int64
+
+    for i, c := range h.counts {
+        if n += c; n >= target {
+            v := lowest(i+1) - 1
+
+            if v > h.max {
+                v = h.max
+            }
+
+            return v
+        }
+    }
+
+    return h.max
+}
+
+func (h *histogram) mean() float64 {
+    if h.total == 0 {
+        return 0
+    }
+
+    return h.sum / float64(h.total)
+}
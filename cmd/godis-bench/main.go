@@ -0,0 +1,373 @@
+// Command godis-bench measures the throughput and latency of the exp client
+// against Redis or the in-process redistest server.
+//
+//      godis-bench -mix get=80,set=20 -keys 100000 -size 16-1024 -c 50 -P 10
+//
+// Workers send the commands of the mix with keys and value sizes drawn from
+// the configured distributions. With a pipeline depth above 1 every worker
+// queues that many commands and sends them with one Pipeline.Exec, and
+// every command of the pipeline is recorded with the latency of the whole
+// pipeline.
+//
+// Latencies are recorded in HDR histograms and reported per command as
+// mean, p50, p99, p999 and max in milliseconds, as text or with -json as a
+// JSON document.
+//
+// With -mock the benchmark runs against a redistest server in the same
+// process, which measures the client without a real server. All keys are
+// created below -prefix, existing keys are not removed.
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "runtime/pprof"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "insmo.com/godis/exp"
+    "insmo.com/godis/redistest"
+)
+
+var (
+    addr     = flag.String("addr", "tcp:127.0.0.1:6379", "address of the server")
+    db       = flag.Int("db", 0, "database")
+    password = flag.String("password", "", "password")
+    mock     = flag.Bool("mock", false, "run against an in-process redistest server")
+    mix      = flag.String("mix", "get=50,set=50", "command mix as command=weight pairs")
+    keys     = flag.Int("keys", 10000, "number of keys of each type")
+    keyDist  = flag.String("keydist", "uniform", "key distribution: uniform, zipf or zipf:EXPONENT")
+    size     = flag.String("size", "64", "value size in bytes: N, MIN-MAX or exp:MEAN")
+    prefix   = flag.String("prefix", "bench:", "key prefix")
+    requests = flag.Int("n", 100000, "number of requests, unless -d is set")
+    duration = flag.Duration("d", 0, "run for a duration instead of a number of requests")
+    clients  = flag.Int("c", 50, "number of concurrent workers")
+    depth    = flag.Int("P", 1, "pipeline depth")
+    seed     = flag.Int64("seed", 1, "random seed")
+    jsonOut  = flag.Bool("json", false, "print the report as JSON")
+    cpuprof  = flag.String("cpuprof", "", "write a CPU profile to this file")
+)
+
+func main() {
+    flag.Parse()
+    cfg, e := configure()
+
+    if e != nil {
+        fmt.Fprintln(os.Stderr, "godis-bench:", e)
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    if *cpuprof != "" {
+        f, e := os.Create(*cpuprof)
+
+        if e != nil {
+            fmt.Fprintln(os.Stderr, "godis-bench:", e)
+            os.Exit(1)
+        }
+
+        pprof.StartCPUProfile(f)
+        defer pprof.StopCPUProfile()
+    }
+
+    if *mock {
+        s := redistest.NewServer()
+        defer s.Close()
+        cfg.Addr = s.NetAddr()
+    }
+
+    rep := run(cfg)
+
+    if *jsonOut {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        enc.Encode(rep)
+    } else {
+        rep.print(os.Stdout)
+    }
+}
+
+// config holds the settings of a run.
+type config struct {
+    Addr     string        `json:"addr"`
+    Mock     bool          `json:"mock"`
+    Mix      string        `json:"mix"`
+    Keys     int           `json:"keys"`
+    KeyDist  string        `json:"keydist"`
+    Size     string        `json:"size"`
+    Requests int           `json:"requests,omitempty"`
+    Duration time.Duration `json:"duration,omitempty"`
+    Clients  int           `json:"clients"`
+    Depth    int           `json:"depth"`
+
+    db       int
+    password string
+    seed     int64
+    workload *workload
+}
+
+func configure() (*config, error) {
+    ops, e := parseMix(*mix)
+
+    if e != nil {
+        return nil, e
+    }
+
+    kd, e := parseKeys(*keyDist, *keys)
+
+    if e != nil {
+        return nil, e
+    }
+
+    sd, e := parseSize(*size)
+
+    if e != nil {
+        return nil, e
+    }
+
+    if *clients < 1 || *depth < 1 {
+        return nil, fmt.Errorf("-c and -P must be at least 1")
+    }
+
+    cfg := &config{
+        Addr:     *addr,
+        Mock:     *mock,
+        Mix:      *mix,
+        Keys:     *keys,
+        KeyDist:  *keyDist,
+        Size:     *size,
+        Clients:  *clients,
+        Depth:    *depth,
+        db:       *db,
+        password: *password,
+        seed:     *seed,
+        workload: newWorkload(ops, kd, sd, *prefix),
+    }
+
+    if *duration > 0 {
+        cfg.Duration = *duration
+    } else {
+        cfg.Requests = *requests
+    }
+
+    return cfg, nil
+}
+
+// worker holds the counters of one go routine, merged after the run.
+type worker struct {
+    hists  map[string]*histogram
+    errors map[string]int64
+}
+
+func (w *worker) record(name string, d time.Duration, err error) {
+    h := w.hists[name]
+
+    if h == nil {
+        h = new(histogram)
+        w.hists[name] = h
+    }
+
+    h.record(int64(d))
+
+    if err != nil {
+        w.errors[name]++
+    }
+}
+
+// run starts the workers and waits until the requests are sent or the
+// duration has passed.
+func run(cfg *config) *report {
+    redis.MaxConnections = cfg.Clients
+    c := redis.NewClient(cfg.Addr, cfg.db, cfg.password)
+
+    // requests left, in batches of the pipeline depth
+    left := int64(cfg.Requests)
+    var stop int32
+
+    if cfg.Duration > 0 {
+        time.AfterFunc(cfg.Duration, func() { atomic.StoreInt32(&stop, 1) })
+    }
+
+    more := func() bool {
+        if cfg.Duration > 0 {
+            return atomic.LoadInt32(&stop) == 0
+        }
+
+        return atomic.AddInt64(&left, -int64(cfg.Depth)) > -int64(cfg.Depth)
+    }
+
+    workers := make([]*worker, cfg.Clients)
+    var wg sync.WaitGroup
+    start := time.Now()
+
+    for i := range workers {
+        w := &worker{make(map[string]*histogram), make(map[string]int64)}
+        workers[i] = w
+        g := cfg.workload.generator(cfg.seed + int64(i))
+        wg.Add(1)
+
+        go func() {
+            defer wg.Done()
+
+            if cfg.Depth == 1 {
+                for more() {
+                    name, args := g.next()
+                    t := time.Now()
+                    _, e := c.Call(args...)
+                    w.record(name, time.Since(t), e)
+                }
+
+                return
+            }
+
+            p := c.Pipeline()
+            names := make([]string, cfg.Depth)
+            futures := make([]*redis.Future, cfg.Depth)
+
+            for more() {
+                for j := range futures {
+                    var args []interface{}
+                    names[j], args = g.next()
+                    futures[j] = p.Queue(args...)
+                }
+
+                t := time.Now()
+                p.Exec()
+                d := time.Since(t)
+
+                for j, f := range futures {
+                    _, e := f.Reply()
+                    w.record(names[j], d, e)
+                }
+            }
+        }()
+    }
+
+    wg.Wait()
+    return newReport(cfg, workers, time.Since(start))
+}
+
+// report is the result of a run.
+type report struct {
+    Config    *config   `json:"config"`
+    Elapsed   float64   `json:"elapsed_sec"`
+    Requests  int64     `json:"requests"`
+    Errors    int64     `json:"errors"`
+    OpsPerSec float64   `json:"ops_per_sec"`
+    Latency   latency   `json:"latency"`
+    Commands  []command `json:"commands"`
+}
+
+// command holds the results of one command of the mix.
+type command struct {
+    Name      string  `json:"name"`
+    Requests  int64   `json:"requests"`
+    Errors    int64   `json:"errors"`
+    OpsPerSec float64 `json:"ops_per_sec"`
+    Latency   latency `json:"latency"`
+}
+
+// latency is reported in milliseconds.
+type latency struct {
+    Mean float64 `json:"mean_ms"`
+    P50  float64 `json:"p50_ms"`
+    P99  float64 `json:"p99_ms"`
+    P999 float64 `json:"p999_ms"`
+    Max  float64 `json:"max_ms"`
+}
+
+func newLatency(h *histogram) latency {
+    ms := func(v float64) float64 {
+        return v / float64(time.Millisecond)
+    }
+
+    return latency{
+        Mean: ms(h.mean()),
+        P50:  ms(float64(h.quantile(0.5))),
+        P99:  ms(float64(h.quantile(0.99))),
+        P999: ms(float64(h.quantile(0.999))),
+        Max:  ms(float64(h.max)),
+    }
+}
+
+func newReport(cfg *config, workers []*worker, elapsed time.Duration) *report {
+    all := new(histogram)
+    hists := make(map[string]*histogram)
+    errors := make(map[string]int64)
+
+    for _, w := range workers {
+        for name, h := range w.hists {
+            if hists[name] == nil {
+                hists[name] = new(histogram)
+            }
+
+            hists[name].merge(h)
+            all.merge(h)
+        }
+
+        for name, n := range w.errors {
+            errors[name] += n
+        }
+    }
+
+    rep := &report{
+        Config:    cfg,
+        Elapsed:   elapsed.Seconds(),
+        Requests:  all.total,
+        OpsPerSec: float64(all.total) / elapsed.Seconds(),
+        Latency:   newLatency(all),
+    }
+
+    names := make([]string, 0, len(hists))
+
+    for name := range hists {
+        names = append(names, name)
+    }
+
+    sort.Strings(names)
+
+    for _, name := range names {
+        h := hists[name]
+        rep.Errors += errors[name]
+        rep.Commands = append(rep.Commands, command{
+            Name:      name,
+            Requests:  h.total,
+            Errors:    errors[name],
+            OpsPerSec: float64(h.total) / elapsed.Seconds(),
+            Latency:   newLatency(h),
+        })
+    }
+
+    return rep
+}
+
+func (rep *report) print(w io.Writer) {
+    cfg := rep.Config
+    target := cfg.Addr
+
+    if cfg.Mock {
+        target = "in-process mock"
+    }
+
+    fmt.Fprintf(w, "%s, %d clients, pipeline %d, %d %s keys, value size %s\n\n",
+        target, cfg.Clients, cfg.Depth, cfg.Keys, cfg.KeyDist, cfg.Size)
+    fmt.Fprintf(w, "%-8s %10s %8s %12s %9s %9s %9s %9s %9s\n",
+        "command", "requests", "errors", "ops/sec", "mean", "p50", "p99", "p999", "max")
+
+    line := func(name string, requests, errors int64, ops float64, l latency) {
+        fmt.Fprintf(w, "%-8s %10d %8d %12.0f %9.3f %9.3f %9.3f %9.3f %9.3f\n",
+            name, requests, errors, ops, l.Mean, l.P50, l.P99, l.P999, l.Max)
+    }
+
+    for _, c := range rep.Commands {
+        line(c.Name, c.Requests, c.Errors, c.OpsPerSec, c.Latency)
+    }
+
+    line("total", rep.Requests, rep.Errors, rep.OpsPerSec, rep.Latency)
+    fmt.Fprintf(w, "\nlatencies in ms, %.2fs elapsed\n", rep.Elapsed)
+}
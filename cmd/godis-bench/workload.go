@@ -0,0 +1,270 @@
+package main
+
+import (
+    "errors"
+    "math/rand"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// commands build the arguments of the benchmarked commands. Every command
+// uses keys of its own type, so any mix runs without WRONGTYPE errors.
+var commands = map[string]func(g *generator) []interface{}{
+    "ping": func(g *generator) []interface{} {
+        return []interface{}{"PING"}
+    },
+    "set": func(g *generator) []interface{} {
+        return []interface{}{"SET", g.key("string"), g.value()}
+    },
+    "get": func(g *generator) []interface{} {
+        return []interface{}{"GET", g.key("string")}
+    },
+    "mget": func(g *generator) []interface{} {
+        args := []interface{}{"MGET"}
+
+        for i := 0; i < 10; i++ {
+            args = append(args, g.key("string"))
+        }
+
+        return args
+    },
+    "incr": func(g *generator) []interface{} {
+        return []interface{}{"INCR", g.key("counter")}
+    },
+    "rpush": func(g *generator) []interface{} {
+        return []interface{}{"RPUSH", g.key("list"), g.value()}
+    },
+    "lpop": func(g *generator) []interface{} {
+        return []interface{}{"LPOP", g.key("list")}
+    },
+    "hset": func(g *generator) []interface{} {
+        return []interface{}{"HSET", g.key("hash"), g.field(), g.value()}
+    },
+    "hget": func(g *generator) []interface{} {
+        return []interface{}{"HGET", g.key("hash"), g.field()}
+    },
+    "sadd": func(g *generator) []interface{} {
+        return []interface{}{"SADD", g.key("set"), g.field()}
+    },
+    "zadd": func(g *generator) []interface{} {
+        return []interface{}{"ZADD", g.key("zset"), g.r.Intn(1000), g.field()}
+    },
+}
+
+// commandNames returns the names of the benchmarked commands, sorted.
+func commandNames() []string {
+    names := make([]string, 0, len(commands))
+
+    for name := range commands {
+        names = append(names, name)
+    }
+
+    sort.Strings(names)
+    return names
+}
+
+// op is a command of the mix and its weight.
+type op struct {
+    name   string
+    weight int
+}
+
+// parseMix parses a command mix such as "get=80,set=20". A command without
+// a weight has a weight of 1.
+func parseMix(s string) ([]op, error) {
+    var ops []op
+
+    for _, part := range strings.Split(s, ",") {
+        if part = strings.TrimSpace(part); part == "" {
+            continue
+        }
+
+        kv := strings.SplitN(part, "=", 2)
+        name := strings.ToLower(kv[0])
+        weight := 1
+
+        if _, ok := commands[name]; !ok {
+            return nil, errors.New("unknown command " + strconv.Quote(name) + ", use one of " + strings.Join(commandNames(), ", "))
+        }
+
+        if len(kv) == 2 {
+            w, e := strconv.Atoi(kv[1])
+
+            if e != nil || w < 0 {
+                return nil, errors.New("invalid weight " + strconv.Quote(kv[1]))
+            }
+
+            weight = w
+        }
+
+        if weight > 0 {
+            ops = append(ops, op{name, weight})
+        }
+    }
+
+    if len(ops) == 0 {
+        return nil, errors.New("empty command mix")
+    }
+
+    return ops, nil
+}
+
+// dist describes a distribution of integers.
+type dist struct {
+    kind     string
+    min, max int
+    param    float64
+}
+
+// parseSize parses a value size distribution: "N" for a fixed size,
+// "MIN-MAX" for a uniform size between MIN and MAX and "exp:MEAN" for an
+// exponential distribution with the given mean.
+func parseSize(s string) (dist, error) {
+    if strings.HasPrefix(s, "exp:") {
+        mean, e := strconv.Atoi(s[4:])
+
+        if e != nil || mean < 1 {
+            return dist{}, errors.New("invalid mean in " + strconv.Quote(s))
+        }
+
+        return dist{kind: "exp", min: 0, max: mean * 20, param: float64(mean)}, nil
+    }
+
+    parts := strings.SplitN(s, "-", 2)
+    min, e := strconv.Atoi(parts[0])
+
+    if e != nil || min < 0 {
+        return dist{}, errors.New("invalid size " + strconv.Quote(s))
+    }
+
+    if len(parts) == 1 {
+        return dist{kind: "fixed", min: min, max: min}, nil
+    }
+
+    max, e := strconv.Atoi(parts[1])
+
+    if e != nil || max < min {
+        return dist{}, errors.New("invalid size " + strconv.Quote(s))
+    }
+
+    return dist{kind: "uniform", min: min, max: max}, nil
+}
+
+// parseKeys parses the key distribution over a key space of n keys:
+// "uniform" or "zipf" with an optional exponent, e.g. "zipf:1.2".
+func parseKeys(s string, n int) (dist, error) {
+    if n < 1 {
+        return dist{}, errors.New("the key space needs at least one key")
+    }
+
+    switch {
+    case s == "uniform":
+        return dist{kind: "uniform", min: 0, max: n - 1}, nil
+    case s == "zipf":
+        return dist{kind: "zipf", min: 0, max: n - 1, param: 1.1}, nil
+    case strings.HasPrefix(s, "zipf:"):
+        v, e := strconv.ParseFloat(s[5:], 64)
+
+        if e != nil || v <= 1 {
+            return dist{}, errors.New("the zipf exponent must be > 1")
+        }
+
+        return dist{kind: "zipf", min: 0, max: n - 1, param: v}, nil
+    }
+
+    return dist{}, errors.New("unknown key distribution " + strconv.Quote(s))
+}
+
+// workload holds the parsed benchmark settings shared by all workers.
+type workload struct {
+    ops    []op
+    total  int
+    keys   dist
+    sizes  dist
+    prefix string
+
+    // values are slices of data, which is never modified
+    data []byte
+}
+
+func newWorkload(ops []op, keys, sizes dist, prefix string) *workload {
+    w := &workload{ops: ops, keys: keys, sizes: sizes, prefix: prefix}
+
+    for _, o := range ops {
+        w.total += o.weight
+    }
+
+    w.data = make([]byte, sizes.max)
+    r := rand.New(rand.NewSource(1))
+
+    for i := range w.data {
+        w.data[i] = byte('a' + r.Intn(26))
+    }
+
+    return w
+}
+
+// generator creates commands for one worker.
+type generator struct {
+    w    *workload
+    r    *rand.Rand
+    zipf *rand.Zipf
+}
+
+func (w *workload) generator(seed int64) *generator {
+    g := &generator{w: w, r: rand.New(rand.NewSource(seed))}
+
+    if w.keys.kind == "zipf" {
+        g.zipf = rand.NewZipf(g.r, w.keys.param, 1, uint64(w.keys.max))
+    }
+
+    return g
+}
+
+// next returns the name and arguments of the next command.
+func (g *generator) next() (string, []interface{}) {
+    n := g.r.Intn(g.w.total)
+
+    for _, o := range g.w.ops {
+        if n -= o.weight; n < 0 {
+            return o.name, commands[o.name](g)
+        }
+    }
+
+    panic("unreachable")
+}
+
+func (g *generator) key(typ string) string {
+    var k int
+
+    if g.zipf != nil {
+        k = int(g.zipf.Uint64())
+    } else {
+        k = g.r.Intn(g.w.keys.max + 1)
+    }
+
+    return g.w.prefix + typ + ":" + strconv.Itoa(k)
+}
+
+func (g *generator) field() string {
+    return "f" + strconv.Itoa(g.r.Intn(100))
+}
+
+func (g *generator) value() []byte {
+    s := g.w.sizes
+    n := s.min
+
+    switch s.kind {
+    case "uniform":
+        n = s.min + g.r.Intn(s.max-s.min+1)
+    case "exp":
+        n = int(g.r.ExpFloat64() * s.param)
+
+        if n > s.max {
+            n = s.max
+        }
+    }
+
+    return g.w.data[:n]
+}
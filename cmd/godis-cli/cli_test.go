@@ -0,0 +1,210 @@
+package main
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "insmo.com/godis/bufin"
+    "insmo.com/godis/exp"
+    "insmo.com/godis/redistest"
+)
+
+func TestParseURL(t *testing.T) {
+    tests := []struct {
+        url string
+        cfg config
+    }{
+        {"redis://127.0.0.1", config{"tcp", "127.0.0.1:6379", 0, ""}},
+        {"redis://:secret@db.local:6380/3", config{"tcp", "db.local:6380", 3, "secret"}},
+        {"redis://[::1]:6379?db=2", config{"tcp", "[::1]:6379", 2, ""}},
+        {"unix:///tmp/redis.sock?db=1&password=pw", config{"unix", "/tmp/redis.sock", 1, "pw"}},
+        {"tcp:127.0.0.1:6379", config{"tcp", "127.0.0.1:6379", 0, ""}},
+    }
+
+    for _, test := range tests {
+        cfg, e := parseURL(test.url)
+
+        if e != nil || *cfg != test.cfg {
+            t.Errorf("%s: expected %+v got %+v, %v", test.url, test.cfg, cfg, e)
+        }
+    }
+
+    for _, bad := range []string{"http://host", "redis://host/x", "nocolon"} {
+        if _, e := parseURL(bad); e == nil {
+            t.Errorf("%s: expected error", bad)
+        }
+    }
+}
+
+func TestSplitArgs(t *testing.T) {
+    tests := []struct {
+        line string
+        args []string
+    }{
+        {"  SET foo bar ", []string{"SET", "foo", "bar"}},
+        {`SET "hello world" 'it''s'`, nil},
+        {`SET "a\"b\n\x41" 'x y'`, []string{"SET", "a\"b\nA", "x y"}},
+        {`GET "foo`, nil},
+        {"", nil},
+    }
+
+    for _, test := range tests {
+        args, _ := splitArgs(test.line)
+
+        if !reflect.DeepEqual(args, test.args) {
+            t.Errorf("%s: expected %q got %q", test.line, test.args, args)
+        }
+    }
+}
+
+func parseReply(raw string) *redis.Reply {
+    return redis.Parse(bufin.NewReader(strings.NewReader(raw)))
+}
+
+func TestPrint(t *testing.T) {
+    tests := []struct {
+        raw, human string
+    }{
+        {"+OK\r\n", "OK"},
+        {"-ERR bad\r\n", "(error) ERR bad"},
+        {":12\r\n", "(integer) 12"},
+        {"$3\r\nfoo\r\n", `"foo"`},
+        {"$-1\r\n", "(nil)"},
+        {"*0\r\n", "(empty array)"},
+        {"*2\r\n*2\r\n$1\r\na\r\n:1\r\n$1\r\nb\r\n", "1) 1) \"a\"\n   2) (integer) 1\n2) \"b\""},
+        {"%1\r\n$1\r\nk\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n", "1# \"k\" => 1) \"a\"\n          2) \"b\""},
+        {"#t\r\n", "(true)"},
+        {",1.5\r\n", "(double) 1.5"},
+        {"_\r\n", "(nil)"},
+    }
+
+    for _, test := range tests {
+        var human, resp bytes.Buffer
+        r := parseReply(test.raw)
+        (&printer{&human, modeHuman}).print(r)
+        (&printer{&resp, modeResp}).print(r)
+
+        if out := strings.TrimSuffix(human.String(), "\n"); out != test.human {
+            t.Errorf("%q: expected\n%s\ngot\n%s", test.raw, test.human, out)
+        }
+
+        if resp.String() != test.raw {
+            t.Errorf("%q: expected the same RESP got %q", test.raw, resp.String())
+        }
+    }
+
+    var raw bytes.Buffer
+    (&printer{&raw, modeRaw}).print(parseReply("*2\r\n$1\r\na\r\n:1\r\n"))
+
+    if raw.String() != "a\n1\n" {
+        t.Errorf("expected raw values got %q", raw.String())
+    }
+}
+
+func TestPipe(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    cfg, _ := parseURL(s.NetAddr())
+
+    for _, in := range []string{
+        "SET a 1\nINCR a\n\nLPUSH a x\n",
+        "*2\r\n$4\r\nINCR\r\n$1\r\nb\r\n*3\r\n$3\r\nSET\r\n$1\r\nc\r\n$1\r\nd\r\n",
+    } {
+        var out bytes.Buffer
+
+        if e := runPipe(cfg, strings.NewReader(in), &out); e != nil {
+            t.Fatal(e.Error())
+        }
+
+        if !strings.HasPrefix(out.String(), "All data transferred.") {
+            t.Errorf("expected summary got %q", out.String())
+        }
+    }
+
+    var out bytes.Buffer
+    runScan(cfg, "*", 1, &printer{&out, modeHuman})
+    keys := strings.Fields(out.String())
+
+    if len(keys) != 3 {
+        t.Errorf("expected keys a, b and c got %q", keys)
+    }
+}
+
+func TestRepl(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    cfg, _ := parseURL(s.NetAddr())
+    dir, _ := ioutil.TempDir("", "godis-cli")
+    defer os.RemoveAll(dir)
+
+    path := filepath.Join(dir, "history")
+    in := "SET foo bar\nSELECT 1\nGET foo\n!1\nhistory\nquit\n"
+    var out bytes.Buffer
+
+    if e := runRepl(cfg, strings.NewReader(in), &printer{&out, modeHuman}, path); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    prompt := cfg.prompt(0)
+    expected := prompt + "OK\n" +
+        prompt + "OK\n" +
+        cfg.prompt(1) + "(nil)\n" +
+        cfg.prompt(1) + "SET foo bar\nOK\n" +
+        cfg.prompt(1) + "    1  SET foo bar\n    2  SELECT 1\n    3  GET foo\n    4  SET foo bar\n    5  history\n" +
+        cfg.prompt(1)
+
+    if out.String() != expected {
+        t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+    }
+
+    if h := loadHistory(path); len(h.lines) != 6 || h.lines[5] != "quit" {
+        t.Errorf("expected 6 history lines got %q", h.lines)
+    }
+}
+
+func TestReplSelect(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+    m := redistest.NewMock(s)
+    defer m.Close()
+
+    // SELECT 2 fails after the reconnect forced by the reset GET
+    m.On("SELECT", "2").Times(1).Pass()
+    m.On("SELECT", "2").Error("ERR DB index is out of range")
+    m.On("GET").Times(1).Reset()
+
+    cfg, _ := parseURL(m.NetAddr())
+    dir, _ := ioutil.TempDir("", "godis-cli")
+    defer os.RemoveAll(dir)
+
+    in := "SELECT 99\nSELECT 2\nGET foo\nGET foo\nquit\n"
+    var out bytes.Buffer
+
+    if e := runRepl(cfg, strings.NewReader(in), &printer{&out, modeHuman}, filepath.Join(dir, "history")); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    lines := strings.Split(out.String(), "\n")
+
+    if len(lines) != 6 {
+        t.Fatalf("expected 6 lines got %q", lines)
+    }
+
+    // the failed SELECT keeps the db, the failed re-SELECT falls back to db 0
+    for i, db := range map[int]int{0: 0, 1: 0, 2: 2, 4: 0, 5: 0} {
+        if !strings.HasPrefix(lines[i], cfg.prompt(db)) {
+            t.Errorf("line %d: expected prompt %q got %q", i, cfg.prompt(db), lines[i])
+        }
+    }
+
+    if !strings.Contains(lines[3], "using db 0") {
+        t.Errorf("expected the failed SELECT after reconnecting got %q", lines[3])
+    }
+}
@@ -0,0 +1,161 @@
+// Command godis-cli is a command line client for Redis built on the exp
+// package.
+//
+// Without a command it starts an interactive prompt:
+//
+//      godis-cli -u redis://:secret@127.0.0.1:6379/2
+//      127.0.0.1:6379[2]> HGETALL user:1
+//      1) "name"
+//      2) "simon"
+//
+// A command given as arguments is run once and its reply printed. The
+// connection is read from -u, or the GODIS_URL environment variable, and is
+// either a redis:// or unix:// URL or a godis address such as
+// "tcp:127.0.0.1:6379".
+//
+// Other modes are
+//
+//      godis-cli -pipe < commands.txt     mass insertion from stdin
+//      godis-cli -scan -pattern 'user:*'  list keys with SCAN
+//      godis-cli -latency                 measure PING round trips
+//
+// Replies are printed like redis-cli does, with -raw only the values are
+// printed and with -resp the replies are printed in the Redis protocol.
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "time"
+
+    "insmo.com/godis/exp"
+)
+
+var (
+    addr     = flag.String("u", defaultURL(), "redis://, unix:// URL or godis address of the server")
+    raw      = flag.Bool("raw", false, "print reply values only")
+    resp     = flag.Bool("resp", false, "print replies in the Redis protocol")
+    pipe     = flag.Bool("pipe", false, "send the commands read from stdin, one per line or in the Redis protocol")
+    window   = flag.Int("window", redis.DefaultBulkWindow, "maximum in-flight commands in -pipe mode")
+    scan     = flag.Bool("scan", false, "list all keys using SCAN")
+    pattern  = flag.String("pattern", "*", "key pattern in -scan mode")
+    count    = flag.Int("count", 100, "SCAN COUNT hint in -scan mode")
+    latency  = flag.Bool("latency", false, "measure the latency of PING")
+    interval = flag.Duration("i", time.Second, "interval between latency reports")
+    samples  = flag.Int("samples", 0, "stop -latency after this many samples, 0 runs until interrupted")
+    histFile = flag.String("history", historyFile(), "history file of the prompt, empty to disable")
+)
+
+func defaultURL() string {
+    if u := os.Getenv("GODIS_URL"); u != "" {
+        return u
+    }
+
+    return "redis://127.0.0.1:6379"
+}
+
+func historyFile() string {
+    home, e := os.UserHomeDir()
+
+    if e != nil {
+        return ""
+    }
+
+    return filepath.Join(home, ".godis_history")
+}
+
+func main() {
+    flag.Parse()
+    cfg, e := parseURL(*addr)
+
+    if e != nil {
+        fatal(e)
+    }
+
+    p := newPrinter(os.Stdout)
+
+    switch {
+    case *pipe:
+        e = runPipe(cfg, os.Stdin, os.Stdout)
+    case *scan:
+        e = runScan(cfg, *pattern, *count, p)
+    case *latency:
+        e = runLatency(cfg, *interval, *samples, os.Stdout)
+    case flag.NArg() > 0:
+        e = runCommand(cfg, flag.Args(), p)
+    default:
+        e = runRepl(cfg, os.Stdin, p, *histFile)
+    }
+
+    if e != nil {
+        fatal(e)
+    }
+}
+
+func fatal(e error) {
+    fmt.Fprintln(os.Stderr, "godis-cli:", e)
+    os.Exit(1)
+}
+
+// newPrinter returns the printer selected by the flags.
+func newPrinter(w io.Writer) *printer {
+    mode := modeHuman
+
+    switch {
+    case *resp:
+        mode = modeResp
+    case *raw:
+        mode = modeRaw
+    }
+
+    return &printer{w, mode}
+}
+
+// runCommand runs a single command and prints its reply.
+func runCommand(cfg *config, args []string, p *printer) error {
+    conn, e := cfg.dial()
+
+    if e != nil {
+        return e
+    }
+
+    defer conn.Close()
+    _, e = call(conn, args, p)
+    return e
+}
+
+// call sends a command, prints its replies and returns the last one. An
+// error reply is printed and returned as a reply. Subscribing commands and
+// MONITOR keep printing until the connection is closed.
+func call(conn *redis.Conn, args []string, p *printer) (*redis.Reply, error) {
+    if e := conn.Write(toArgs(args)...); e != nil {
+        return nil, e
+    }
+
+    for {
+        r := conn.ReadReply()
+
+        if r.Type() == "" {
+            return nil, r.Err
+        }
+
+        p.print(r)
+
+        if !streaming(args[0]) {
+            return r, nil
+        }
+    }
+}
+
+func toArgs(args []string) []interface{} {
+    v := make([]interface{}, len(args))
+
+    for i, arg := range args {
+        v[i] = arg
+    }
+
+    return v
+}
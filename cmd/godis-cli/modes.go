@@ -0,0 +1,195 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "os/signal"
+    "time"
+
+    "insmo.com/godis/bufin"
+    "insmo.com/godis/exp"
+)
+
+// runPipe sends the commands read from in with a BulkWriter. Input which
+// starts with '*' is read as the Redis protocol, as written for
+// redis-cli --pipe, other input as one command per line.
+func runPipe(cfg *config, in io.Reader, out io.Writer) error {
+    w, e := cfg.client().BulkWriter(redis.BulkConfig{
+        Window: *window,
+        OnError: func(e *redis.BulkError) {
+            fmt.Fprintln(os.Stderr, e)
+        },
+    })
+
+    if e != nil {
+        return e
+    }
+
+    br := bufio.NewReaderSize(in, 64*1024)
+    first, _ := br.Peek(1)
+
+    if len(first) == 1 && first[0] == '*' {
+        e = pipeResp(w, br)
+    } else {
+        e = pipeLines(w, br)
+    }
+
+    st, ce := w.Close()
+
+    if e == nil {
+        e = ce
+    }
+
+    fmt.Fprintf(out, "All data transferred. commands: %d, replies: %d, errors: %d, %.0f replies/sec\n",
+        st.Commands, st.Replies, st.Errors, st.Rate())
+    return e
+}
+
+func pipeResp(w *redis.BulkWriter, in io.Reader) error {
+    buf := bufin.NewReader(in)
+
+    for {
+        r := redis.Parse(buf)
+
+        if r.Type() == "" {
+            if r.Err == io.EOF {
+                return nil
+            }
+
+            return r.Err
+        }
+
+        if e := w.WriteArgs(r.BytesArray()); e != nil {
+            return e
+        }
+    }
+}
+
+func pipeLines(w *redis.BulkWriter, in io.Reader) error {
+    s := bufio.NewScanner(in)
+    s.Buffer(make([]byte, 64*1024), 512*1024*1024)
+
+    for n := 1; s.Scan(); n++ {
+        args, e := splitArgs(s.Text())
+
+        if e != nil {
+            return fmt.Errorf("line %d: %s", n, e)
+        }
+
+        if len(args) == 0 {
+            continue
+        }
+
+        if e = w.Write(toArgs(args)...); e != nil {
+            return e
+        }
+    }
+
+    return s.Err()
+}
+
+// runScan prints all keys matching pattern.
+func runScan(cfg *config, pattern string, count int, p *printer) error {
+    c := cfg.client()
+    cursor := "0"
+
+    for {
+        r, e := c.Call("SCAN", cursor, "MATCH", pattern, "COUNT", count)
+
+        if e != nil {
+            return e
+        }
+
+        if r.Len() != 2 {
+            return fmt.Errorf("unexpected SCAN reply with %d elements", r.Len())
+        }
+
+        for _, key := range r.Elems[1].StringArray() {
+            fmt.Fprintln(p.w, key)
+        }
+
+        if cursor = r.Elems[0].Elem.String(); cursor == "0" {
+            return nil
+        }
+    }
+}
+
+// runLatency sends PING until interrupted or n samples are taken and
+// reports the minimum, maximum and average round trip in milliseconds.
+func runLatency(cfg *config, interval time.Duration, n int, out io.Writer) error {
+    conn, e := cfg.dial()
+
+    if e != nil {
+        return e
+    }
+
+    defer conn.Close()
+
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, os.Interrupt)
+    defer signal.Stop(stop)
+
+    var l latencyStats
+    report := time.NewTicker(interval)
+    defer report.Stop()
+
+    for n == 0 || l.count < n {
+        start := time.Now()
+
+        if e := conn.Write("PING"); e != nil {
+            return e
+        }
+
+        if _, e := conn.Read(); e != nil {
+            return e
+        }
+
+        l.add(time.Since(start))
+
+        select {
+        case <-stop:
+            fmt.Fprintf(out, "\r%s\n", l)
+            return nil
+        case <-report.C:
+            fmt.Fprintf(out, "\r%s", l)
+        case <-time.After(10 * time.Millisecond):
+        }
+    }
+
+    fmt.Fprintf(out, "\r%s\n", l)
+    return nil
+}
+
+type latencyStats struct {
+    count         int
+    min, max, sum time.Duration
+}
+
+func (l *latencyStats) add(d time.Duration) {
+    if l.count == 0 || d < l.min {
+        l.min = d
+    }
+
+    if d > l.max {
+        l.max = d
+    }
+
+    l.sum += d
+    l.count++
+}
+
+func (l latencyStats) String() string {
+    ms := func(d time.Duration) float64 {
+        return float64(d) / float64(time.Millisecond)
+    }
+
+    avg := time.Duration(0)
+
+    if l.count > 0 {
+        avg = l.sum / time.Duration(l.count)
+    }
+
+    return fmt.Sprintf("min: %.3f, max: %.3f, avg: %.3f (%d samples)", ms(l.min), ms(l.max), ms(avg), l.count)
+}
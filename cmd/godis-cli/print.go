@@ -0,0 +1,204 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "insmo.com/godis/exp"
+)
+
+const (
+    modeHuman = iota
+    modeRaw
+    modeResp
+)
+
+// printer writes replies in one of the output modes.
+type printer struct {
+    w    io.Writer
+    mode int
+}
+
+func (p *printer) print(r *redis.Reply) {
+    switch p.mode {
+    case modeRaw:
+        io.WriteString(p.w, strings.Join(rawLines(r), "\n")+"\n")
+    case modeResp:
+        writeResp(p.w, r)
+    default:
+        io.WriteString(p.w, strings.Join(humanLines(r), "\n")+"\n")
+    }
+}
+
+// humanLines formats a reply like redis-cli, with the type of the reply and
+// nested multi-bulk replies indented below their index.
+func humanLines(r *redis.Reply) []string {
+    switch r.Type() {
+    case "status":
+        return []string{r.Elem.String()}
+    case "error", "blob-error":
+        return []string{"(error) " + r.Err.Error()}
+    case "integer":
+        return []string{"(integer) " + r.Elem.String()}
+    case "double":
+        return []string{"(double) " + r.Elem.String()}
+    case "bignum":
+        return []string{"(big number) " + r.Elem.String()}
+    case "boolean":
+        if r.Elem.String() == "t" {
+            return []string{"(true)"}
+        }
+
+        return []string{"(false)"}
+    case "null":
+        return []string{"(nil)"}
+    case "array", "set", "push":
+        if r.Err != nil && r.Elems == nil {
+            return []string{"(nil)"}
+        }
+
+        return elemLines(r.Elems, ")")
+    case "map":
+        return mapLines(r.Elems)
+    }
+
+    // bulk and verbatim strings
+    if r.Elem == nil {
+        return []string{"(nil)"}
+    }
+
+    return []string{strconv.Quote(r.Elem.String())}
+}
+
+func elemLines(elems []*redis.Reply, sep string) []string {
+    if len(elems) == 0 {
+        return []string{"(empty array)"}
+    }
+
+    var lines []string
+    width := len(strconv.Itoa(len(elems)))
+
+    for i, e := range elems {
+        prefix := fmt.Sprintf("%*d%s ", width, i+1, sep)
+        lines = append(lines, indent(prefix, humanLines(e))...)
+    }
+
+    return lines
+}
+
+func mapLines(elems []*redis.Reply) []string {
+    if len(elems) == 0 {
+        return []string{"(empty hash)"}
+    }
+
+    var lines []string
+    width := len(strconv.Itoa(len(elems) / 2))
+
+    for i := 0; i+1 < len(elems); i += 2 {
+        prefix := fmt.Sprintf("%*d# ", width, i/2+1)
+        key := humanLines(elems[i])
+        key[len(key)-1] += " => "
+        value := humanLines(elems[i+1])
+        last := key[len(key)-1]
+        entry := append(key[:len(key)-1], indent(last, value)...)
+        lines = append(lines, indent(prefix, entry)...)
+    }
+
+    return lines
+}
+
+// indent prefixes the first line and aligns the following lines with it.
+func indent(prefix string, lines []string) []string {
+    pad := strings.Repeat(" ", len(prefix))
+    out := make([]string, len(lines))
+
+    for i, line := range lines {
+        if i == 0 {
+            out[i] = prefix + line
+        } else {
+            out[i] = pad + line
+        }
+    }
+
+    return out
+}
+
+// rawLines prints values without types or quotes, one per line.
+func rawLines(r *redis.Reply) []string {
+    switch {
+    case r.Elems != nil:
+        var lines []string
+
+        for _, e := range r.Elems {
+            lines = append(lines, rawLines(e)...)
+        }
+
+        return lines
+    case r.Err != nil:
+        return []string{r.Err.Error()}
+    }
+
+    return []string{r.Elem.String()}
+}
+
+// respTypes are the type bytes of the reply types.
+var respTypes = map[string]byte{
+    "status":     '+',
+    "error":      '-',
+    "integer":    ':',
+    "bulk":       '$',
+    "array":      '*',
+    "null":       '_',
+    "map":        '%',
+    "set":        '~',
+    "push":       '>',
+    "boolean":    '#',
+    "double":     ',',
+    "bignum":     '(',
+    "verbatim":   '=',
+    "blob-error": '!',
+}
+
+// writeResp writes a reply in the Redis protocol.
+func writeResp(w io.Writer, r *redis.Reply) {
+    typ := respTypes[r.Type()]
+
+    switch typ {
+    case '+', ':', ',', '(', '#':
+        fmt.Fprintf(w, "%c%s\r\n", typ, r.Elem)
+    case '-':
+        fmt.Fprintf(w, "-%s\r\n", r.Err)
+    case '!':
+        fmt.Fprintf(w, "!%d\r\n%s\r\n", len(r.Err.Error()), r.Err)
+    case '_':
+        io.WriteString(w, "_\r\n")
+    case '*', '~', '>', '%':
+        if r.Err != nil && r.Elems == nil {
+            io.WriteString(w, "*-1\r\n")
+            return
+        }
+
+        n := len(r.Elems)
+
+        if typ == '%' {
+            n /= 2
+        }
+
+        fmt.Fprintf(w, "%c%d\r\n", typ, n)
+
+        for _, e := range r.Elems {
+            writeResp(w, e)
+        }
+    case '=':
+        // the format prefix is stripped by the parser
+        fmt.Fprintf(w, "=%d\r\ntxt:%s\r\n", len(r.Elem)+4, r.Elem)
+    default:
+        if r.Elem == nil {
+            io.WriteString(w, "$-1\r\n")
+        } else {
+            fmt.Fprintf(w, "$%d\r\n%s\r\n", len(r.Elem), r.Elem)
+        }
+    }
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "strconv"
+    "strings"
+
+    "insmo.com/godis/exp"
+)
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+// runRepl reads commands from in until EOF or quit. The lines are kept in
+// the history file, "history" lists them and "!n" runs line n again.
+func runRepl(cfg *config, in io.Reader, p *printer, historyPath string) error {
+    conn, e := cfg.dial()
+
+    if e != nil {
+        return e
+    }
+
+    defer func() { conn.Close() }()
+
+    h := loadHistory(historyPath)
+    db := cfg.Db
+    s := bufio.NewScanner(in)
+    s.Buffer(make([]byte, 64*1024), 512*1024*1024)
+
+    for {
+        io.WriteString(p.w, cfg.prompt(db))
+
+        if !s.Scan() {
+            io.WriteString(p.w, "\n")
+            return s.Err()
+        }
+
+        line := strings.TrimSpace(s.Text())
+
+        if strings.HasPrefix(line, "!") {
+            n, e := strconv.Atoi(line[1:])
+
+            if e != nil || n < 1 || n > len(h.lines) {
+                fmt.Fprintf(p.w, "(error) no history line %s\n", line[1:])
+                continue
+            }
+
+            line = h.lines[n-1]
+            fmt.Fprintln(p.w, line)
+        }
+
+        args, e := splitArgs(line)
+
+        if e != nil {
+            fmt.Fprintf(p.w, "(error) %s\n", e)
+            continue
+        }
+
+        if len(args) == 0 {
+            continue
+        }
+
+        h.add(line)
+
+        switch strings.ToLower(args[0]) {
+        case "quit", "exit":
+            return nil
+        case "history":
+            for i, l := range h.lines {
+                fmt.Fprintf(p.w, "%5d  %s\n", i+1, l)
+            }
+
+            continue
+        }
+
+        r, e := call(conn, args, p)
+
+        if e != nil {
+            // reconnect once, the server may have closed an idle connection
+            fmt.Fprintf(p.w, "(error) %s, reconnecting\n", e)
+            conn.Close()
+
+            if conn, e = cfg.dial(); e != nil {
+                return e
+            }
+
+            // the new connection uses the database of the URL
+            if e = selectDb(conn, db); e != nil {
+                fmt.Fprintf(p.w, "(error) %s, using db %d\n", e, cfg.Db)
+                db = cfg.Db
+            }
+
+            continue
+        }
+
+        if strings.EqualFold(args[0], "select") && len(args) == 2 && r.Err == nil {
+            if n, e := strconv.Atoi(args[1]); e == nil {
+                db = n
+            }
+        }
+    }
+}
+
+// selectDb selects db on a new connection which was dialed with the
+// database of the URL.
+func selectDb(conn *redis.Conn, db int) error {
+    if e := conn.Write("SELECT", db); e != nil {
+        return e
+    }
+
+    _, e := conn.Read()
+    return e
+}
+
+// streaming reports whether a command keeps sending replies.
+func streaming(name string) bool {
+    switch strings.ToUpper(name) {
+    case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE", "MONITOR":
+        return true
+    }
+
+    return false
+}
+
+// history is the list of lines entered at the prompt. New lines are
+// appended to the history file right away.
+type history struct {
+    path  string
+    lines []string
+}
+
+func loadHistory(path string) *history {
+    h := &history{path: path}
+
+    if path == "" {
+        return h
+    }
+
+    if b, e := ioutil.ReadFile(path); e == nil {
+        for _, line := range strings.Split(string(b), "\n") {
+            if line != "" {
+                h.lines = append(h.lines, line)
+            }
+        }
+    }
+
+    if len(h.lines) > maxHistory {
+        h.lines = h.lines[len(h.lines)-maxHistory:]
+        ioutil.WriteFile(path, []byte(strings.Join(h.lines, "\n")+"\n"), 0600)
+    }
+
+    return h
+}
+
+func (h *history) add(line string) {
+    if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+        return
+    }
+
+    h.lines = append(h.lines, line)
+
+    if h.path == "" {
+        return
+    }
+
+    f, e := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+    if e != nil {
+        return
+    }
+
+    fmt.Fprintln(f, line)
+    f.Close()
+}
+
+// splitArgs splits a line into arguments like redis-cli. Arguments are
+// separated by spaces and may be quoted. Double quoted arguments support
+// the escapes \n, \r, \t, \", \\ and \xHH.
+func splitArgs(line string) ([]string, error) {
+    var args []string
+    i := 0
+
+    for {
+        for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+            i++
+        }
+
+        if i == len(line) {
+            return args, nil
+        }
+
+        var arg []byte
+
+        switch line[i] {
+        case '"':
+            i++
+
+            for ; i < len(line) && line[i] != '"'; i++ {
+                if line[i] != '\\' || i+1 == len(line) {
+                    arg = append(arg, line[i])
+                    continue
+                }
+
+                i++
+
+                switch line[i] {
+                case 'n':
+                    arg = append(arg, '\n')
+                case 'r':
+                    arg = append(arg, '\r')
+                case 't':
+                    arg = append(arg, '\t')
+                case 'x':
+                    if i+2 < len(line) {
+                        if b, e := strconv.ParseUint(line[i+1:i+3], 16, 8); e == nil {
+                            arg = append(arg, byte(b))
+                            i += 2
+                            continue
+                        }
+                    }
+
+                    arg = append(arg, 'x')
+                default:
+                    arg = append(arg, line[i])
+                }
+            }
+
+            if i == len(line) {
+                return nil, errors.New("unbalanced quotes")
+            }
+
+            i++
+        case '\'':
+            j := strings.IndexByte(line[i+1:], '\'')
+
+            if j < 0 {
+                return nil, errors.New("unbalanced quotes")
+            }
+
+            arg = []byte(line[i+1 : i+1+j])
+            i += j + 2
+        default:
+            for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+                arg = append(arg, line[i])
+                i++
+            }
+        }
+
+        if i < len(line) && line[i] != ' ' && line[i] != '\t' {
+            return nil, errors.New("closing quote must be followed by a space")
+        }
+
+        args = append(args, string(arg))
+    }
+}
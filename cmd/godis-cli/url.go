@@ -0,0 +1,107 @@
+package main
+
+import (
+    "errors"
+    "net"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "insmo.com/godis/exp"
+)
+
+// config holds the connection settings parsed from a URL.
+type config struct {
+    Proto    string
+    Addr     string
+    Db       int
+    Password string
+}
+
+// parseURL understands
+//
+//      redis://[:password@]host[:port][/db]
+//      unix:///path/to/redis.sock[?db=N&password=secret]
+//      tcp:127.0.0.1:6379
+//
+// The last form is the address format of the godis clients.
+func parseURL(s string) (*config, error) {
+    if !strings.Contains(s, "://") {
+        na := strings.SplitN(s, ":", 2)
+
+        if len(na) != 2 || na[1] == "" {
+            return nil, errors.New("invalid address " + strconv.Quote(s))
+        }
+
+        return &config{Proto: na[0], Addr: na[1]}, nil
+    }
+
+    u, e := url.Parse(s)
+
+    if e != nil {
+        return nil, e
+    }
+
+    cfg := &config{}
+    q := u.Query()
+
+    if u.User != nil {
+        cfg.Password, _ = u.User.Password()
+    }
+
+    if p := q.Get("password"); p != "" {
+        cfg.Password = p
+    }
+
+    db := q.Get("db")
+
+    switch u.Scheme {
+    case "redis":
+        cfg.Proto = "tcp"
+        host, port := u.Hostname(), u.Port()
+
+        if host == "" {
+            host = "127.0.0.1"
+        }
+
+        if port == "" {
+            port = "6379"
+        }
+
+        cfg.Addr = net.JoinHostPort(host, port)
+
+        if path := strings.Trim(u.Path, "/"); path != "" {
+            db = path
+        }
+    case "unix":
+        cfg.Proto = "unix"
+        cfg.Addr = u.Path
+    default:
+        return nil, errors.New("unsupported scheme " + strconv.Quote(u.Scheme))
+    }
+
+    if db != "" {
+        if cfg.Db, e = strconv.Atoi(db); e != nil {
+            return nil, errors.New("invalid db " + strconv.Quote(db))
+        }
+    }
+
+    return cfg, nil
+}
+
+func (cfg *config) dial() (*redis.Conn, error) {
+    return redis.NewConn(cfg.Addr, cfg.Proto, cfg.Db, cfg.Password)
+}
+
+func (cfg *config) client() *redis.Client {
+    return redis.NewClient(cfg.Proto+":"+cfg.Addr, cfg.Db, cfg.Password)
+}
+
+// prompt returns the REPL prompt, e.g. "127.0.0.1:6379[2]> ".
+func (cfg *config) prompt(db int) string {
+    if db == 0 {
+        return cfg.Addr + "> "
+    }
+
+    return cfg.Addr + "[" + strconv.Itoa(db) + "]> "
+}
@@ -0,0 +1,257 @@
+package redis
+
+import (
+    "bufio"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// DefaultBulkWindow is used when BulkConfig.Window is not set.
+var DefaultBulkWindow = 10000
+
+// BulkConfig configures a BulkWriter.
+type BulkConfig struct {
+    // Window is the maximum number of commands which are written but
+    // whose replies are not read yet. Write blocks when it is reached.
+    Window int
+
+    // OnError is called from the reading go routine for every error
+    // reply. If it is nil the errors are kept and returned by Errors.
+    OnError func(e *BulkError)
+}
+
+// BulkError is the error reply of a command written by a BulkWriter.
+// Index is the position of the command, counting from zero.
+type BulkError struct {
+    Index int64
+    Err   error
+}
+
+func (e *BulkError) Error() string {
+    return "command " + strconv.FormatInt(e.Index, 10) + ": " + e.Err.Error()
+}
+
+// BulkStats holds the counters of a BulkWriter.
+type BulkStats struct {
+    Commands     int64
+    Replies      int64
+    Errors       int64
+    BytesWritten int64
+    Elapsed      time.Duration
+}
+
+// Rate returns the number of replies read per second.
+func (s BulkStats) Rate() float64 {
+    if s.Elapsed <= 0 {
+        return 0
+    }
+
+    return float64(s.Replies) / s.Elapsed.Seconds()
+}
+
+// BulkWriter loads large amounts of data the way redis-cli --pipe does.
+// Commands are streamed to a connection of their own while a go routine
+// reads the replies, so memory use is bounded by the window of in-flight
+// commands instead of growing with the number of commands.
+//
+//      w, e := c.BulkWriter(redis.BulkConfig{})
+//
+//      for _, u := range users {
+//          w.Write("HSET", "user:"+u.Id, "name", u.Name)
+//      }
+//
+//      stats, e := w.Close()
+//
+// The replies are discarded, error replies are reported with the index of
+// their command. Command hooks are not called for bulk writes. A
+// BulkWriter is not safe to share between go routines.
+type BulkWriter struct {
+    conn    *Conn
+    w       *bufio.Writer
+    stats   *clientStats
+    onError func(e *BulkError)
+    start   time.Time
+
+    // slots holds a value for every in-flight command
+    slots chan struct{}
+
+    // done is closed when the reading go routine stops, err is why
+    done    chan struct{}
+    err     error
+    closing int32
+
+    // updated atomically
+    commands int64
+    replies  int64
+    errCount int64
+    bytes    int64
+
+    mu   sync.Mutex
+    errs []*BulkError
+}
+
+// BulkWriter opens a new connection with the settings of c and starts
+// reading its replies.
+func (c *Client) BulkWriter(config BulkConfig) (*BulkWriter, error) {
+    conn, e := c.dial()
+
+    if e != nil {
+        return nil, e
+    }
+
+    if config.Window <= 0 {
+        config.Window = DefaultBulkWindow
+    }
+
+    b := &BulkWriter{
+        conn:    conn,
+        w:       bufio.NewWriterSize(conn.Sock(), 64*1024),
+        stats:   c.stats,
+        onError: config.OnError,
+        start:   time.Now(),
+        slots:   make(chan struct{}, config.Window),
+        done:    make(chan struct{}),
+    }
+
+    go b.read()
+    return b, nil
+}
+
+// Write formats and buffers a command. It blocks while the window is full
+// and returns an error if the connection failed.
+func (b *BulkWriter) Write(args ...interface{}) error {
+    buf := make([][]byte, len(args))
+
+    for i, arg := range args {
+        buf[i] = argBytes(arg)
+    }
+
+    return b.WriteArgs(buf)
+}
+
+// WriteArgs is like Write for arguments which are already bytes.
+func (b *BulkWriter) WriteArgs(args [][]byte) error {
+    select {
+    case b.slots <- struct{}{}:
+    default:
+        // let the server catch up on what is buffered before waiting
+        if e := b.w.Flush(); e != nil {
+            return e
+        }
+
+        select {
+        case b.slots <- struct{}{}:
+        case <-b.done:
+            return b.err
+        }
+    }
+
+    select {
+    case <-b.done:
+        return b.err
+    default:
+    }
+
+    n, e := b.w.Write(formatArgs(args))
+    atomic.AddInt64(&b.bytes, int64(n))
+
+    if e != nil {
+        return e
+    }
+
+    atomic.AddInt64(&b.commands, 1)
+
+    if b.stats != nil {
+        atomic.AddInt64(&b.stats.commands, 1)
+    }
+
+    return nil
+}
+
+// Flush writes the buffered commands to the connection.
+func (b *BulkWriter) Flush() error {
+    return b.w.Flush()
+}
+
+// read reads replies until the connection fails or is closed.
+func (b *BulkWriter) read() {
+    defer close(b.done)
+
+    for i := int64(0); ; i++ {
+        r := b.conn.readReply()
+
+        if r.typ == 0 {
+            if atomic.LoadInt32(&b.closing) == 0 {
+                b.err = r.Err
+            }
+
+            return
+        }
+
+        atomic.AddInt64(&b.replies, 1)
+        <-b.slots
+
+        if r.Err == nil {
+            continue
+        }
+
+        atomic.AddInt64(&b.errCount, 1)
+        e := &BulkError{i, r.Err}
+
+        if b.onError != nil {
+            b.onError(e)
+        } else {
+            b.mu.Lock()
+            b.errs = append(b.errs, e)
+            b.mu.Unlock()
+        }
+    }
+}
+
+// Errors returns the error replies read so far, unless an OnError callback
+// is configured.
+func (b *BulkWriter) Errors() []*BulkError {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return append([]*BulkError(nil), b.errs...)
+}
+
+// Stats returns a snapshot of the counters of the writer.
+func (b *BulkWriter) Stats() BulkStats {
+    return BulkStats{
+        Commands:     atomic.LoadInt64(&b.commands),
+        Replies:      atomic.LoadInt64(&b.replies),
+        Errors:       atomic.LoadInt64(&b.errCount),
+        BytesWritten: atomic.LoadInt64(&b.bytes),
+        Elapsed:      time.Since(b.start),
+    }
+}
+
+// Close flushes the buffered commands, waits for all replies and closes
+// the connection. It returns the final stats and the error which stopped
+// the writer, if any.
+func (b *BulkWriter) Close() (BulkStats, error) {
+    err := b.w.Flush()
+
+    // every slot is free once the last reply is read
+wait:
+    for i := 0; i < cap(b.slots) && err == nil; i++ {
+        select {
+        case b.slots <- struct{}{}:
+        case <-b.done:
+            break wait
+        }
+    }
+
+    atomic.StoreInt32(&b.closing, 1)
+    b.conn.Close()
+    <-b.done
+
+    if err == nil {
+        err = b.err
+    }
+
+    return b.Stats(), err
+}
@@ -0,0 +1,125 @@
+package redis
+
+import (
+    "strconv"
+    "testing"
+    "time"
+
+    "insmo.com/godis/redistest"
+)
+
+func TestBulkWriter(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    c := NewClient(s.NetAddr(), 0, "")
+    w, e := c.BulkWriter(BulkConfig{Window: 16})
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    n := 1000
+
+    for i := 0; i < n; i++ {
+        if e := w.Write("SET", "key:"+strconv.Itoa(i), i); e != nil {
+            t.Fatal(e.Error())
+        }
+    }
+
+    w.Write("RPUSH", "list", "a")
+    w.Write("INCR", "list")
+    w.WriteArgs([][]byte{[]byte("INCR"), []byte("key:1")})
+    st, e := w.Close()
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if st.Commands != int64(n+3) || st.Replies != st.Commands || st.Errors != 1 {
+        t.Errorf("expected %d commands and replies and 1 error got %+v", n+3, st)
+    }
+
+    if st.BytesWritten == 0 || st.Rate() <= 0 {
+        t.Errorf("expected bytes and rate got %+v", st)
+    }
+
+    errs := w.Errors()
+
+    if len(errs) != 1 || errs[0].Index != int64(n+1) {
+        t.Errorf("expected error of command %d got %v", n+1, errs)
+    }
+
+    if r, e := c.Call("GET", "key:1"); e != nil || r.Elem.String() != "2" {
+        t.Errorf("expected 2 got %v, %v", r, e)
+    }
+
+    if r, e := c.Call("DBSIZE"); e != nil || r.Elem.Int() != n+1 {
+        t.Errorf("expected %d keys got %v, %v", n+1, r, e)
+    }
+}
+
+func TestBulkWriterOnError(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    var errs []*BulkError
+    w, e := NewClient(s.NetAddr(), 0, "").BulkWriter(BulkConfig{
+        OnError: func(e *BulkError) { errs = append(errs, e) },
+    })
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    w.Write("SET", "foo", "bar")
+    w.Write("INCR", "foo")
+    w.Write("HGET", "foo", "f")
+
+    if _, e := w.Close(); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if len(errs) != 2 || errs[0].Index != 1 || errs[1].Index != 2 {
+        t.Errorf("expected errors of commands 1 and 2 got %v", errs)
+    }
+
+    if len(w.Errors()) != 0 {
+        t.Errorf("expected errors only passed to OnError")
+    }
+}
+
+func TestBulkWriterWindow(t *testing.T) {
+    m := redistest.NewMock(nil)
+    m.On("BLPOP").Hang()
+
+    w, e := NewClient(m.NetAddr(), 0, "").BulkWriter(BulkConfig{Window: 1})
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    w.Write("BLPOP", "list", 0)
+    written := make(chan error)
+
+    go func() {
+        written <- w.Write("PING")
+    }()
+
+    select {
+    case e := <-written:
+        t.Fatalf("expected Write to block got %v", e)
+    case <-time.After(50 * time.Millisecond):
+    }
+
+    // the reply never comes, the writer fails once the connection does
+    m.Close()
+
+    if e := <-written; e == nil {
+        t.Errorf("expected connection error")
+    }
+
+    if st, e := w.Close(); e == nil || st.Commands != 1 {
+        t.Errorf("expected connection error after 1 command got %+v, %v", st, e)
+    }
+}
@@ -0,0 +1,439 @@
+package redis
+
+import (
+    "container/list"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultCacheSize is used when CacheConfig.Size is not set.
+var DefaultCacheSize = 1024
+
+// CacheConfig configures the local cache of a CachedClient.
+type CacheConfig struct {
+    // Size is the maximum number of cached replies.
+    Size int
+
+    // TTL is the maximum age of a cached reply. Zero means replies are kept
+    // until they are invalidated or evicted.
+    TTL time.Duration
+
+    // Broadcast enables tracking in broadcast mode. Redis then sends
+    // invalidations for every key matching one of Prefixes instead of only
+    // for the keys read by the client. Only keys matching Prefixes are
+    // cached, no prefixes matches all keys.
+    Broadcast bool
+    Prefixes  []string
+}
+
+// CachedClient works like Client but keeps the replies of GET, HGETALL and
+// MGET in a local LRU cache. Redis server-assisted client side caching
+// (CLIENT TRACKING) is used to evict replies when keys are modified.
+//
+// A dedicated RESP3 connection receives the invalidation messages. When it
+// is dropped the whole cache is flushed, since invalidations might have been
+// lost, and the connection is reopened by the next cached command.
+//
+// Cached replies are shared between callers and must not be modified.
+type CachedClient struct {
+    *Client
+    config  CacheConfig
+    tracked *connPool
+    now     func() time.Time
+
+    mu       sync.Mutex
+    lru      *list.List
+    entries  map[string]*cacheEntry
+    byKey    map[string]map[*cacheEntry]bool
+    tracker  Connection
+    redirect int64
+    gen      int
+}
+
+type cacheEntry struct {
+    id      string
+    keys    []string
+    reply   *Reply
+    expires time.Time
+
+    // elem is nil while the reply is read from Redis
+    elem *list.Element
+}
+
+// trackedConn is a pooled connection with tracking redirected to the
+// tracker connection of generation gen.
+type trackedConn struct {
+    Connection
+    gen int
+}
+
+// Use the connection settings from Client to create a new CachedClient.
+func (c *Client) CachedClient(config CacheConfig) *CachedClient {
+    if config.Size < 1 {
+        config.Size = DefaultCacheSize
+    }
+
+    return &CachedClient{
+        Client:  c,
+        config:  config,
+        tracked: newConnPool(),
+        now:     time.Now,
+        lru:     list.New(),
+        entries: make(map[string]*cacheEntry),
+        byKey:   make(map[string]map[*cacheEntry]bool),
+    }
+}
+
+// NewCachedClient expects a addr like "tcp:127.0.0.1:6379"
+// It returns a new *CachedClient.
+func NewCachedClient(addr string, db int, password string, config CacheConfig) *CachedClient {
+    return NewClient(addr, db, password).CachedClient(config)
+}
+
+// Call works like Client.Call. Replies for GET, HGETALL and MGET are served
+// from the cache when possible.
+func (cc *CachedClient) Call(args ...interface{}) (*Reply, error) {
+    keys := cc.cacheable(args)
+
+    if keys == nil {
+        return cc.Client.Call(args...)
+    }
+
+    id := cacheId(args)
+    cc.mu.Lock()
+
+    if reply := cc.lookup(id); reply != nil {
+        cc.mu.Unlock()
+        return reply, nil
+    }
+
+    if cc.tracker == nil {
+        if e := cc.startTracker(); e != nil {
+            cc.mu.Unlock()
+            return nil, e
+        }
+    }
+
+    gen, redirect := cc.gen, cc.redirect
+    entry := cc.claim(id, keys)
+    cc.mu.Unlock()
+
+    reply, e := cc.call(gen, redirect, args)
+    cc.mu.Lock()
+    defer cc.mu.Unlock()
+
+    if entry != nil && cc.entries[id] == entry {
+        if e != nil || gen != cc.gen {
+            cc.remove(entry)
+        } else {
+            cc.store(entry, reply)
+        }
+    }
+
+    return reply, e
+}
+
+// Len returns the number of cached replies.
+func (cc *CachedClient) Len() int {
+    cc.mu.Lock()
+    defer cc.mu.Unlock()
+    return cc.lru.Len()
+}
+
+// Flush removes all replies from the cache.
+func (cc *CachedClient) Flush() {
+    cc.mu.Lock()
+    cc.flush()
+    cc.mu.Unlock()
+}
+
+// Close closes the tracking connection and flushes the cache.
+func (cc *CachedClient) Close() {
+    cc.mu.Lock()
+    cc.dropTracker()
+    cc.mu.Unlock()
+}
+
+// call sends a cacheable command. In broadcast mode any connection will do,
+// otherwise the connection must have tracking enabled for Redis to remember
+// the keys we read.
+func (cc *CachedClient) call(gen int, redirect int64, args []interface{}) (*Reply, error) {
+    if cc.config.Broadcast {
+        return cc.Client.Call(args...)
+    }
+
+    conn, e := cc.connectTracked(gen, redirect)
+
+    if e != nil {
+        cc.tracked.push(nil)
+        return nil, e
+    }
+
+    if e = conn.Write(args...); e != nil {
+        conn.Close()
+        cc.tracked.push(nil)
+        return nil, e
+    }
+
+    reply, e := conn.Read()
+    cc.tracked.push(conn)
+    return reply, e
+}
+
+// Pop a connection with tracking enabled from the pool. Connections which
+// redirect to a previous tracker are replaced.
+func (cc *CachedClient) connectTracked(gen int, redirect int64) (*trackedConn, error) {
+    if c, ok := cc.tracked.pop().(*trackedConn); ok {
+        if c.gen == gen {
+            return c, nil
+        }
+
+        c.Close()
+    }
+
+    conn, e := cc.dial()
+
+    if e != nil {
+        return nil, e
+    }
+
+    if e = conn.Write("CLIENT", "TRACKING", "ON", "REDIRECT", redirect); e == nil {
+        _, e = conn.Read()
+    }
+
+    if e != nil {
+        conn.Close()
+        return nil, e
+    }
+
+    return &trackedConn{conn, gen}, nil
+}
+
+// startTracker opens the connection which receives invalidation messages.
+// It must be called with cc.mu held.
+func (cc *CachedClient) startTracker() error {
+    conn, e := cc.dial()
+
+    if e != nil {
+        return e
+    }
+
+    cmds := [][]interface{}{{"HELLO", 3}, {"CLIENT", "ID"}}
+
+    if cc.config.Broadcast {
+        cmd := []interface{}{"CLIENT", "TRACKING", "ON", "BCAST"}
+
+        for _, p := range cc.config.Prefixes {
+            cmd = append(cmd, "PREFIX", p)
+        }
+
+        cmds = append(cmds, cmd)
+    }
+
+    var reply *Reply
+
+    for i, cmd := range cmds {
+        if e = conn.Write(cmd...); e == nil {
+            reply, e = conn.Read()
+        }
+
+        if e != nil {
+            conn.Close()
+            return e
+        }
+
+        if i == 1 {
+            cc.redirect = reply.Elem.Int64()
+        }
+    }
+
+    cc.tracker = conn
+    go cc.listen(conn, cc.gen)
+    return nil
+}
+
+// dropTracker closes the tracking connection and flushes the cache. It must
+// be called with cc.mu held.
+func (cc *CachedClient) dropTracker() {
+    if cc.tracker != nil {
+        cc.tracker.Close()
+        cc.tracker = nil
+    }
+
+    cc.gen++
+    cc.flush()
+}
+
+// listen reads invalidation messages until the connection fails.
+func (cc *CachedClient) listen(conn Connection, gen int) {
+    for {
+        reply, e := conn.Read()
+        cc.mu.Lock()
+
+        if gen != cc.gen {
+            cc.mu.Unlock()
+            return
+        }
+
+        if e != nil {
+            cc.dropTracker()
+            cc.mu.Unlock()
+            return
+        }
+
+        cc.invalidate(reply)
+        cc.mu.Unlock()
+    }
+}
+
+// invalidate handles a `invalidate` push message. A nil list of keys is sent
+// when the database is flushed.
+func (cc *CachedClient) invalidate(reply *Reply) {
+    if reply.Len() != 2 || reply.Elems[0].Elem.String() != "invalidate" {
+        return
+    }
+
+    keys := reply.Elems[1]
+
+    if keys.Elems == nil {
+        cc.flush()
+        return
+    }
+
+    for _, k := range keys.Elems {
+        for entry := range cc.byKey[k.Elem.String()] {
+            cc.remove(entry)
+        }
+    }
+}
+
+// cacheable returns the keys read by a command, or nil if the reply for the
+// command should not be cached.
+func (cc *CachedClient) cacheable(args []interface{}) []string {
+    if len(args) < 2 {
+        return nil
+    }
+
+    switch strings.ToUpper(string(argBytes(args[0]))) {
+    case "GET", "HGETALL":
+        if len(args) != 2 {
+            return nil
+        }
+    case "MGET":
+    default:
+        return nil
+    }
+
+    keys := make([]string, len(args)-1)
+
+    for i, arg := range args[1:] {
+        keys[i] = string(argBytes(arg))
+
+        if !cc.matchPrefix(keys[i]) {
+            return nil
+        }
+    }
+
+    return keys
+}
+
+func (cc *CachedClient) matchPrefix(key string) bool {
+    if !cc.config.Broadcast || len(cc.config.Prefixes) == 0 {
+        return true
+    }
+
+    for _, p := range cc.config.Prefixes {
+        if strings.HasPrefix(key, p) {
+            return true
+        }
+    }
+
+    return false
+}
+
+// lookup returns a cached reply or nil. It must be called with cc.mu held.
+func (cc *CachedClient) lookup(id string) *Reply {
+    entry, ok := cc.entries[id]
+
+    if !ok || entry.elem == nil {
+        return nil
+    }
+
+    if cc.config.TTL > 0 && cc.now().After(entry.expires) {
+        cc.remove(entry)
+        return nil
+    }
+
+    cc.lru.MoveToFront(entry.elem)
+    return entry.reply
+}
+
+// claim adds a pending entry which is removed if any of its keys are
+// invalidated before the reply is stored. It returns nil if another caller
+// already is reading the same reply.
+func (cc *CachedClient) claim(id string, keys []string) *cacheEntry {
+    if _, ok := cc.entries[id]; ok {
+        return nil
+    }
+
+    entry := &cacheEntry{id: id, keys: keys}
+    cc.entries[id] = entry
+
+    for _, k := range keys {
+        if cc.byKey[k] == nil {
+            cc.byKey[k] = make(map[*cacheEntry]bool)
+        }
+
+        cc.byKey[k][entry] = true
+    }
+
+    return entry
+}
+
+// store fills a pending entry and evicts the least recently used entries.
+func (cc *CachedClient) store(entry *cacheEntry, reply *Reply) {
+    entry.reply = reply
+    entry.expires = cc.now().Add(cc.config.TTL)
+    entry.elem = cc.lru.PushFront(entry)
+
+    for cc.lru.Len() > cc.config.Size {
+        cc.remove(cc.lru.Back().Value.(*cacheEntry))
+    }
+}
+
+func (cc *CachedClient) remove(entry *cacheEntry) {
+    if cc.entries[entry.id] == entry {
+        delete(cc.entries, entry.id)
+    }
+
+    for _, k := range entry.keys {
+        delete(cc.byKey[k], entry)
+
+        if len(cc.byKey[k]) == 0 {
+            delete(cc.byKey, k)
+        }
+    }
+
+    if entry.elem != nil {
+        cc.lru.Remove(entry.elem)
+        entry.elem = nil
+    }
+}
+
+func (cc *CachedClient) flush() {
+    cc.lru.Init()
+    cc.entries = make(map[string]*cacheEntry)
+    cc.byKey = make(map[string]map[*cacheEntry]bool)
+}
+
+func cacheId(args []interface{}) string {
+    buf := make([]string, len(args))
+
+    for i, arg := range args {
+        buf[i] = string(argBytes(arg))
+    }
+
+    buf[0] = strings.ToUpper(buf[0])
+    return strings.Join(buf, "\x00")
+}
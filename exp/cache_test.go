@@ -0,0 +1,164 @@
+package redis
+
+import (
+    "bytes"
+    "testing"
+    "time"
+
+    "insmo.com/godis/bufin"
+)
+
+func parseString(s string) *Reply {
+    return Parse(bufin.NewReader(bytes.NewBufferString(s)))
+}
+
+func cacheReply(cc *CachedClient, args ...interface{}) *Reply {
+    reply := &Reply{Elem: Elem(cacheId(args))}
+    cc.store(cc.claim(cacheId(args), cc.cacheable(args)), reply)
+    return reply
+}
+
+func TestCacheable(t *testing.T) {
+    cc := NewClient("", 0, "").CachedClient(CacheConfig{})
+    tests := []struct {
+        args []interface{}
+        n    int
+    }{
+        {[]interface{}{"GET", "foo"}, 1},
+        {[]interface{}{"get", "foo"}, 1},
+        {[]interface{}{"HGETALL", "foo"}, 1},
+        {[]interface{}{"MGET", "foo", "bar", 1}, 3},
+        {[]interface{}{"GET"}, 0},
+        {[]interface{}{"GET", "foo", "bar"}, 0},
+        {[]interface{}{"SET", "foo", "bar"}, 0},
+    }
+
+    for _, test := range tests {
+        if keys := cc.cacheable(test.args); len(keys) != test.n {
+            t.Errorf("%v: expected %d keys got %q", test.args, test.n, keys)
+        }
+    }
+
+    cc = NewClient("", 0, "").CachedClient(CacheConfig{Broadcast: true, Prefixes: []string{"flag:"}})
+
+    if keys := cc.cacheable([]interface{}{"GET", "flag:foo"}); len(keys) != 1 {
+        t.Errorf("expected prefixed key to be cacheable")
+    }
+
+    if keys := cc.cacheable([]interface{}{"MGET", "flag:foo", "foo"}); keys != nil {
+        t.Errorf("expected key without prefix not to be cacheable")
+    }
+}
+
+func TestCacheLRU(t *testing.T) {
+    cc := NewClient("", 0, "").CachedClient(CacheConfig{Size: 2})
+    a := cacheReply(cc, "GET", "a")
+    cacheReply(cc, "GET", "b")
+
+    if cc.lookup(cacheId([]interface{}{"GET", "a"})) != a {
+        t.Fatalf("expected a to be cached")
+    }
+
+    cacheReply(cc, "GET", "c")
+
+    if cc.Len() != 2 {
+        t.Errorf("expected 2 entries got %d", cc.Len())
+    }
+
+    if cc.lookup(cacheId([]interface{}{"GET", "b"})) != nil {
+        t.Errorf("expected b to be evicted")
+    }
+
+    if cc.lookup(cacheId([]interface{}{"GET", "a"})) != a {
+        t.Errorf("expected a to be cached")
+    }
+}
+
+func TestCacheTTL(t *testing.T) {
+    now := time.Now()
+    cc := NewClient("", 0, "").CachedClient(CacheConfig{TTL: time.Second})
+    cc.now = func() time.Time { return now }
+    cacheReply(cc, "GET", "a")
+    now = now.Add(time.Second)
+
+    if cc.lookup(cacheId([]interface{}{"GET", "a"})) == nil {
+        t.Errorf("expected a to be cached")
+    }
+
+    now = now.Add(time.Millisecond)
+
+    if cc.lookup(cacheId([]interface{}{"GET", "a"})) != nil {
+        t.Errorf("expected a to be expired")
+    }
+
+    if cc.Len() != 0 {
+        t.Errorf("expected empty cache got %d", cc.Len())
+    }
+}
+
+func TestCacheInvalidate(t *testing.T) {
+    cc := NewClient("", 0, "").CachedClient(CacheConfig{})
+    cacheReply(cc, "GET", "a")
+    cacheReply(cc, "GET", "b")
+    cacheReply(cc, "MGET", "a", "c")
+
+    pending := cc.claim("pending", []string{"c"})
+    cc.invalidate(parseString(">2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\na\r\n"))
+
+    if cc.Len() != 1 {
+        t.Errorf("expected 1 entry got %d", cc.Len())
+    }
+
+    cc.invalidate(parseString(">2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\nc\r\n"))
+
+    if cc.entries["pending"] == pending {
+        t.Errorf("expected pending entry to be invalidated")
+    }
+
+    cc.invalidate(parseString(">2\r\n$10\r\ninvalidate\r\n_\r\n"))
+
+    if cc.Len() != 0 || len(cc.byKey) != 0 {
+        t.Errorf("expected empty cache got %d", cc.Len())
+    }
+}
+
+// TestCachedClient needs a Redis 6 server on localhost:6379 for client side
+// caching, it is skipped without one.
+func TestCachedClient(t *testing.T) {
+    c := NewClient("", 0, "")
+
+    for _, cmd := range [][]interface{}{{"CLIENT", "ID"}, {"CLIENT", "TRACKING", "OFF"}} {
+        if _, e := c.Call(cmd...); e != nil {
+            t.Skipf("client side caching not supported: %s", e)
+        }
+    }
+
+    cc := c.CachedClient(CacheConfig{})
+    defer cc.Close()
+
+    if _, e := c.Call("SET", "foo", "foo"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    for i := 0; i < 2; i++ {
+        if r, e := cc.Call("GET", "foo"); e != nil || r.Elem.String() != "foo" {
+            error_(t, "cached get", "foo", r, e)
+        }
+    }
+
+    if cc.Len() != 1 {
+        t.Fatalf("expected 1 cached reply got %d", cc.Len())
+    }
+
+    if _, e := c.Call("SET", "foo", "bar"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    for i := 0; i < 100 && cc.Len() > 0; i++ {
+        time.Sleep(time.Millisecond)
+    }
+
+    if r, e := cc.Call("GET", "foo"); e != nil || r.Elem.String() != "bar" {
+        error_(t, "invalidated get", "bar", r, e)
+    }
+}
@@ -3,10 +3,9 @@ package redis
 import (
     "insmo.com/godis/bufin"
     "net"
+    "sync/atomic"
 )
 
-var ConnSum = 0
-
 type Connection interface {
     Write(args ...interface{}) error
     Read() (*Reply, error)
@@ -16,8 +15,10 @@ type Connection interface {
 
 // Conn implements the Connection interface. 
 type Conn struct {
-    rbuf *bufin.Reader
-    c    net.Conn
+    rbuf  *bufin.Reader
+    c     net.Conn
+    stats *clientStats
+    log   *clientLog
 }
 
 // NewConn expects a network address and protocol.
@@ -32,14 +33,33 @@ type Conn struct {
 // interface. It's easy to use this interface to create your own
 // redis client or to simply talk to the redis database. 
 func NewConn(addr, proto string, db int, password string) (*Conn, error) {
+    return newConn(addr, proto, db, password, nil, nil)
+}
+
+// newConn opens a connection which updates stats and logs to log, if they
+// are not nil.
+func newConn(addr, proto string, db int, password string, stats *clientStats, log *clientLog) (*Conn, error) {
+    var conn net.Conn
     conn, err := net.Dial(proto, addr)
 
+    if stats != nil {
+        atomic.AddInt64(&stats.dials, 1)
+
+        if err != nil {
+            atomic.AddInt64(&stats.dialFailures, 1)
+        } else {
+            atomic.AddInt64(&stats.connsOpened, 1)
+            conn = &statsConn{Conn: conn, stats: stats}
+        }
+    }
+
     if err != nil {
+        log.logf(LevelWarn, "dial %s:%s: %s", proto, addr, err)
         return nil, err
     }
 
-    ConnSum++
-    c := &Conn{bufin.NewReader(conn), conn}
+    log.logf(LevelInfo, "connected to %s:%s", proto, addr)
+    c := &Conn{bufin.NewReader(conn), conn, stats, log}
 
     if password != "" {
         e := c.Write("AUTH", password)
@@ -76,7 +96,7 @@ func NewConn(addr, proto string, db int, password string) (*Conn, error) {
 // this method will block.
 // Returns either an error or a pointer to a Reply object.
 func (c *Conn) Read() (*Reply, error) {
-    reply := Parse(c.rbuf)
+    reply := c.readReply()
 
     if reply.Err != nil {
         return nil, reply.Err
@@ -85,6 +105,26 @@ func (c *Conn) Read() (*Reply, error) {
     return reply, nil
 }
 
+// ReadReply reads one reply like Read, but returns error replies as a
+// Reply with Err set. Errors nested in a multi-bulk reply are kept in its
+// Elems. A reply whose Type is empty could not be read.
+func (c *Conn) ReadReply() *Reply {
+    return c.readReply()
+}
+
+// readReply parses one reply and keeps its type, so a read failure can be
+// told apart from an error reply.
+func (c *Conn) readReply() *Reply {
+    reply := Parse(c.rbuf)
+
+    if c.stats != nil {
+        c.stats.reply(reply)
+    }
+
+    c.log.reply(reply)
+    return reply
+}
+
 // Write accepts any redis command and arbitrary list of arguments.
 // 
 //     Write("SET", "counter", 1)
@@ -92,6 +132,12 @@ func (c *Conn) Read() (*Reply, error) {
 //
 // Write might return a net.Conn.Write error
 func (c *Conn) Write(args ...interface{}) error {
+    if c.stats != nil {
+        atomic.AddInt64(&c.stats.commands, 1)
+    }
+
+    c.log.command(args)
+
     _, e := c.c.Write(format(args...))
 
     if e != nil {
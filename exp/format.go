@@ -14,6 +14,18 @@ const (
     minus  byte = 45
     plus   byte = 43
     star   byte = 42
+
+    // RESP3
+    underscore byte = 95
+    hash       byte = 35
+    comma      byte = 44
+    lparen     byte = 40
+    equals     byte = 61
+    bang       byte = 33
+    percent    byte = 37
+    tilde      byte = 126
+    gt         byte = 62
+    pipe       byte = 124
 )
 
 var (
@@ -77,19 +89,24 @@ func format(args ...interface{}) []byte {
     buf := make([][]byte, len(args))
 
     for i, arg := range args {
-        switch v := arg.(type) {
-        case []byte:
-            buf[i] = v
-        case nil:
-            buf[i] = []byte(nil)
-        case string:
-            buf[i] = []byte(v)
-        default:
-            var b bytes.Buffer
-            fmt.Fprint(&b, v)
-            buf[i] = b.Bytes()
-        }
+        buf[i] = argBytes(arg)
     }
 
     return formatArgs(buf)
 }
+
+// argBytes returns the bytes sent to Redis for a single argument.
+func argBytes(arg interface{}) []byte {
+    switch v := arg.(type) {
+    case []byte:
+        return v
+    case nil:
+        return []byte(nil)
+    case string:
+        return []byte(v)
+    }
+
+    var b bytes.Buffer
+    fmt.Fprint(&b, arg)
+    return b.Bytes()
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+    "bytes"
     "testing"
 )
 
@@ -23,3 +24,57 @@ func BenchmarkFormat(t *testing.B) {
         format("SET", "foo", "bar")
     }
 }
+
+func BenchmarkFormatArgs(b *testing.B) {
+    sizes := []struct {
+        name  string
+        args  int
+        value int
+    }{
+        {"3x16", 3, 16},
+        {"3x1K", 3, 1 << 10},
+        {"3x64K", 3, 64 << 10},
+        {"100x16", 100, 16},
+    }
+
+    for _, size := range sizes {
+        b.Run(size.name, func(b *testing.B) {
+            args := make([][]byte, size.args)
+
+            for i := range args {
+                args[i] = bytes.Repeat([]byte("x"), size.value)
+            }
+
+            b.SetBytes(int64(len(formatArgs(args))))
+            b.ReportAllocs()
+            b.ResetTimer()
+
+            for i := 0; i < b.N; i++ {
+                formatArgs(args)
+            }
+        })
+    }
+}
+
+func BenchmarkFormatTypes(b *testing.B) {
+    args := []struct {
+        name string
+        arg  interface{}
+    }{
+        {"string", "foo"},
+        {"bytes", []byte("foo")},
+        {"int", 1234567},
+        {"float", 3.14},
+        {"nil", nil},
+    }
+
+    for _, a := range args {
+        b.Run(a.name, func(b *testing.B) {
+            b.ReportAllocs()
+
+            for i := 0; i < b.N; i++ {
+                format("SET", "foo", a.arg)
+            }
+        })
+    }
+}
@@ -0,0 +1,241 @@
+package redis
+
+// Future is the reply of a command queued in a Pipeline or AsyncClient. It
+// is resolved once, by Pipeline.Exec or when the AsyncClient reads the
+// reply.
+type Future struct {
+    done  chan struct{}
+    reply *Reply
+    err   error
+}
+
+func newFuture() *Future {
+    return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) resolve(reply *Reply, err error) {
+    f.reply, f.err = reply, err
+    close(f.done)
+}
+
+// Done returns a channel which is closed when the future is resolved.
+func (f *Future) Done() <-chan struct{} {
+    return f.done
+}
+
+// Reply blocks until the future is resolved and returns the reply of the
+// command or an error. A future of an AsyncClient is only resolved by Read
+// or ReadAll, so calling Reply before them blocks forever.
+func (f *Future) Reply() (*Reply, error) {
+    <-f.done
+    return f.reply, f.err
+}
+
+// StringCmd is a future for a bulk or status reply.
+type StringCmd struct {
+    *Future
+}
+
+// Result returns the reply as a string, or an error. A nil reply is the
+// empty string.
+func (c *StringCmd) Result() (string, error) {
+    r, e := c.Reply()
+
+    if e != nil {
+        return "", e
+    }
+
+    return r.Elem.String(), nil
+}
+
+// Val returns the reply as a string, ignoring errors.
+func (c *StringCmd) Val() string {
+    v, _ := c.Result()
+    return v
+}
+
+// IntCmd is a future for an integer reply.
+type IntCmd struct {
+    *Future
+}
+
+// Result returns the reply as an int64, or an error.
+func (c *IntCmd) Result() (int64, error) {
+    r, e := c.Reply()
+
+    if e != nil {
+        return 0, e
+    }
+
+    return r.Elem.Int64(), nil
+}
+
+// Val returns the reply as an int64, ignoring errors.
+func (c *IntCmd) Val() int64 {
+    v, _ := c.Result()
+    return v
+}
+
+// FloatCmd is a future for a float reply such as the one of INCRBYFLOAT
+// or ZSCORE.
+type FloatCmd struct {
+    *Future
+}
+
+// Result returns the reply as a float64, or an error.
+func (c *FloatCmd) Result() (float64, error) {
+    r, e := c.Reply()
+
+    if e != nil {
+        return 0, e
+    }
+
+    return r.Elem.Float64(), nil
+}
+
+// Val returns the reply as a float64, ignoring errors.
+func (c *FloatCmd) Val() float64 {
+    v, _ := c.Result()
+    return v
+}
+
+// BoolCmd is a future for an integer reply of 0 or 1, such as the one of
+// EXISTS or SISMEMBER, or a RESP3 boolean.
+type BoolCmd struct {
+    *Future
+}
+
+// Result returns the reply as a bool, or an error.
+func (c *BoolCmd) Result() (bool, error) {
+    r, e := c.Reply()
+
+    if e != nil {
+        return false, e
+    }
+
+    return r.Elem.Bool(), nil
+}
+
+// Val returns the reply as a bool, ignoring errors.
+func (c *BoolCmd) Val() bool {
+    v, _ := c.Result()
+    return v
+}
+
+// StringsCmd is a future for a multi-bulk reply such as the one of MGET or
+// LRANGE.
+type StringsCmd struct {
+    *Future
+}
+
+// Result returns the elements of the reply as strings, or an error.
+func (c *StringsCmd) Result() ([]string, error) {
+    r, e := c.Reply()
+
+    if e != nil {
+        return nil, e
+    }
+
+    return r.StringArray(), nil
+}
+
+// Val returns the elements of the reply as strings, ignoring errors.
+func (c *StringsCmd) Val() []string {
+    v, _ := c.Result()
+    return v
+}
+
+// StringMapCmd is a future for a multi-bulk reply of fields and values,
+// such as the one of HGETALL.
+type StringMapCmd struct {
+    *Future
+}
+
+// Result returns the reply as a map, or an error.
+func (c *StringMapCmd) Result() (map[string]string, error) {
+    r, e := c.Reply()
+
+    if e != nil {
+        return nil, e
+    }
+
+    return r.StringMap(), nil
+}
+
+// Val returns the reply as a map, ignoring errors.
+func (c *StringMapCmd) Val() map[string]string {
+    v, _ := c.Result()
+    return v
+}
+
+// Queue appends a command to the write buffer and returns a future which
+// is resolved when its reply is read.
+func (ac *AsyncClient) Queue(args ...interface{}) *Future {
+    f := newFuture()
+    ac.queue(args, f)
+    return f
+}
+
+// QueueString queues a command whose reply is a string.
+func (ac *AsyncClient) QueueString(args ...interface{}) *StringCmd {
+    return &StringCmd{ac.Queue(args...)}
+}
+
+// QueueInt queues a command whose reply is an integer.
+//
+//      incr := ac.QueueInt("INCR", "counter")
+//      ac.ReadAll()
+//      println(incr.Val())
+func (ac *AsyncClient) QueueInt(args ...interface{}) *IntCmd {
+    return &IntCmd{ac.Queue(args...)}
+}
+
+// QueueFloat queues a command whose reply is a float.
+func (ac *AsyncClient) QueueFloat(args ...interface{}) *FloatCmd {
+    return &FloatCmd{ac.Queue(args...)}
+}
+
+// QueueBool queues a command whose reply is a boolean.
+func (ac *AsyncClient) QueueBool(args ...interface{}) *BoolCmd {
+    return &BoolCmd{ac.Queue(args...)}
+}
+
+// QueueStrings queues a command whose reply is a list of strings.
+func (ac *AsyncClient) QueueStrings(args ...interface{}) *StringsCmd {
+    return &StringsCmd{ac.Queue(args...)}
+}
+
+// QueueStringMap queues a command whose reply is a list of fields and values.
+func (ac *AsyncClient) QueueStringMap(args ...interface{}) *StringMapCmd {
+    return &StringMapCmd{ac.Queue(args...)}
+}
+
+// QueueString queues a command whose reply is a string.
+func (p *Pipeline) QueueString(args ...interface{}) *StringCmd {
+    return &StringCmd{p.Queue(args...)}
+}
+
+// QueueInt queues a command whose reply is an integer.
+func (p *Pipeline) QueueInt(args ...interface{}) *IntCmd {
+    return &IntCmd{p.Queue(args...)}
+}
+
+// QueueFloat queues a command whose reply is a float.
+func (p *Pipeline) QueueFloat(args ...interface{}) *FloatCmd {
+    return &FloatCmd{p.Queue(args...)}
+}
+
+// QueueBool queues a command whose reply is a boolean.
+func (p *Pipeline) QueueBool(args ...interface{}) *BoolCmd {
+    return &BoolCmd{p.Queue(args...)}
+}
+
+// QueueStrings queues a command whose reply is a list of strings.
+func (p *Pipeline) QueueStrings(args ...interface{}) *StringsCmd {
+    return &StringsCmd{p.Queue(args...)}
+}
+
+// QueueStringMap queues a command whose reply is a list of fields and values.
+func (p *Pipeline) QueueStringMap(args ...interface{}) *StringMapCmd {
+    return &StringMapCmd{p.Queue(args...)}
+}
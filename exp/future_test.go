@@ -0,0 +1,192 @@
+package redis
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+
+    "insmo.com/godis/redistest"
+)
+
+func TestAsyncFutures(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    ac := NewAsyncClient(s.NetAddr(), 0, "")
+    defer ac.Close()
+
+    set := ac.QueueString("SET", "n", 1)
+    ac.Call("RPUSH", "list", "a", "b")
+    incr := ac.QueueInt("INCR", "n")
+    float := ac.QueueFloat("INCRBYFLOAT", "n", "0.5")
+    exists := ac.QueueBool("EXISTS", "list")
+    list := ac.QueueStrings("LRANGE", "list", 0, -1)
+    ac.Call("HSET", "h", "f", "v")
+    hash := ac.QueueStringMap("HGETALL", "h")
+    missing := ac.QueueString("GET", "missing")
+    bad := ac.QueueInt("INCR", "list")
+
+    // ReadAll returns the error reply of INCR, all futures are resolved
+    if _, e := ac.ReadAll(); e == nil {
+        t.Fatal("expected WRONGTYPE error")
+    }
+
+    if v := set.Val(); v != "OK" {
+        t.Errorf("SET: expected OK got %q", v)
+    }
+
+    if v := incr.Val(); v != 2 {
+        t.Errorf("INCR: expected 2 got %d", v)
+    }
+
+    if v := float.Val(); v != 2.5 {
+        t.Errorf("INCRBYFLOAT: expected 2.5 got %v", v)
+    }
+
+    if v := exists.Val(); !v {
+        t.Errorf("EXISTS: expected true")
+    }
+
+    if v := list.Val(); !reflect.DeepEqual(v, []string{"a", "b"}) {
+        t.Errorf("LRANGE: expected [a b] got %q", v)
+    }
+
+    if v := hash.Val(); !reflect.DeepEqual(v, map[string]string{"f": "v"}) {
+        t.Errorf("HGETALL: expected map[f:v] got %v", v)
+    }
+
+    if v, e := missing.Result(); e != nil || v != "" {
+        t.Errorf("GET: expected empty string got %q, %v", v, e)
+    }
+
+    if _, e := bad.Result(); e == nil {
+        t.Errorf("INCR: expected WRONGTYPE error")
+    }
+
+    if n := ac.Queued(); n != 0 {
+        t.Errorf("expected 0 queued got %d", n)
+    }
+}
+
+func TestAsyncFuturesAfterError(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    ac := NewAsyncClient(s.NetAddr(), 0, "")
+    defer ac.Close()
+
+    ac.Call("SET", "s", "str")
+    bad := ac.QueueInt("INCR", "s")
+    incr := ac.QueueInt("INCR", "n")
+
+    if _, e := ac.ReadAll(); e == nil || !strings.Contains(e.Error(), "not an integer") {
+        t.Fatalf("expected the error of the first INCR got %v", e)
+    }
+
+    if _, e := bad.Result(); e == nil {
+        t.Errorf("expected the error of the first INCR")
+    }
+
+    if v, e := incr.Result(); e != nil || v != 1 {
+        t.Errorf("expected the second INCR to succeed got %d, %v", v, e)
+    }
+
+    if n := ac.Queued(); n != 0 {
+        t.Errorf("expected 0 queued got %d", n)
+    }
+
+    // the connection is still in sync
+    ac.Call("GET", "n")
+
+    if r, e := ac.Read(); e != nil || r.Elem.String() != "1" {
+        t.Errorf("expected 1 got %v, %v", r, e)
+    }
+}
+
+func TestAsyncFuturesClosed(t *testing.T) {
+    s := redistest.NewServer()
+    ac := NewAsyncClient(s.NetAddr(), 0, "")
+    defer ac.Close()
+    s.Close()
+
+    get := ac.QueueString("GET", "a")
+    incr := ac.QueueInt("INCR", "n")
+
+    if _, e := ac.ReadAll(); e == nil {
+        t.Fatal("expected a connection error")
+    }
+
+    for i, f := range []*Future{get.Future, incr.Future} {
+        select {
+        case <-f.Done():
+            if _, e := f.Reply(); e == nil {
+                t.Errorf("%d: expected the connection error", i)
+            }
+        default:
+            t.Errorf("%d: future not resolved", i)
+        }
+    }
+
+    if n := ac.Queued(); n != 0 {
+        t.Errorf("expected 0 queued got %d", n)
+    }
+}
+
+func TestAsyncFuturesRead(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    ac := NewAsyncClient(s.NetAddr(), 0, "")
+    defer ac.Close()
+
+    incr := ac.QueueInt("INCR", "n")
+
+    select {
+    case <-incr.Done():
+        t.Fatal("future resolved before the reply was read")
+    default:
+    }
+
+    if r, e := ac.Read(); e != nil || r.Elem.Int() != 1 {
+        t.Fatalf("expected 1 got %v, %v", r, e)
+    }
+
+    if v := incr.Val(); v != 1 {
+        t.Errorf("expected 1 got %d", v)
+    }
+}
+
+func TestAsyncFuturesRefused(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    c := NewClient(s.NetAddr(), 0, "")
+    c.AddHook(refuseHook{})
+    ac := c.AsyncClient()
+    incr := ac.QueueInt("INCR", "n")
+
+    if _, e := ac.ReadAll(); e == nil {
+        t.Fatal("expected pipeline to be refused")
+    }
+
+    if _, e := incr.Result(); e == nil || e.Error() != "refused" {
+        t.Errorf("expected refused got %v", e)
+    }
+}
+
+func TestPipelineFutures(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    p := NewPipeline(s.NetAddr(), 0, "")
+    incr := p.QueueInt("INCR", "n")
+    get := p.QueueString("GET", "n")
+
+    if e := p.Exec(); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if incr.Val() != 1 || get.Val() != "1" {
+        t.Errorf("expected 1 got %d, %q", incr.Val(), get.Val())
+    }
+}
@@ -51,14 +51,66 @@
 //      reply, _ = c.Read()
 //
 //      println(reply.Elem.Int()) // prints 1
+//
+// Instead of keeping track of which Read belongs to which command, commands
+// can be queued with a typed method which returns a future. The future is
+// resolved when its reply is read.
+//
+//      incr := c.QueueInt("INCR", "counter")
+//      members := c.QueueStrings("SMEMBERS", "set")
+//      c.ReadAll()
+//
+//      println(incr.Val(), len(members.Val()))
 // 
 // Due to the nature of how the AsyncClient works, it's not safe to share it
 // between go routines.
+//
+// Pipeline
+//
+// The Pipeline queues commands like the AsyncClient, but returns a Future
+// for every command and is safe to share between go routines. Exec sends
+// the queued commands in one write and resolves their futures.
+//
+//      p := c.Pipeline()
+//      p.Queue("SET", "foo", 1)
+//      incr := p.Queue("INCR", "foo")
+//
+//      if e := p.Exec(); e != nil {
+//          // handle error
+//      }
+//
+//      reply, _ := incr.Reply()
+//      println(reply.Elem.Int()) // prints 2
+//
+// CachedClient
+//
+// The CachedClient keeps replies for GET, HGETALL and MGET in a local cache.
+// It requires Redis 6 or later, which notifies the client when a cached key
+// is modified.
+//
+//      c := redis.NewCachedClient("tcp:127.0.0.1:6379", 0, "", redis.CacheConfig{
+//          Size: 1000,
+//          TTL:  time.Minute,
+//      })
+//      defer c.Close()
+//
+//      reply, e := c.Call("GET", "feature:foo")
+//
+// Hooks
+//
+// A Hook is called before and after every command, pipeline and new
+// connection of a Client. Hooks are used for logging, metrics and tracing,
+// and can answer a command without sending it to Redis.
+//
+//      c := redis.NewClient("tcp:127.0.0.1:6379", 0, "")
+//      c.AddHook(myHook)
 package redis
 
 import (
     "bytes"
     "strings"
+    "sync/atomic"
+    "time"
 )
 
 // Client implements a Redis client which handles connections to the database
@@ -70,6 +122,9 @@ type Client struct {
     Db       int
     Password string
     pool     *connPool
+    hooks    hookChain
+    stats    *clientStats
+    log      *clientLog
 }
 
 // NewClient expects a addr like "tcp:127.0.0.1:6379"
@@ -80,27 +135,61 @@ func NewClient(addr string, db int, password string) *Client {
     }
 
     na := strings.SplitN(addr, ":", 2)
-    return &Client{na[1], na[0], db, password, newConnPool()}
+    return &Client{na[1], na[0], db, password, newConnPool(), nil, new(clientStats), newClientLog()}
 }
 
 // Call is the canonical way of talking to Redis. It accepts any 
 // Redis command and a arbitrary number of arguments.
 // Call returns a Reply object or an error.
 func (c *Client) Call(args ...interface{}) (*Reply, error) {
+    if len(c.hooks) == 0 {
+        return c.call(args)
+    }
+
+    cmd := &Cmd{Args: args}
+    n, ok := c.hooks.beforeCall(cmd)
+
+    if ok {
+        cmd.Start = time.Now()
+        cmd.Reply, cmd.Err = c.call(args)
+        cmd.Duration = time.Since(cmd.Start)
+    }
+
+    c.hooks.afterCall(n, cmd)
+    return cmd.Reply, cmd.Err
+}
+
+func (c *Client) call(args []interface{}) (*Reply, error) {
     conn, err := c.connect()
-    defer c.pool.push(conn)
+
+    defer func() {
+        c.pool.push(conn)
+    }()
 
     if err != nil {
         return nil, err
     }
 
-    conn.Write(args...)
-
-    if err != nil {
+    if err = conn.Write(args...); err != nil {
+        conn.Close()
+        conn = nil
         return nil, err
     }
 
-    return conn.Read()
+    r := conn.(*Conn).readReply()
+
+    if r.typ == 0 {
+        // the connection is out of sync or broken, don't reuse it
+        conn.Close()
+        conn = nil
+        return nil, r.Err
+    }
+
+    if r.Err != nil {
+        return nil, r.Err
+    }
+
+    return r, nil
 }
 
 // Pop a connection from pool 
@@ -108,11 +197,38 @@ func (c *Client) connect() (conn Connection, err error) {
     conn = c.pool.pop()
 
     if conn == nil {
-        conn, err = NewConn(c.Addr, c.Proto, c.Db, c.Password)
+        dialed, err := c.dial()
 
         if err != nil {
             return nil, err
         }
+
+        conn = dialed
+    }
+
+    return conn, nil
+}
+
+// Open a new connection and run the dial hooks
+func (c *Client) dial() (*Conn, error) {
+    if len(c.hooks) == 0 {
+        return newConn(c.Addr, c.Proto, c.Db, c.Password, c.stats, c.log)
+    }
+
+    ev := &DialEvent{Addr: c.Addr, Proto: c.Proto, Db: c.Db}
+    n := c.hooks.beforeDial(ev)
+    var conn *Conn
+
+    if ev.Err == nil {
+        ev.Start = time.Now()
+        conn, ev.Err = newConn(c.Addr, c.Proto, c.Db, c.Password, c.stats, c.log)
+        ev.Duration = time.Since(ev.Start)
+    }
+
+    c.hooks.afterDial(n, ev)
+
+    if ev.Err != nil {
+        return nil, ev.Err
     }
 
     return conn, nil
@@ -120,7 +236,7 @@ func (c *Client) connect() (conn Connection, err error) {
 
 // Use the connection settings from Client to create a new AsyncClient
 func (c *Client) AsyncClient() *AsyncClient {
-    return &AsyncClient{c, bytes.NewBuffer(make([]byte, 0, 1024*16)), nil, 0}
+    return &AsyncClient{c, bytes.NewBuffer(make([]byte, 0, 1024*16)), nil, 0, nil, nil}
 }
 
 // Async client implements an asynchronous client. It is very similar to Client
@@ -131,6 +247,12 @@ type AsyncClient struct {
     buf    *bytes.Buffer
     conn   Connection
     queued int
+
+    // queued commands, only kept for the pipeline hooks
+    cmds []*Cmd
+
+    // futures of the queued commands, nil for commands queued by Call
+    futures []*Future
 }
 
 // NewAsyncClient expects a addr like "tcp:127.0.0.1:6379"
@@ -141,13 +263,29 @@ func NewAsyncClient(addr string, db int, password string) *AsyncClient {
         bytes.NewBuffer(make([]byte, 0, 1024*16)),
         nil,
         0,
+        nil,
+        nil,
     }
 }
 
-// Call appends a command to the write buffer or returns an error.
-func (ac *AsyncClient) Call(args ...interface{}) (err error) {
+// Call appends a command to the write buffer or returns an error. Use
+// Queue or one of the typed methods such as QueueInt to get a future for the
+// reply instead of reading it with Read.
+func (ac *AsyncClient) Call(args ...interface{}) error {
+    return ac.queue(args, nil)
+}
+
+func (ac *AsyncClient) queue(args []interface{}, f *Future) (err error) {
     _, err = ac.buf.Write(format(args...))
     ac.queued++
+    ac.futures = append(ac.futures, f)
+    atomic.AddInt64(&ac.stats.commands, 1)
+    ac.log.command(args)
+
+    if len(ac.hooks) > 0 {
+        ac.cmds = append(ac.cmds, &Cmd{Args: args})
+    }
+
     return err
 }
 
@@ -157,10 +295,32 @@ func (ac *AsyncClient) Call(args ...interface{}) (err error) {
 //      2) Write any buffered commands to the server.
 //      3) Try to read a reply from the server, or block on read.
 //
-// Read returns a Reply or error.
+// Read returns a Reply or error. An error reply resolves only the future of
+// its command. If no reply can be read the connection is closed and the
+// futures of all queued commands are resolved with the error.
 func (ac *AsyncClient) Read() (*Reply, error) {
+    reply, e := ac.read()
+
+    if e != nil {
+        ac.discard(e)
+        return nil, e
+    }
+
+    if reply.Err != nil {
+        reply, e = nil, reply.Err
+    }
+
+    ac.pop(reply, e)
+    return reply, e
+}
+
+// read writes the buffered commands and reads the next reply. An error
+// reply is returned as a Reply with Err set. An error is returned if no
+// reply could be read, the connection is then closed as it can not be
+// used any more.
+func (ac *AsyncClient) read() (*Reply, error) {
     if ac.conn == nil {
-        conn, e := NewConn(ac.Addr, ac.Proto, ac.Db, ac.Password)
+        conn, e := ac.dial()
 
         if e != nil {
             return nil, e
@@ -173,38 +333,151 @@ func (ac *AsyncClient) Read() (*Reply, error) {
         _, err := ac.buf.WriteTo(ac.conn.Sock())
 
         if err != nil {
+            ac.Close()
             return nil, err
         }
     }
 
-    reply, e := ac.conn.Read()
-    ac.queued--
-    return reply, e
+    r := ac.conn.(*Conn).readReply()
+
+    if r.typ == 0 {
+        ac.Close()
+        return nil, r.Err
+    }
+
+    return r, nil
+}
+
+// pop resolves the future of the oldest queued command.
+func (ac *AsyncClient) pop(reply *Reply, e error) {
+    // replies which were not queued, e.g. pubsub messages
+    if ac.queued > 0 {
+        ac.queued--
+    }
+
+    if len(ac.cmds) > 0 {
+        ac.cmds = ac.cmds[1:]
+    }
+
+    if len(ac.futures) > 0 {
+        if f := ac.futures[0]; f != nil {
+            f.resolve(reply, e)
+        }
+
+        ac.futures = ac.futures[1:]
+    }
+}
+
+// discard resolves the futures of all queued commands with e and drops
+// the commands not yet written.
+func (ac *AsyncClient) discard(e error) {
+    for _, f := range ac.futures {
+        if f != nil {
+            f.resolve(nil, e)
+        }
+    }
+
+    ac.buf.Reset()
+    ac.queued = 0
+    ac.cmds = nil
+    ac.futures = nil
 }
 
 func (ac *AsyncClient) Queued() int {
     return ac.queued
 }
 
+// ReadAll reads the replies of all queued commands and returns the first
+// error. An error reply does not stop reading, the future of every queued
+// command is resolved with its reply or its own error. The pipeline hooks
+// are called with the queued commands before they are sent and after the
+// replies are read.
 func (ac *AsyncClient) ReadAll() ([]*Reply, error) {
+    if len(ac.hooks) == 0 {
+        replies, e := ac.readAll()
+
+        if e != nil {
+            return nil, e
+        }
+
+        return replies, nil
+    }
+
+    cmds := ac.cmds
+    n, e := ac.hooks.beforePipeline(cmds)
+
+    if e != nil {
+        ac.discard(e)
+        ac.hooks.afterPipeline(n, cmds)
+        return nil, e
+    }
+
+    start := time.Now()
+    replies, e := ac.readAll()
+    d := time.Since(start)
+
+    for i, cmd := range cmds {
+        cmd.Start, cmd.Duration = start, d
+
+        if i >= len(replies) {
+            cmd.Err = e
+        } else if r := replies[i]; r.Err != nil {
+            cmd.Err = r.Err
+        } else {
+            cmd.Reply = r
+        }
+    }
+
+    ac.hooks.afterPipeline(n, cmds)
+
+    if e != nil {
+        return nil, e
+    }
+
+    return replies, nil
+}
+
+// readAll returns the replies of all queued commands, error replies with
+// Err set, and the first error. If a reply can not be read the replies
+// read before are returned.
+func (ac *AsyncClient) readAll() ([]*Reply, error) {
     replies := make([]*Reply, 0, ac.queued)
+    var first error
 
-    for ac.Queued() > 0 {
-        r, e := ac.Read()
+    for ac.queued > 0 {
+        r, e := ac.read()
 
         if e != nil {
-            return nil, e
+            ac.discard(e)
+
+            if first == nil {
+                first = e
+            }
+
+            break
+        }
+
+        if r.Err != nil {
+            ac.pop(nil, r.Err)
+
+            if first == nil {
+                first = r.Err
+            }
+        } else {
+            ac.pop(r, nil)
         }
 
         replies = append(replies, r)
     }
 
-    return replies, nil
+    return replies, first
 }
 
 // The AsyncClient will only open one connection. This is not automatically
 // closed, so to close it we need to call this method.
 func (ac *AsyncClient) Close() {
-    ac.conn.Close()
-    ac.conn = nil
+    if ac.conn != nil {
+        ac.conn.Close()
+        ac.conn = nil
+    }
 }
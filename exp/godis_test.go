@@ -4,6 +4,8 @@ import (
     "bytes"
     "strconv"
     "testing"
+
+    "insmo.com/godis/redistest"
 )
 
 func error_(t *testing.T, name string, expected, got interface{}, err error) {
@@ -15,7 +17,9 @@ func error_(t *testing.T, name string, expected, got interface{}, err error) {
 }
 
 func TestClient(t *testing.T) {
-    c := NewClient("", 0, "")
+    s := redistest.NewServer()
+    defer s.Close()
+    c := NewClient(s.NetAddr(), 0, "")
 
     if _, err := c.Call("SET", "foo", "foo"); err != nil {
         t.Fatal(err.Error())
@@ -54,7 +58,9 @@ func BenchmarkItoa(b *testing.B) {
 }
 
 func BenchmarkSet(b *testing.B) {
-    c := NewClient("", 0, "")
+    s := redistest.NewServer()
+    defer s.Close()
+    c := NewClient(s.NetAddr(), 0, "")
 
     for i := 0; i < b.N; i++ {
         c.Call("SET", "foo", "foo")
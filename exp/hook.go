@@ -0,0 +1,137 @@
+package redis
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Cmd is a command passing through the hooks of a Client.
+type Cmd struct {
+    Args  []interface{}
+    Reply *Reply
+    Err   error
+
+    // Start is when the command was sent and Duration how long it took to
+    // read the reply. For pipelines they cover the whole pipeline.
+    Start    time.Time
+    Duration time.Duration
+}
+
+// Name returns the upper-cased command name.
+func (cmd *Cmd) Name() string {
+    if len(cmd.Args) == 0 {
+        return ""
+    }
+
+    return strings.ToUpper(fmt.Sprint(cmd.Args[0]))
+}
+
+// DialEvent describes a new connection to Redis.
+type DialEvent struct {
+    Addr  string
+    Proto string
+    Db    int
+    Err   error
+
+    Start    time.Time
+    Duration time.Duration
+}
+
+// Hook observes or alters the commands of a Client. Hooks are added with
+// AddHook and called in the order they were added for Before methods and
+// in reverse order for After methods, like nested middleware.
+//
+// BeforeCall may short-circuit a command by setting cmd.Reply or cmd.Err.
+// The command is then not sent, the remaining BeforeCall hooks are
+// skipped, and Call returns the reply and error after the AfterCall hooks
+// have run. BeforePipeline and BeforeDial short-circuit the same way by
+// setting an Err.
+//
+// Embed NopHook to implement only some of the methods.
+type Hook interface {
+    BeforeCall(cmd *Cmd)
+    AfterCall(cmd *Cmd)
+    BeforePipeline(cmds []*Cmd)
+    AfterPipeline(cmds []*Cmd)
+    BeforeDial(ev *DialEvent)
+    AfterDial(ev *DialEvent)
+}
+
+// NopHook implements Hook with methods that do nothing.
+type NopHook struct{}
+
+func (NopHook) BeforeCall(cmd *Cmd)        {}
+func (NopHook) AfterCall(cmd *Cmd)         {}
+func (NopHook) BeforePipeline(cmds []*Cmd) {}
+func (NopHook) AfterPipeline(cmds []*Cmd)  {}
+func (NopHook) BeforeDial(ev *DialEvent)   {}
+func (NopHook) AfterDial(ev *DialEvent)    {}
+
+// AddHook appends h to the hook chain of c. Hooks must be added before the
+// client is used.
+func (c *Client) AddHook(h Hook) {
+    c.hooks = append(c.hooks, h)
+}
+
+// hookChain calls a list of hooks in order.
+type hookChain []Hook
+
+// beforeCall returns the number of hooks called and false if the command
+// was short-circuited.
+func (hc hookChain) beforeCall(cmd *Cmd) (int, bool) {
+    for i, h := range hc {
+        h.BeforeCall(cmd)
+
+        if cmd.Reply != nil || cmd.Err != nil {
+            return i + 1, false
+        }
+    }
+
+    return len(hc), true
+}
+
+// afterCall calls AfterCall on the first n hooks in reverse order.
+func (hc hookChain) afterCall(n int, cmd *Cmd) {
+    for i := n - 1; i >= 0; i-- {
+        hc[i].AfterCall(cmd)
+    }
+}
+
+func (hc hookChain) beforePipeline(cmds []*Cmd) (int, error) {
+    for i, h := range hc {
+        h.BeforePipeline(cmds)
+
+        for _, cmd := range cmds {
+            if cmd.Err != nil {
+                return i + 1, cmd.Err
+            }
+        }
+    }
+
+    return len(hc), nil
+}
+
+func (hc hookChain) afterPipeline(n int, cmds []*Cmd) {
+    for i := n - 1; i >= 0; i-- {
+        hc[i].AfterPipeline(cmds)
+    }
+}
+
+func (hc hookChain) beforeDial(ev *DialEvent) int {
+    for i, h := range hc {
+        h.BeforeDial(ev)
+
+        if ev.Err != nil {
+            return i + 1
+        }
+    }
+
+    return len(hc)
+}
+
+func (hc hookChain) afterDial(n int, ev *DialEvent) {
+    for i := n - 1; i >= 0; i-- {
+        hc[i].AfterDial(ev)
+    }
+}
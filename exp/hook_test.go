@@ -0,0 +1,197 @@
+package redis
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+
+    "insmo.com/godis/redistest"
+)
+
+// traceHook records the hook calls in a shared log.
+type traceHook struct {
+    name string
+    log  *[]string
+    cmds []*Cmd
+}
+
+func (h *traceHook) BeforeCall(cmd *Cmd) {
+    *h.log = append(*h.log, h.name+" before "+cmd.Name())
+}
+
+func (h *traceHook) AfterCall(cmd *Cmd) {
+    *h.log = append(*h.log, h.name+" after "+cmd.Name())
+    h.cmds = append(h.cmds, cmd)
+}
+
+func (h *traceHook) BeforePipeline(cmds []*Cmd) {
+    *h.log = append(*h.log, h.name+" before pipeline")
+}
+
+func (h *traceHook) AfterPipeline(cmds []*Cmd) {
+    *h.log = append(*h.log, h.name+" after pipeline")
+    h.cmds = append(h.cmds, cmds...)
+}
+
+func (h *traceHook) BeforeDial(ev *DialEvent) {
+    *h.log = append(*h.log, h.name+" before dial")
+}
+
+func (h *traceHook) AfterDial(ev *DialEvent) {
+    *h.log = append(*h.log, h.name+" after dial")
+}
+
+// shortHook answers GET short from memory and refuses DEL.
+type shortHook struct {
+    NopHook
+}
+
+func (shortHook) BeforeCall(cmd *Cmd) {
+    switch {
+    case cmd.Name() == "GET" && cmd.Args[1] == "short":
+        cmd.Reply = &Reply{Elem: Elem("cached")}
+    case cmd.Name() == "DEL":
+        cmd.Err = errors.New("DEL is not allowed")
+    }
+}
+
+func TestHookChain(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    var log []string
+    outer := &traceHook{name: "outer", log: &log}
+    c := NewClient(s.NetAddr(), 0, "")
+    c.AddHook(outer)
+    c.AddHook(&traceHook{name: "inner", log: &log})
+
+    if _, e := c.Call("SET", "foo", "bar"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    expected := []string{
+        "outer before SET",
+        "inner before SET",
+        "outer before dial",
+        "inner before dial",
+        "inner after dial",
+        "outer after dial",
+        "inner after SET",
+        "outer after SET",
+    }
+
+    if !reflect.DeepEqual(log, expected) {
+        t.Errorf("expected %q got %q", expected, log)
+    }
+
+    cmd := outer.cmds[0]
+
+    if cmd.Err != nil || cmd.Reply.Elem.String() != "OK" || cmd.Duration <= 0 || cmd.Start.IsZero() {
+        t.Errorf("unexpected cmd %+v", cmd)
+    }
+}
+
+func TestHookShortCircuit(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    var log []string
+    trace := &traceHook{name: "trace", log: &log}
+    c := NewClient(s.NetAddr(), 0, "")
+    c.AddHook(trace)
+    c.AddHook(shortHook{})
+    c.AddHook(&traceHook{name: "skipped", log: &log})
+
+    if r, e := c.Call("GET", "short"); e != nil || r.Elem.String() != "cached" {
+        t.Errorf("expected cached reply got %v, %v", r, e)
+    }
+
+    if _, e := c.Call("DEL", "foo"); e == nil || e.Error() != "DEL is not allowed" {
+        t.Errorf("expected error got %v", e)
+    }
+
+    expected := []string{"trace before GET", "trace after GET", "trace before DEL", "trace after DEL"}
+
+    if !reflect.DeepEqual(log, expected) {
+        t.Errorf("expected %q got %q", expected, log)
+    }
+
+    if len(trace.cmds) != 2 || !trace.cmds[0].Start.IsZero() {
+        t.Errorf("expected short-circuited commands got %+v", trace.cmds)
+    }
+}
+
+func TestHookPipeline(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    var log []string
+    trace := &traceHook{name: "trace", log: &log}
+    c := NewClient(s.NetAddr(), 0, "")
+    c.AddHook(trace)
+
+    ac := c.AsyncClient()
+    defer ac.Close()
+    ac.Call("SET", "foo", "bar")
+    ac.Call("GET", "foo")
+    ac.Call("INCR", "foo")
+
+    if _, e := ac.ReadAll(); e == nil {
+        t.Errorf("expected error for INCR")
+    }
+
+    expected := []string{"trace before pipeline", "trace before dial", "trace after dial", "trace after pipeline"}
+
+    if !reflect.DeepEqual(log, expected) {
+        t.Errorf("expected %q got %q", expected, log)
+    }
+
+    if len(trace.cmds) != 3 {
+        t.Fatalf("expected 3 commands got %d", len(trace.cmds))
+    }
+
+    if r := trace.cmds[1].Reply; r == nil || r.Elem.String() != "bar" {
+        t.Errorf("expected bar got %v", r)
+    }
+
+    if trace.cmds[2].Err == nil || trace.cmds[2].Reply != nil {
+        t.Errorf("expected error got %+v", trace.cmds[2])
+    }
+
+    if ac.Queued() != 0 || len(ac.cmds) != 0 {
+        t.Errorf("expected empty pipeline got %d queued", ac.Queued())
+    }
+}
+
+// refuseHook refuses to dial and to send pipelines.
+type refuseHook struct {
+    NopHook
+}
+
+func (refuseHook) BeforePipeline(cmds []*Cmd) {
+    cmds[0].Err = errors.New("refused")
+}
+
+func (refuseHook) BeforeDial(ev *DialEvent) {
+    ev.Err = errors.New("refused")
+}
+
+func TestHookRefuse(t *testing.T) {
+    c := NewClient("tcp:127.0.0.1:1", 0, "")
+    c.AddHook(refuseHook{})
+
+    if _, e := c.Call("PING"); e == nil || e.Error() != "refused" {
+        t.Errorf("expected refused dial got %v", e)
+    }
+
+    ac := c.AsyncClient()
+    ac.Call("PING")
+
+    if _, e := ac.ReadAll(); e == nil || e.Error() != "refused" {
+        t.Errorf("expected refused pipeline got %v", e)
+    }
+
+    if ac.Queued() != 0 || ac.buf.Len() != 0 {
+        t.Errorf("expected discarded pipeline")
+    }
+}
@@ -0,0 +1,149 @@
+package redis
+
+import (
+    "bytes"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "testing"
+
+    "insmo.com/godis/redistest"
+)
+
+type bufLogger struct {
+    mu   sync.Mutex
+    msgs []string
+}
+
+func (b *bufLogger) Log(level Level, msg string) {
+    b.mu.Lock()
+    b.msgs = append(b.msgs, level.String()+" "+msg)
+    b.mu.Unlock()
+}
+
+func (b *bufLogger) reset() []string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    msgs := b.msgs
+    b.msgs = nil
+    return msgs
+}
+
+func TestRedactCommand(t *testing.T) {
+    tests := []struct {
+        args []interface{}
+        out  string
+    }{
+        {[]interface{}{"get", "foo"}, `GET "foo"`},
+        {[]interface{}{"SET", "foo", strings.Repeat("x", 11)}, `SET "foo" (11 bytes)`},
+        {[]interface{}{"INCRBY", "n", 10}, `INCRBY "n" "10"`},
+        {[]interface{}{"AUTH", "secret"}, `AUTH (redacted)`},
+        {[]interface{}{"AUTH", "user", "secret"}, `AUTH (redacted) (redacted)`},
+        {[]interface{}{"HELLO", 3, "AUTH", "user", "secret"}, `HELLO "3" "AUTH" (redacted) (redacted)`},
+    }
+
+    for _, test := range tests {
+        if out := redactCommand(test.args, 10); out != test.out {
+            t.Errorf("%v: expected %s got %s", test.args, test.out, out)
+        }
+    }
+}
+
+func TestLogger(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    b := &bufLogger{}
+    c := NewClient(s.NetAddr(), 0, "")
+    c.SetLogger(LogConfig{Logger: b, Level: LevelInfo, MaxArgLen: 8})
+
+    if _, e := c.Call("SET", "foo", "bar"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if msgs := b.reset(); len(msgs) != 1 || !strings.HasPrefix(msgs[0], "INFO connected to tcp:") {
+        t.Errorf("expected connect message got %q", msgs)
+    }
+
+    c.SetLogLevel(LevelDebug)
+    c.Call("SET", "foo", "a long value")
+    c.Call("MGET", "foo", "bar")
+    c.Call("INCR", "foo")
+
+    expected := []string{
+        `DEBUG command SET "foo" (12 bytes)`,
+        `DEBUG reply "OK"`,
+        `DEBUG command MGET "foo" "bar"`,
+        `DEBUG reply [(12 bytes), nil]`,
+        `DEBUG command INCR "foo"`,
+        `DEBUG reply -ERR value is not an integer or out of range`,
+    }
+
+    var msgs []string
+
+    // every call dials a new connection until the pool is filled
+    for _, msg := range b.reset() {
+        if strings.HasPrefix(msg, "DEBUG ") {
+            msgs = append(msgs, msg)
+        }
+    }
+
+    if strings.Join(msgs, "\n") != strings.Join(expected, "\n") {
+        t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(msgs, "\n"))
+    }
+
+    c.SetLogLevel(LevelOff)
+    c.Call("GET", "foo")
+
+    if msgs := b.reset(); len(msgs) != 0 {
+        t.Errorf("expected no messages got %q", msgs)
+    }
+}
+
+func TestLoggerAuth(t *testing.T) {
+    s := redistest.NewServer()
+    s.Password = "secret"
+    defer s.Close()
+
+    b := &bufLogger{}
+    c := NewClient(s.NetAddr(), 0, "secret")
+    c.SetLogger(LogConfig{Logger: b, Level: LevelDebug})
+    c.Call("PING")
+
+    for _, msg := range b.reset() {
+        if strings.Contains(msg, "secret") {
+            t.Errorf("password logged: %s", msg)
+        }
+    }
+}
+
+func TestLoggerNil(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    var buf bytes.Buffer
+    log.SetOutput(&buf)
+    defer log.SetOutput(os.Stderr)
+
+    // a nil logger is the standard logger
+    c := NewClient(s.NetAddr(), 0, "")
+    c.SetLogger(LogConfig{Level: LevelDebug})
+    c.Call("PING")
+
+    if !strings.Contains(buf.String(), "godis DEBUG: command PING") {
+        t.Errorf("expected the command in the standard log got %q", buf.String())
+    }
+}
+
+func TestParseLevel(t *testing.T) {
+    for _, l := range []Level{LevelOff, LevelError, LevelWarn, LevelInfo, LevelDebug} {
+        if p, ok := ParseLevel(strings.ToLower(l.String())); !ok || p != l {
+            t.Errorf("%s: got %s", l, p)
+        }
+    }
+
+    if _, ok := ParseLevel("verbose"); ok {
+        t.Errorf("expected unknown level")
+    }
+}
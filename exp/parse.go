@@ -3,111 +3,214 @@ package redis
 import (
     "errors"
     "io"
-    "log"
     "strconv"
 
     "insmo.com/godis/bufin"
 )
 
 var (
-    debug       = false
     ErrProtocol = errors.New("godis: protocol error")
+
+    // ErrNilMultiBulk is returned for a nil multi-bulk reply, e.g. by EXEC
+    // when a watched key was modified.
+    ErrNilMultiBulk = errors.New("-MULTI-BULK: nil reply")
+)
+
+// Limits of the replies accepted by Parse, they protect a client from
+// broken or hostile servers and proxies. Larger replies fail with
+// ErrProtocol.
+var (
+    // MaxBulkSize is the largest bulk string in bytes. Redis limits bulk
+    // strings to 512MB by default.
+    MaxBulkSize = 512 << 20
+
+    // MaxArraySize is the largest number of elements of an aggregate
+    // reply, the keys and values of a map both count.
+    MaxArraySize = 1 << 24
+
+    // MaxDepth is the deepest nesting of aggregate replies.
+    MaxDepth = 32
+)
+
+const (
+    // buffers are allocated up to these sizes before the data arrives,
+    // larger replies grow them while reading
+    bulkChunk = 64 << 10
+    elemChunk = 1024
 )
 
 func (r *Reply) parseErr(res []byte) {
     r.Err = errors.New(string(res))
-
-    if debug {
-        log.Println("-ERR: " + string(res))
-    }
 }
 
 func (r *Reply) parseStr(res []byte) {
     b := make([]byte, len(res))
     copy(b, res)
     r.Elem = b
-
-    if debug {
-        log.Println("-STR: " + string(res))
-    }
 }
 
 func (r *Reply) parseInt(res []byte) {
     b := make([]byte, len(res))
     copy(b, res)
     r.Elem = b
+}
 
-    if debug {
-        log.Println("-INT: " + string(res))
-    }
+// fail marks r as unreadable. The connection is out of sync with the
+// server afterwards and must not be reused.
+func (r *Reply) fail(err error) {
+    r.Err = err
+    r.typ = 0
 }
 
-func (r *Reply) parseBulk(buf *bufin.Reader, res []byte) {
+// parseLen parses the length of a bulk or aggregate reply, -1 is returned
+// for nil replies.
+func parseLen(res []byte) (int, error) {
     l, e := strconv.Atoi(string(res))
 
-    if e != nil {
-        // TODO: handle error
+    if e != nil || l < -1 {
+        return 0, ErrProtocol
     }
 
-    if l == -1 {
-        if debug {
-            log.Println("-BULK: Key does not exist")
+    return l, nil
+}
+
+// readBulk reads l bytes of data and the trailing CRLF. The buffer grows
+// while the data arrives, so a bogus length does not allocate up front.
+func readBulk(buf *bufin.Reader, l int) ([]byte, error) {
+    n := l + 2
+    size := n
+
+    if size > bulkChunk {
+        size = bulkChunk
+    }
+
+    data := make([]byte, 0, size)
+
+    for len(data) < n {
+        if len(data) == cap(data) {
+            size = 2 * cap(data)
+
+            if size > n {
+                size = n
+            }
+
+            grown := make([]byte, len(data), size)
+            copy(grown, data)
+            data = grown
         }
 
-        return
+        m, e := io.ReadFull(buf, data[len(data):cap(data)])
+        data = data[:len(data)+m]
+
+        if e != nil {
+            return nil, e
+        }
+    }
+
+    if data[l] != cr || data[l+1] != lf {
+        return nil, ErrProtocol
     }
 
-    l += 2 // make sure to read \r\n
-    data := make([]byte, l)
-    n, err := io.ReadFull(buf, data)
+    return data[:l], nil
+}
+
+func (r *Reply) parseBulk(buf *bufin.Reader, res []byte) {
+    l, e := parseLen(res)
 
-    // if we were unable to read all data from socket
-    if n != l && err == nil {
-        // TODO: handle error
+    if e == nil && l > MaxBulkSize {
+        e = ErrProtocol
     }
 
-    if err != nil {
-        r.Err = err
+    if e != nil {
+        r.fail(e)
         return
     }
 
-    l -= 2
-    r.Elem = data[:l]
+    if l == -1 {
+        return
+    }
 
-    if debug {
-        log.Printf("-BULK: read %d byte, bulk-data %q\n", l, data)
+    if r.Elem, e = readBulk(buf, l); e != nil {
+        r.fail(e)
     }
 }
 
-func (r *Reply) parseMultiBulk(buf *bufin.Reader, res []byte) {
-    l, _ := strconv.Atoi(string(res))
+// parseMultiBulk reads l*width replies. Maps are read with a width of 2, so
+// keys and values end up next to each other in Elems.
+func (r *Reply) parseMultiBulk(buf *bufin.Reader, res []byte, width, depth int) {
+    l, e := parseLen(res)
+
+    if e == nil && (l > MaxArraySize/width || depth >= MaxDepth) {
+        e = ErrProtocol
+    }
+
+    if e != nil {
+        r.fail(e)
+        return
+    }
 
     if l == -1 {
-        r.Err = errors.New("-MULTI-BULK: nil reply")
+        r.Err = ErrNilMultiBulk
         return
     }
 
-    r.Elems = make([]*Reply, l)
+    l *= width
+    size := l
+
+    if size > elemChunk {
+        size = elemChunk
+    }
+
+    r.Elems = make([]*Reply, 0, size)
 
     for i := 0; i < l; i++ {
-        rr := Parse(buf)
+        rr := parse(buf, depth+1)
+
+        if rr.typ == 0 {
+            r.fail(rr.Err)
+            return
+        }
 
         if rr.Err != nil {
             r.Err = rr.Err
         }
 
-        r.Elems[i] = rr
+        r.Elems = append(r.Elems, rr)
     }
+}
+
+// parseVerbatim reads a RESP3 verbatim string and strips the format prefix,
+// e.g. `txt:`.
+func (r *Reply) parseVerbatim(buf *bufin.Reader, res []byte) {
+    r.parseBulk(buf, res)
+
+    if len(r.Elem) >= 4 {
+        r.Elem = r.Elem[4:]
+    }
+}
 
-    // buffer is reduced to account for `nil` value returns
-    r.Elems = r.Elems[:l]
+// parseBulkErr reads a RESP3 blob error.
+func (r *Reply) parseBulkErr(buf *bufin.Reader, res []byte) {
+    r.parseBulk(buf, res)
 
-    if debug {
-        //log.Printf(": %d == %d %q\n", l, len(r.Elems), r.Elems)
+    if r.Err == nil {
+        r.parseErr(r.Elem)
+        r.Elem = nil
     }
 }
 
+// Parse reads one reply from buf. Both RESP2 and RESP3 replies are
+// understood. RESP3 maps, sets and push messages are returned as Elems,
+// simple RESP3 types such as booleans and doubles as Elem.
+//
+// Malformed replies and replies exceeding MaxBulkSize, MaxArraySize or
+// MaxDepth fail with ErrProtocol. A reply which could not be read has no
+// Type and leaves buf out of sync with the server.
 func Parse(buf *bufin.Reader) *Reply {
+    return parse(buf, 0)
+}
+
+func parse(buf *bufin.Reader, depth int) *Reply {
     r := new(Reply)
     res, err := buf.ReadSlice(lf)
 
@@ -116,8 +219,15 @@ func Parse(buf *bufin.Reader) *Reply {
         return r
     }
 
+    // a type byte and CRLF at least
+    if len(res) < 3 || res[len(res)-2] != cr {
+        r.Err = ErrProtocol
+        return r
+    }
+
     typ := res[0]
     line := res[1 : len(res)-2]
+    r.typ = typ
 
     switch typ {
     case minus:
@@ -128,10 +238,31 @@ func Parse(buf *bufin.Reader) *Reply {
         r.parseInt(line)
     case dollar:
         r.parseBulk(buf, line)
-    case star:
-        r.parseMultiBulk(buf, line)
+    case star, tilde, gt:
+        r.parseMultiBulk(buf, line, 1, depth)
+    case percent:
+        r.parseMultiBulk(buf, line, 2, depth)
+    case underscore:
+        // RESP3 null
+    case hash, comma, lparen:
+        r.parseStr(line)
+    case equals:
+        r.parseVerbatim(buf, line)
+    case bang:
+        r.parseBulkErr(buf, line)
+    case pipe:
+        // attributes are not exposed, skip them and read the actual reply.
+        // They count as nesting, so a stream of attributes cannot recurse
+        // forever.
+        r.parseMultiBulk(buf, line, 2, depth)
+
+        if r.typ == 0 {
+            return r
+        }
+
+        return parse(buf, depth+1)
     default:
-        r.Err = ErrProtocol
+        r.fail(ErrProtocol)
     }
 
     return r
@@ -0,0 +1,46 @@
+//go:build go1.18
+// +build go1.18
+
+package redis
+
+import (
+    "bytes"
+    "testing"
+
+    "insmo.com/godis/bufin"
+)
+
+// FuzzParse feeds arbitrary input to Parse, which must neither panic nor
+// hang; the fuzzer reports a worker which stops responding. Every call
+// consumes at least one byte until the input is exhausted, so the stream is
+// parsed to the end within len(data)+1 calls.
+//
+//      go test -run '^$' -fuzz FuzzParse ./exp
+func FuzzParse(f *testing.F) {
+    for _, test := range parseTests {
+        f.Add([]byte(test.in))
+    }
+
+    for _, s := range replyStreams {
+        if len(s.raw) < 4096 {
+            f.Add([]byte(s.raw))
+            f.Add([]byte(s.raw[:len(s.raw)/2]))
+        }
+    }
+
+    f.Add([]byte("*2\r\n:1\r\n"))
+    f.Add([]byte("$10\r\nfoo\r\n"))
+    f.Add([]byte("?\r\n"))
+
+    f.Fuzz(func(t *testing.T, data []byte) {
+        buf := bufin.NewReader(bytes.NewReader(data))
+
+        for n := 0; n <= len(data); n++ {
+            if r := Parse(buf); r.typ == 0 {
+                return
+            }
+        }
+
+        t.Fatalf("%q: parsed %d replies without reaching the end", data, len(data)+1)
+    })
+}
@@ -0,0 +1,221 @@
+package redis
+
+import (
+    "bytes"
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+
+    "insmo.com/godis/bufin"
+    "insmo.com/godis/redistest"
+)
+
+type parseTest struct {
+    in    string
+    elem  interface{}
+    elems []string
+}
+
+var parseTests = []parseTest{
+    {"+OK\r\n", "OK", nil},
+    {":1\r\n", "1", nil},
+    {"$3\r\nfoo\r\n", "foo", nil},
+    {"$-1\r\n", nil, nil},
+    {"*2\r\n$3\r\nfoo\r\n:1\r\n", nil, []string{"foo", "1"}},
+    {"_\r\n", nil, nil},
+    {"#t\r\n", "t", nil},
+    {",3.14\r\n", "3.14", nil},
+    {"(3492890328409238509324850943850943825024385\r\n", "3492890328409238509324850943850943825024385", nil},
+    {"=7\r\ntxt:foo\r\n", "foo", nil},
+    {"%2\r\n$3\r\nfoo\r\n:1\r\n$3\r\nbar\r\n:2\r\n", nil, []string{"foo", "1", "bar", "2"}},
+    {"~2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n", nil, []string{"foo", "bar"}},
+    {">2\r\n$10\r\ninvalidate\r\n$3\r\nfoo\r\n", nil, []string{"invalidate", "foo"}},
+    {"|1\r\n+ttl\r\n:3600\r\n$3\r\nfoo\r\n", "foo", nil},
+}
+
+func TestParse(t *testing.T) {
+    for _, test := range parseTests {
+        r := Parse(bufin.NewReader(bytes.NewBufferString(test.in)))
+
+        if r.Err != nil {
+            t.Errorf("%q: unexpected error %v", test.in, r.Err)
+            continue
+        }
+
+        if test.elem == nil && r.Elem != nil {
+            t.Errorf("%q: expected nil elem got %q", test.in, r.Elem.String())
+        } else if test.elem != nil && r.Elem.String() != test.elem {
+            t.Errorf("%q: expected %q got %q", test.in, test.elem, r.Elem.String())
+        }
+
+        if test.elems != nil && !reflect.DeepEqual(test.elems, r.StringArray()) {
+            t.Errorf("%q: expected %q got %q", test.in, test.elems, r.StringArray())
+        }
+    }
+}
+
+func TestParseBulkErr(t *testing.T) {
+    r := Parse(bufin.NewReader(bytes.NewBufferString("!21\r\nSYNTAX invalid syntax\r\n")))
+
+    if r.Err == nil || r.Err.Error() != "SYNTAX invalid syntax" {
+        t.Errorf("expected blob error got %v", r.Err)
+    }
+}
+
+func TestParseMalformed(t *testing.T) {
+    for _, in := range []string{
+        "\n",
+        "\r\n",
+        "+OK\n",
+        "$abc\r\nfoo\r\n",
+        "$-2\r\n",
+        "$3\r\nfoobar\r\n",
+        "$3\r\nfo\r\n\r\n",
+        "*abc\r\n",
+        "*-5\r\n",
+        "*2\r\n:1\r\n?\r\n",
+        "%1\r\n$1\r\nabc\r\n:1\r\n",
+        "?\r\n",
+    } {
+        r := Parse(bufin.NewReader(bytes.NewBufferString(in)))
+
+        if r.Err != ErrProtocol || r.typ != 0 {
+            t.Errorf("%q: expected ErrProtocol without type got %v, %q", in, r.Err, r.typ)
+        }
+    }
+
+    // a truncated bulk reply fails with the read error
+    if r := Parse(bufin.NewReader(bytes.NewBufferString("$500000000\r\nfoo"))); r.Err == nil || r.typ != 0 {
+        t.Errorf("expected a read error without type got %v", r.Err)
+    }
+}
+
+func TestParseLimits(t *testing.T) {
+    defer func(bulk, array, depth int) {
+        MaxBulkSize, MaxArraySize, MaxDepth = bulk, array, depth
+    }(MaxBulkSize, MaxArraySize, MaxDepth)
+
+    MaxBulkSize, MaxArraySize, MaxDepth = 3, 2, 2
+
+    tests := []struct {
+        in string
+        ok bool
+    }{
+        {"$3\r\nfoo\r\n", true},
+        {"$4\r\nfoob\r\n", false},
+        {"*2\r\n:1\r\n:2\r\n", true},
+        {"*3\r\n:1\r\n:2\r\n:3\r\n", false},
+        {"%1\r\n:1\r\n:2\r\n", true},
+        {"%2\r\n:1\r\n:2\r\n:3\r\n:4\r\n", false},
+        {"*1\r\n*1\r\n:1\r\n", true},
+        {"*1\r\n*1\r\n*1\r\n:1\r\n", false},
+        {"|1\r\n+ttl\r\n:1\r\n:1\r\n", true},
+        {"|1\r\n+a\r\n:1\r\n|1\r\n+b\r\n:1\r\n|1\r\n+c\r\n:1\r\n:1\r\n", false},
+    }
+
+    for _, test := range tests {
+        r := Parse(bufin.NewReader(bytes.NewBufferString(test.in)))
+
+        if ok := r.Err == nil; ok != test.ok || (!ok && r.Err != ErrProtocol) {
+            t.Errorf("%q: expected ok %v got %v", test.in, test.ok, r.Err)
+        }
+    }
+}
+
+func TestCallProtocolError(t *testing.T) {
+    m := redistest.NewMock(nil)
+    defer m.Close()
+
+    m.On("GET", "foo").Reply("*2\r\n$3\r\nbar\r\n$-2\r\n")
+    m.On("GET", "bar").Bulk("baz")
+    c := NewClient(m.NetAddr(), 0, "")
+
+    if _, e := c.Call("GET", "foo"); e != ErrProtocol {
+        t.Errorf("expected ErrProtocol got %v", e)
+    }
+
+    if st := c.PoolStats(); st.Idle != 0 || st.InUse != 0 {
+        t.Errorf("expected connection to be closed got %+v", st)
+    }
+
+    if r, e := c.Call("GET", "bar"); e != nil || r.Elem.String() != "baz" {
+        t.Errorf("expected baz got %v, %v", r, e)
+    }
+}
+
+// loopReader replays a recorded stream forever, so a single bufin.Reader
+// can be parsed from b.N times without touching a socket.
+type loopReader struct {
+    data []byte
+    off  int
+}
+
+func (l *loopReader) Read(p []byte) (int, error) {
+    n := copy(p, l.data[l.off:])
+    l.off = (l.off + n) % len(l.data)
+    return n, nil
+}
+
+func bulk(n int) string {
+    return "$" + strconv.Itoa(n) + "\r\n" + strings.Repeat("x", n) + "\r\n"
+}
+
+func aggregate(typ string, n int, elem string) string {
+    return typ + strconv.Itoa(n) + "\r\n" + strings.Repeat(elem, n)
+}
+
+// replyStreams are replies as sent by Redis, covering every reply type and
+// a range of sizes.
+var replyStreams = []struct {
+    name string
+    raw  string
+}{
+    {"status", "+OK\r\n"},
+    {"error", "-ERR unknown command 'FOO'\r\n"},
+    {"int", ":1234567\r\n"},
+    {"nil", "$-1\r\n"},
+    {"bulk/16", bulk(16)},
+    {"bulk/1K", bulk(1 << 10)},
+    {"bulk/64K", bulk(64 << 10)},
+    {"array/10", aggregate("*", 10, bulk(16))},
+    {"array/1000", aggregate("*", 1000, bulk(16))},
+    {"nested/10x10", aggregate("*", 10, aggregate("*", 10, ":1\r\n"))},
+    {"map/100", aggregate("%", 100, bulk(8)+":1\r\n")},
+    {"set/100", aggregate("~", 100, bulk(8))},
+    {"push", ">3\r\n$7\r\nmessage\r\n$4\r\nchan\r\n" + bulk(64)},
+    {"null", "_\r\n"},
+    {"bool", "#t\r\n"},
+    {"double", ",3.141592653589793\r\n"},
+    {"bignum", "(3492890328409238509324850943850943825024385\r\n"},
+    {"verbatim/1K", "=1028\r\ntxt:" + strings.Repeat("x", 1024) + "\r\n"},
+    {"bulkerr", "!21\r\nSYNTAX invalid syntax\r\n"},
+    {"attribute", "|1\r\n+ttl\r\n:3600\r\n" + bulk(16)},
+}
+
+func TestReplyStreams(t *testing.T) {
+    for _, s := range replyStreams {
+        buf := bufin.NewReader(&loopReader{data: []byte(s.raw)})
+
+        for i := 0; i < 3; i++ {
+            if r := Parse(buf); r.typ == 0 || (r.Err != nil && s.name != "error" && s.name != "bulkerr") {
+                t.Fatalf("%s: read %d failed: %v", s.name, i, r.Err)
+            }
+        }
+    }
+}
+
+func BenchmarkParse(b *testing.B) {
+    for _, s := range replyStreams {
+        b.Run(s.name, func(b *testing.B) {
+            buf := bufin.NewReader(&loopReader{data: []byte(s.raw)})
+            b.SetBytes(int64(len(s.raw)))
+            b.ReportAllocs()
+            b.ResetTimer()
+
+            for i := 0; i < b.N; i++ {
+                Parse(buf)
+            }
+        })
+    }
+}
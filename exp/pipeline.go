@@ -0,0 +1,192 @@
+package redis
+
+import (
+    "bytes"
+    "errors"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// ErrReplyCount is returned by Pipeline.Exec when Redis sent more or fewer
+// replies than commands were queued. The connection is closed, as the
+// replies of later commands can no longer be matched to them.
+var ErrReplyCount = errors.New("godis: reply count does not match queued commands")
+
+// markPrefix starts the argument of the ECHO sent after the commands of an
+// Exec, see Pipeline.Exec.
+const markPrefix = "godis:pipeline:"
+
+var marks int64
+
+// Pipeline queues commands and sends them in one write with Exec. Unlike
+// the AsyncClient it is safe to share between go routines: every call to
+// Exec sends the commands queued since the previous Exec on a connection
+// from the pool of the Client.
+type Pipeline struct {
+    *Client
+    mu      sync.Mutex
+    buf     *bytes.Buffer
+    futures []*Future
+
+    // queued commands, only kept for the pipeline hooks
+    cmds []*Cmd
+}
+
+// NewPipeline expects a addr like "tcp:127.0.0.1:6379"
+// It returns a new *Pipeline.
+func NewPipeline(addr string, db int, password string) *Pipeline {
+    return NewClient(addr, db, password).Pipeline()
+}
+
+// Use the connection settings and pool from Client to create a Pipeline
+func (c *Client) Pipeline() *Pipeline {
+    return &Pipeline{Client: c, buf: new(bytes.Buffer)}
+}
+
+// Queue appends a command to the pipeline and returns a future for its
+// reply.
+func (p *Pipeline) Queue(args ...interface{}) *Future {
+    f := newFuture()
+    b := format(args...)
+    atomic.AddInt64(&p.stats.commands, 1)
+    p.log.command(args)
+
+    p.mu.Lock()
+    p.buf.Write(b)
+    p.futures = append(p.futures, f)
+
+    if len(p.hooks) > 0 {
+        p.cmds = append(p.cmds, &Cmd{Args: args})
+    }
+
+    p.mu.Unlock()
+    return f
+}
+
+// Queued returns the number of commands waiting for Exec.
+func (p *Pipeline) Queued() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return len(p.futures)
+}
+
+// Exec sends the queued commands and resolves their futures. Error replies
+// only fail their own future, Exec returns an error if the commands could
+// not be sent or the replies not be read. The futures which were not
+// resolved by a reply get the same error.
+//
+// The commands are followed by an ECHO of a mark unique to the Exec, so the
+// number of replies is checked without waiting on a timeout. Exec returns
+// ErrReplyCount if the mark is the reply of a queued command, as a command
+// sent no reply, or if the reply after the last command is not the mark, as
+// a command sent more than one reply, e.g. SUBSCRIBE of several channels or
+// a MULTI without EXEC. A command without a reply and another one with an
+// extra reply in the same Exec are not detected. Commands which turn replies
+// off, CLIENT REPLY OFF, are not supported: Exec blocks waiting for the mark.
+func (p *Pipeline) Exec() error {
+    p.mu.Lock()
+    buf, futures, cmds := p.buf, p.futures, p.cmds
+    p.buf, p.futures, p.cmds = new(bytes.Buffer), nil, nil
+    p.mu.Unlock()
+
+    if len(futures) == 0 {
+        return nil
+    }
+
+    if len(p.hooks) == 0 {
+        return p.exec(buf, futures)
+    }
+
+    n, e := p.hooks.beforePipeline(cmds)
+
+    if e != nil {
+        for _, f := range futures {
+            f.resolve(nil, e)
+        }
+    } else {
+        start := time.Now()
+        e = p.exec(buf, futures)
+        d := time.Since(start)
+
+        for i, cmd := range cmds {
+            cmd.Start, cmd.Duration = start, d
+            cmd.Reply, cmd.Err = futures[i].Reply()
+        }
+    }
+
+    p.hooks.afterPipeline(n, cmds)
+    return e
+}
+
+func (p *Pipeline) exec(buf *bytes.Buffer, futures []*Future) (err error) {
+    conn, err := p.connect()
+
+    defer func() {
+        if err != nil && conn != nil {
+            conn.Close()
+            conn = nil
+        }
+
+        p.pool.push(conn)
+    }()
+
+    if err != nil {
+        return fail(futures, err)
+    }
+
+    mark := markPrefix + strconv.FormatInt(atomic.AddInt64(&marks, 1), 10)
+    buf.Write(format("ECHO", mark))
+
+    if _, err = buf.WriteTo(conn.Sock()); err != nil {
+        return fail(futures, err)
+    }
+
+    c := conn.(*Conn)
+
+    for i, f := range futures {
+        r := c.readReply()
+
+        if r.typ == 0 {
+            return fail(futures[i:], r.Err)
+        }
+
+        if isMark(r, mark) {
+            return fail(futures[i:], ErrReplyCount)
+        }
+
+        if r.Err != nil {
+            f.resolve(nil, r.Err)
+        } else {
+            f.resolve(r, nil)
+        }
+    }
+
+    // the mark is not counted in the reply stats
+    r := Parse(c.rbuf)
+
+    if r.typ == 0 {
+        return r.Err
+    }
+
+    if !isMark(r, mark) {
+        return ErrReplyCount
+    }
+
+    return nil
+}
+
+// isMark returns whether r is the reply to the ECHO of mark.
+func isMark(r *Reply, mark string) bool {
+    return r.Err == nil && r.Elem != nil && r.Elem.String() == mark
+}
+
+// fail resolves futures with err and returns it.
+func fail(futures []*Future, err error) error {
+    for _, f := range futures {
+        f.resolve(nil, err)
+    }
+
+    return err
+}
@@ -0,0 +1,220 @@
+package redis
+
+import (
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+
+    "insmo.com/godis/redistest"
+)
+
+func TestPipeline(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    p := NewClient(s.NetAddr(), 0, "").Pipeline()
+    set := p.Queue("SET", "foo", 1)
+    incr := p.Queue("INCR", "foo")
+    bad := p.Queue("LPUSH", "foo", "bar")
+    get := p.Queue("GET", "foo")
+
+    if n := p.Queued(); n != 4 {
+        t.Errorf("expected 4 queued got %d", n)
+    }
+
+    if e := p.Exec(); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if r, e := set.Reply(); e != nil || r.Elem.String() != "OK" {
+        t.Errorf("SET: expected OK got %v, %v", r, e)
+    }
+
+    if r, e := incr.Reply(); e != nil || r.Elem.Int() != 2 {
+        t.Errorf("INCR: expected 2 got %v, %v", r, e)
+    }
+
+    if _, e := bad.Reply(); e == nil {
+        t.Errorf("LPUSH: expected WRONGTYPE error")
+    }
+
+    if r, e := get.Reply(); e != nil || r.Elem.String() != "2" {
+        t.Errorf("GET: expected 2 got %v, %v", r, e)
+    }
+
+    if n := p.Queued(); n != 0 {
+        t.Errorf("expected 0 queued got %d", n)
+    }
+
+    if e := p.Exec(); e != nil {
+        t.Errorf("empty Exec: %s", e)
+    }
+}
+
+func TestPipelineConcurrent(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    p := NewClient(s.NetAddr(), 0, "").Pipeline()
+    var wg sync.WaitGroup
+
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+
+        go func(i int) {
+            defer wg.Done()
+            key := "key" + strconv.Itoa(i)
+            futures := make([]*Future, 20)
+
+            for j := range futures {
+                futures[j] = p.Queue("INCR", key)
+            }
+
+            if e := p.Exec(); e != nil {
+                t.Error(e.Error())
+                return
+            }
+
+            // the commands may be sent by the Exec of another go routine,
+            // so only wait for the futures and check every reply is seen
+            seen := make(map[int]bool)
+
+            for _, f := range futures {
+                r, e := f.Reply()
+
+                if e != nil {
+                    t.Error(e.Error())
+                    return
+                }
+
+                seen[r.Elem.Int()] = true
+            }
+
+            for j := 1; j <= len(futures); j++ {
+                if !seen[j] {
+                    t.Errorf("%s: missing reply %d", key, j)
+                }
+            }
+        }(i)
+    }
+
+    wg.Wait()
+}
+
+func TestPipelineReplyCount(t *testing.T) {
+    m := redistest.NewMock(nil)
+    defer m.Close()
+
+    m.On("GET", "foo").Reply("$3\r\nbar\r\n$3\r\nbaz\r\n")
+    c := NewClient(m.NetAddr(), 0, "")
+    p := c.Pipeline()
+    get := p.Queue("GET", "foo")
+
+    if e := p.Exec(); e != ErrReplyCount {
+        t.Errorf("expected ErrReplyCount got %v", e)
+    }
+
+    if r, e := get.Reply(); e != nil || r.Elem.String() != "bar" {
+        t.Errorf("expected bar got %v, %v", r, e)
+    }
+
+    if st := c.PoolStats(); st.Idle != 0 || st.InUse != 0 {
+        t.Errorf("expected connection to be closed got %+v", st)
+    }
+}
+
+func TestPipelineShortReplies(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+    m := redistest.NewMock(s)
+    defer m.Close()
+
+    // GET foo sends no reply, the mark is read in place of the reply of
+    // DEL
+    m.On("GET", "foo").Reply("")
+    c := NewClient(m.NetAddr(), 0, "")
+    p := c.Pipeline()
+    set := p.Queue("SET", "foo", "bar")
+    get := p.Queue("GET", "foo")
+    del := p.Queue("DEL", "foo")
+    done := make(chan error, 1)
+
+    go func() {
+        done <- p.Exec()
+    }()
+
+    select {
+    case e := <-done:
+        if e != ErrReplyCount {
+            t.Errorf("expected ErrReplyCount got %v", e)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("Exec blocked on a missing reply")
+    }
+
+    if _, e := set.Reply(); e != nil {
+        t.Errorf("SET: %s", e)
+    }
+
+    if _, e := del.Reply(); e != ErrReplyCount {
+        t.Errorf("DEL: expected ErrReplyCount got %v", e)
+    }
+
+    // the reply of DEL is taken for the reply of GET
+    if r, e := get.Reply(); e != nil || r.Elem.Int() != 1 {
+        t.Errorf("GET: expected the reply of DEL got %v, %v", r, e)
+    }
+
+    if st := c.PoolStats(); st.Idle != 0 || st.InUse != 0 {
+        t.Errorf("expected connection to be closed got %+v", st)
+    }
+}
+
+func TestPipelineReset(t *testing.T) {
+    m := redistest.NewMock(nil)
+    defer m.Close()
+
+    m.On("SET").Status("OK")
+    m.On("GET").Reset()
+    p := NewClient(m.NetAddr(), 0, "").Pipeline()
+    set := p.Queue("SET", "foo", "bar")
+    get := p.Queue("GET", "foo")
+    del := p.Queue("DEL", "foo")
+
+    if e := p.Exec(); e == nil {
+        t.Fatal("expected read error")
+    }
+
+    if _, e := set.Reply(); e != nil {
+        t.Errorf("SET: %s", e)
+    }
+
+    for _, f := range []*Future{get, del} {
+        if _, e := f.Reply(); e == nil {
+            t.Errorf("expected read error")
+        }
+    }
+}
+
+func TestAsyncClientQueued(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    ac := NewAsyncClient(s.NetAddr(), 0, "")
+    defer ac.Close()
+    ac.Call("SUBSCRIBE", "ch")
+    ac.Read()
+
+    if r, e := NewClient(s.NetAddr(), 0, "").Call("PUBLISH", "ch", "hello"); e != nil || r.Elem.Int() != 1 {
+        t.Fatalf("expected 1 subscriber got %v, %v", r, e)
+    }
+
+    if r, _ := ac.Read(); r.Message() == nil {
+        t.Errorf("expected message got %v", r)
+    }
+
+    if n := ac.Queued(); n != 0 {
+        t.Errorf("expected 0 queued got %d", n)
+    }
+}
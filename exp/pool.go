@@ -1,13 +1,36 @@
 package redis
 
+import (
+    "sync/atomic"
+)
+
 var MaxConnections = 50
 
 type connPool struct {
+    // updated atomically, first in the struct for 64-bit alignment
+    inUse int64
+    idle  int64
+    waits int64
+
     free chan Connection
 }
 
+// PoolStats describes the state of the connection pool of a Client.
+type PoolStats struct {
+    // InUse is the number of connections popped from the pool, including
+    // connections which are being dialed.
+    InUse int64
+
+    // Idle is the number of open connections in the pool.
+    Idle int64
+
+    // Waits is the number of times a caller had to wait for a connection
+    // because MaxConnections were in use.
+    Waits int64
+}
+
 func newConnPool() *connPool {
-    p := connPool{make(chan Connection, MaxConnections)}
+    p := connPool{free: make(chan Connection, MaxConnections)}
 
     for i := 0; i < MaxConnections; i++ {
         p.free <- nil
@@ -16,10 +39,42 @@ func newConnPool() *connPool {
     return &p
 }
 
-func (p *connPool) pop() Connection {
-    return <-p.free
+func (p *connPool) pop() (c Connection) {
+    select {
+    case c = <-p.free:
+    default:
+        atomic.AddInt64(&p.waits, 1)
+        c = <-p.free
+    }
+
+    atomic.AddInt64(&p.inUse, 1)
+
+    if c != nil {
+        atomic.AddInt64(&p.idle, -1)
+    }
+
+    return c
 }
 
 func (p *connPool) push(c Connection) {
+    atomic.AddInt64(&p.inUse, -1)
+
+    if c != nil {
+        atomic.AddInt64(&p.idle, 1)
+    }
+
     p.free <- c
 }
+
+func (p *connPool) stats() PoolStats {
+    return PoolStats{
+        InUse: atomic.LoadInt64(&p.inUse),
+        Idle:  atomic.LoadInt64(&p.idle),
+        Waits: atomic.LoadInt64(&p.waits),
+    }
+}
+
+// PoolStats returns the current state of the connection pool.
+func (c *Client) PoolStats() PoolStats {
+    return c.pool.stats()
+}
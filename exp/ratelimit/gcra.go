@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+    "insmo.com/godis/exp"
+)
+
+// KEYS[1] holds the theoretical arrival time (tat) in milliseconds.
+// ARGV: rate, burst, period, now, cost
+var gcraScript = newScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local cost = tonumber(ARGV[5])
+
+local interval = period / rate
+local tolerance = interval * burst
+
+local tat = redis.call("GET", KEYS[1])
+
+if tat then
+    tat = tonumber(tat)
+else
+    tat = now
+end
+
+if tat < now then
+    tat = now
+end
+
+local newtat = tat + cost * interval
+local diff = now - (newtat - tolerance)
+
+if diff < 0 then
+    local remaining = math.floor((now - (tat - tolerance)) / interval)
+
+    if remaining < 0 then
+        remaining = 0
+    end
+
+    return {0, remaining, math.ceil(-diff), math.ceil(tat - now)}
+end
+
+local reset = math.ceil(newtat - now)
+
+if cost > 0 then
+    redis.call("SET", KEYS[1], newtat, "PX", reset)
+end
+
+return {1, math.floor(diff / interval), 0, reset}
+`)
+
+// GCRA implements the generic cell rate algorithm.
+type GCRA struct {
+    Prefix string
+    Clock  Clock
+    db     *redis.Client
+    limit  Limit
+}
+
+// NewGCRA returns a GCRA limiter which allows limit.Rate requests per
+// limit.Period with bursts of up to limit.Burst requests.
+func NewGCRA(db *redis.Client, limit Limit) (*GCRA, error) {
+    if e := limit.check(); e != nil {
+        return nil, e
+    }
+
+    if limit.Burst == 0 {
+        limit.Burst = limit.Rate
+    }
+
+    return &GCRA{"ratelimit:gcra:", systemClock{}, db, limit}, nil
+}
+
+// Allow is shorthand for AllowN(key, 1).
+func (l *GCRA) Allow(key string) (*Result, error) {
+    return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n requests may happen now and counts them if so.
+func (l *GCRA) AllowN(key string, n int64) (*Result, error) {
+    if e := checkCount(n); e != nil {
+        return nil, e
+    }
+
+    reply, e := gcraScript.run(l.db, []string{l.Prefix + key},
+        l.limit.Rate,
+        l.limit.Burst,
+        durationMillis(l.limit.Period),
+        millis(l.Clock.Now()),
+        n)
+
+    if e != nil {
+        return nil, e
+    }
+
+    return newResult(reply)
+}
@@ -0,0 +1,188 @@
+// Package ratelimit implements rate limiters backed by Redis.
+//
+// All limiters keep their state in Redis and update it with a single Lua
+// script per request, so a limit can be shared by any number of processes.
+// Three algorithms are available.
+//
+// GCRA
+//
+// The generic cell rate algorithm stores one timestamp per key, the
+// theoretical arrival time of the next request. It spreads requests evenly
+// over the period and allows short bursts.
+//
+//      db := redis.NewClient("tcp:127.0.0.1:6379", 0, "")
+//      l, e := ratelimit.NewGCRA(db, ratelimit.PerSecond(10))
+//      res, e := l.Allow("user:1")
+//
+//      if e == nil && !res.Allowed {
+//          // try again in res.RetryAfter
+//      }
+//
+// FixedWindow
+//
+// A counter per key and window. Cheap, but allows up to twice the rate
+// around the edge of two windows.
+//
+// SlidingLog
+//
+// A sorted set with one entry per request. Exact, at the cost of memory
+// proportional to the rate.
+//
+// The current time is read from the Clock of each limiter and sent to the
+// script, which makes it possible to drive a limiter with a fake clock.
+package ratelimit
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "insmo.com/godis/exp"
+)
+
+// ErrLimit is returned by the constructors of the limiters for a Limit
+// whose Rate or Period is not positive or whose Burst is negative.
+var ErrLimit = errors.New("ratelimit: invalid limit")
+
+// ErrCount is returned by AllowN for a count of requests below one.
+var ErrCount = errors.New("ratelimit: invalid request count")
+
+// checkCount returns an error wrapping ErrCount if n is below one.
+func checkCount(n int64) error {
+    if n < 1 {
+        return fmt.Errorf("%w: %d", ErrCount, n)
+    }
+
+    return nil
+}
+
+// Limit describes the number of requests allowed per period.
+type Limit struct {
+    Rate   int64
+    Period time.Duration
+
+    // Burst is only used by GCRA. It defaults to Rate.
+    Burst int64
+}
+
+// check returns an error wrapping ErrLimit if l allows no requests. The
+// period is sent to the scripts in milliseconds, it must be at least one.
+func (l Limit) check() error {
+    switch {
+    case l.Rate < 1:
+        return fmt.Errorf("%w: rate %d", ErrLimit, l.Rate)
+    case l.Period < time.Millisecond:
+        return fmt.Errorf("%w: period %s", ErrLimit, l.Period)
+    case l.Burst < 0:
+        return fmt.Errorf("%w: burst %d", ErrLimit, l.Burst)
+    }
+
+    return nil
+}
+
+func PerSecond(rate int64) Limit {
+    return Limit{Rate: rate, Period: time.Second, Burst: rate}
+}
+
+func PerMinute(rate int64) Limit {
+    return Limit{Rate: rate, Period: time.Minute, Burst: rate}
+}
+
+func PerHour(rate int64) Limit {
+    return Limit{Rate: rate, Period: time.Hour, Burst: rate}
+}
+
+// Result is returned by a limiter for every request.
+type Result struct {
+    // Allowed is true if the request was allowed and counted.
+    Allowed bool
+
+    // Remaining is the number of requests which would be allowed right now.
+    Remaining int64
+
+    // RetryAfter is the time until the request would be allowed. It is zero
+    // for allowed requests.
+    RetryAfter time.Duration
+
+    // ResetAfter is the time until the limiter is back to its initial state.
+    ResetAfter time.Duration
+}
+
+// Limiter is implemented by GCRA, FixedWindow and SlidingLog. AllowN
+// returns an error wrapping ErrCount if n is below one, without calling
+// Redis.
+type Limiter interface {
+    Allow(key string) (*Result, error)
+    AllowN(key string, n int64) (*Result, error)
+}
+
+// Clock returns the current time.
+type Clock interface {
+    Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+    return time.Now()
+}
+
+// script caches the sha1 of a Lua script.
+type script struct {
+    src  string
+    hash string
+}
+
+func newScript(src string) *script {
+    h := sha1.Sum([]byte(src))
+    return &script{src, hex.EncodeToString(h[:])}
+}
+
+// run executes the script with EVALSHA and falls back to EVAL when the
+// script is not yet cached by the server.
+func (s *script) run(db *redis.Client, keys []string, args ...interface{}) (*redis.Reply, error) {
+    cmd := make([]interface{}, 0, 3+len(keys)+len(args))
+    cmd = append(cmd, "EVALSHA", s.hash, len(keys))
+
+    for _, k := range keys {
+        cmd = append(cmd, k)
+    }
+
+    cmd = append(cmd, args...)
+    reply, e := db.Call(cmd...)
+
+    if e != nil && strings.HasPrefix(e.Error(), "NOSCRIPT") {
+        cmd[0], cmd[1] = "EVAL", s.src
+        reply, e = db.Call(cmd...)
+    }
+
+    return reply, e
+}
+
+// All scripts reply with {allowed, remaining, retry after, reset after},
+// durations in milliseconds.
+func newResult(reply *redis.Reply) (*Result, error) {
+    if reply.Len() != 4 {
+        return nil, redis.ErrProtocol
+    }
+
+    v := reply.IntArray()
+
+    return &Result{
+        Allowed:    v[0] == 1,
+        Remaining:  v[1],
+        RetryAfter: time.Duration(v[2]) * time.Millisecond,
+        ResetAfter: time.Duration(v[3]) * time.Millisecond,
+    }, nil
+}
+
+func millis(t time.Time) int64 {
+    return t.UnixNano() / int64(time.Millisecond)
+}
+
+func durationMillis(d time.Duration) int64 {
+    return int64(d / time.Millisecond)
+}
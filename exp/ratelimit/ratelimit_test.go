@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "insmo.com/godis/exp"
+)
+
+var db *redis.Client
+
+func init() {
+    redis.MaxConnections = 1
+    db = redis.NewClient("tcp:localhost:6379", 9, "")
+}
+
+type fakeClock struct {
+    t time.Time
+}
+
+func newFakeClock() *fakeClock {
+    return &fakeClock{time.Date(2012, 5, 6, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+    return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+    c.t = c.t.Add(d)
+}
+
+type allowTest struct {
+    advance   time.Duration
+    n         int64
+    allowed   bool
+    remaining int64
+    retry     time.Duration
+}
+
+func runAllowTests(t *testing.T, name string, l Limiter, clock *fakeClock, tests []allowTest) {
+    if _, e := db.Call("FLUSHDB"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    for i, o := range tests {
+        clock.Advance(o.advance)
+        res, e := l.AllowN("foo", o.n)
+
+        if e != nil {
+            t.Fatalf("%s #%d: %s", name, i, e.Error())
+        }
+
+        if res.Allowed != o.allowed || res.Remaining != o.remaining || res.RetryAfter != o.retry {
+            t.Errorf("%s #%d: expected allowed=%v remaining=%d retry=%v, got allowed=%v remaining=%d retry=%v",
+                name, i, o.allowed, o.remaining, o.retry, res.Allowed, res.Remaining, res.RetryAfter)
+        }
+    }
+}
+
+func TestGCRA(t *testing.T) {
+    clock := newFakeClock()
+    l, _ := NewGCRA(db, Limit{Rate: 10, Period: time.Second, Burst: 3})
+    l.Clock = clock
+
+    runAllowTests(t, "gcra", l, clock, []allowTest{
+        {0, 1, true, 2, 0},
+        {0, 1, true, 1, 0},
+        {0, 1, true, 0, 0},
+        {0, 1, false, 0, 100 * time.Millisecond},
+        {50 * time.Millisecond, 1, false, 0, 50 * time.Millisecond},
+        {50 * time.Millisecond, 1, true, 0, 0},
+        {time.Second, 2, true, 1, 0},
+        {0, 2, false, 1, 100 * time.Millisecond},
+        {0, 1, true, 0, 0},
+    })
+}
+
+func TestFixedWindow(t *testing.T) {
+    clock := newFakeClock()
+    l, _ := NewFixedWindow(db, PerMinute(3))
+    l.Clock = clock
+
+    runAllowTests(t, "fixed", l, clock, []allowTest{
+        {0, 1, true, 2, 0},
+        {time.Second, 2, true, 0, 0},
+        {time.Second, 1, false, 0, 58 * time.Second},
+        {58 * time.Second, 1, true, 2, 0},
+        {0, 3, false, 2, 60 * time.Second},
+    })
+}
+
+func TestSlidingLog(t *testing.T) {
+    clock := newFakeClock()
+    l, _ := NewSlidingLog(db, PerMinute(3))
+    l.Clock = clock
+
+    runAllowTests(t, "log", l, clock, []allowTest{
+        {0, 1, true, 2, 0},
+        {10 * time.Second, 2, true, 0, 0},
+        {10 * time.Second, 1, false, 0, 40 * time.Second},
+        {40 * time.Second, 1, true, 0, 0},
+        {0, 2, false, 0, 10 * time.Second},
+        {10 * time.Second, 2, true, 0, 0},
+    })
+}
+
+func TestResetAfter(t *testing.T) {
+    clock := newFakeClock()
+    l, _ := NewGCRA(db, PerSecond(10))
+    l.Clock = clock
+
+    if _, e := db.Call("FLUSHDB"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    res, e := l.AllowN("foo", 5)
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if res.ResetAfter != 500*time.Millisecond {
+        t.Errorf("expected reset after 500ms, got %v", res.ResetAfter)
+    }
+}
+
+func TestInvalidLimit(t *testing.T) {
+    for _, limit := range []Limit{
+        {Rate: 0, Period: time.Second},
+        {Rate: -1, Period: time.Second},
+        {Rate: 1, Period: 0},
+        {Rate: 1, Period: time.Microsecond},
+        {Rate: 1, Period: time.Second, Burst: -1},
+    } {
+        if _, e := NewGCRA(db, limit); !errors.Is(e, ErrLimit) {
+            t.Errorf("gcra %+v: expected ErrLimit got %v", limit, e)
+        }
+
+        if _, e := NewFixedWindow(db, limit); !errors.Is(e, ErrLimit) {
+            t.Errorf("fixed %+v: expected ErrLimit got %v", limit, e)
+        }
+
+        if _, e := NewSlidingLog(db, limit); !errors.Is(e, ErrLimit) {
+            t.Errorf("log %+v: expected ErrLimit got %v", limit, e)
+        }
+    }
+
+    if l, e := NewGCRA(db, Limit{Rate: 5, Period: time.Second}); e != nil || l.limit.Burst != 5 {
+        t.Errorf("expected burst to default to the rate got %v", e)
+    }
+}
+
+func TestInvalidCount(t *testing.T) {
+    gcra, _ := NewGCRA(db, PerSecond(10))
+    fixed, _ := NewFixedWindow(db, PerSecond(10))
+    log, _ := NewSlidingLog(db, PerSecond(10))
+
+    for _, l := range []Limiter{gcra, fixed, log} {
+        for _, n := range []int64{0, -1} {
+            if _, e := l.AllowN("foo", n); !errors.Is(e, ErrCount) {
+                t.Errorf("%T %d: expected ErrCount got %v", l, n, e)
+            }
+        }
+    }
+}
@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "strconv"
+
+    "insmo.com/godis/exp"
+)
+
+// KEYS[1] is the counter for the current window.
+// ARGV: limit, milliseconds left of the window, cost
+var fixedWindowScript = newScript(`
+local limit = tonumber(ARGV[1])
+local left = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+
+if used + cost > limit then
+    return {0, math.max(limit - used, 0), left, left}
+end
+
+used = redis.call("INCRBY", KEYS[1], cost)
+redis.call("PEXPIRE", KEYS[1], left)
+return {1, math.max(limit - used, 0), 0, left}
+`)
+
+// KEYS[1] is a sorted set of request timestamps.
+// ARGV: limit, window, now, cost, member prefix
+var slidingLogScript = newScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local used = redis.call("ZCARD", KEYS[1])
+
+if used + cost > limit then
+    local retry = window
+    local reset = 0
+    local over = used + cost - limit
+
+    if over <= used then
+        local entry = redis.call("ZRANGE", KEYS[1], over - 1, over - 1, "WITHSCORES")
+        retry = tonumber(entry[2]) + window - now
+    end
+
+    if used > 0 then
+        local entry = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+        reset = tonumber(entry[2]) + window - now
+    end
+
+    return {0, math.max(limit - used, 0), retry, reset}
+end
+
+for i = 1, cost do
+    redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+end
+
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - used - cost, 0, window}
+`)
+
+// FixedWindow counts requests in consecutive windows of limit.Period.
+type FixedWindow struct {
+    Prefix string
+    Clock  Clock
+    db     *redis.Client
+    limit  Limit
+}
+
+// NewFixedWindow returns a limiter which allows limit.Rate requests per
+// window of limit.Period.
+func NewFixedWindow(db *redis.Client, limit Limit) (*FixedWindow, error) {
+    if e := limit.check(); e != nil {
+        return nil, e
+    }
+
+    return &FixedWindow{"ratelimit:fixed:", systemClock{}, db, limit}, nil
+}
+
+// Allow is shorthand for AllowN(key, 1).
+func (l *FixedWindow) Allow(key string) (*Result, error) {
+    return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n requests may happen now and counts them if so.
+func (l *FixedWindow) AllowN(key string, n int64) (*Result, error) {
+    if e := checkCount(n); e != nil {
+        return nil, e
+    }
+
+    now := l.Clock.Now()
+    start := now.Truncate(l.limit.Period)
+    left := durationMillis(start.Add(l.limit.Period).Sub(now))
+
+    if left < 1 {
+        left = 1
+    }
+
+    k := l.Prefix + key + ":" + strconv.FormatInt(millis(start), 10)
+    reply, e := fixedWindowScript.run(l.db, []string{k}, l.limit.Rate, left, n)
+
+    if e != nil {
+        return nil, e
+    }
+
+    return newResult(reply)
+}
+
+// SlidingLog keeps a log of the requests during the last limit.Period.
+type SlidingLog struct {
+    Prefix string
+    Clock  Clock
+    db     *redis.Client
+    limit  Limit
+}
+
+// NewSlidingLog returns a limiter which allows limit.Rate requests during
+// any interval of limit.Period.
+func NewSlidingLog(db *redis.Client, limit Limit) (*SlidingLog, error) {
+    if e := limit.check(); e != nil {
+        return nil, e
+    }
+
+    return &SlidingLog{"ratelimit:log:", systemClock{}, db, limit}, nil
+}
+
+// Allow is shorthand for AllowN(key, 1).
+func (l *SlidingLog) Allow(key string) (*Result, error) {
+    return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n requests may happen now and logs them if so.
+func (l *SlidingLog) AllowN(key string, n int64) (*Result, error) {
+    if e := checkCount(n); e != nil {
+        return nil, e
+    }
+
+    now := millis(l.Clock.Now())
+    id, e := nonce()
+
+    if e != nil {
+        return nil, e
+    }
+
+    reply, e := slidingLogScript.run(l.db, []string{l.Prefix + key},
+        l.limit.Rate,
+        durationMillis(l.limit.Period),
+        now,
+        n,
+        strconv.FormatInt(now, 10)+"-"+id)
+
+    if e != nil {
+        return nil, e
+    }
+
+    return newResult(reply)
+}
+
+// nonce keeps log entries from different clients unique.
+func nonce() (string, error) {
+    b := make([]byte, 8)
+
+    if _, e := rand.Read(b); e != nil {
+        return "", e
+    }
+
+    return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,175 @@
+package redisotel
+
+import (
+    "sync"
+    "time"
+)
+
+// SpanData is a span recorded by an Exporter.
+type SpanData struct {
+    Name  string
+    Attrs map[string]interface{}
+    Err   error
+    Start time.Time
+    End   time.Time
+    Ended bool
+}
+
+// Measurement is a value recorded by a histogram or counter of an
+// Exporter.
+type Measurement struct {
+    Value float64
+    Attrs map[string]interface{}
+}
+
+// Exporter is an in-memory Tracer and Meter for tests.
+type Exporter struct {
+    mu           sync.Mutex
+    spans        []*SpanData
+    measurements map[string][]Measurement
+    gauges       map[string]func() int64
+}
+
+// NewExporter returns an empty Exporter.
+func NewExporter() *Exporter {
+    return &Exporter{
+        measurements: make(map[string][]Measurement),
+        gauges:       make(map[string]func() int64),
+    }
+}
+
+// Spans returns the spans started so far.
+func (x *Exporter) Spans() []SpanData {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    spans := make([]SpanData, len(x.spans))
+
+    for i, s := range x.spans {
+        spans[i] = *s
+    }
+
+    return spans
+}
+
+// Measurements returns the values recorded by the named histogram or
+// counter.
+func (x *Exporter) Measurements(name string) []Measurement {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    return append([]Measurement(nil), x.measurements[name]...)
+}
+
+// Sum returns the sum of the values recorded by the named instrument whose
+// attributes include attrs.
+func (x *Exporter) Sum(name string, attrs ...Attr) float64 {
+    sum := 0.0
+
+    for _, m := range x.Measurements(name) {
+        if m.matches(attrs) {
+            sum += m.Value
+        }
+    }
+
+    return sum
+}
+
+// Observe collects the named gauge. It returns false if no such gauge was
+// registered.
+func (x *Exporter) Observe(name string) (int64, bool) {
+    x.mu.Lock()
+    fn, ok := x.gauges[name]
+    x.mu.Unlock()
+
+    if !ok {
+        return 0, false
+    }
+
+    return fn(), true
+}
+
+// Reset forgets the recorded spans and measurements.
+func (x *Exporter) Reset() {
+    x.mu.Lock()
+    x.spans = nil
+    x.measurements = make(map[string][]Measurement)
+    x.mu.Unlock()
+}
+
+func (m Measurement) matches(attrs []Attr) bool {
+    for _, a := range attrs {
+        if m.Attrs[a.Key] != a.Value {
+            return false
+        }
+    }
+
+    return true
+}
+
+func attrMap(attrs []Attr) map[string]interface{} {
+    m := make(map[string]interface{}, len(attrs))
+
+    for _, a := range attrs {
+        m[a.Key] = a.Value
+    }
+
+    return m
+}
+
+// Start implements Tracer.
+func (x *Exporter) Start(name string, attrs ...Attr) Span {
+    s := &SpanData{Name: name, Attrs: attrMap(attrs), Start: time.Now()}
+    x.mu.Lock()
+    x.spans = append(x.spans, s)
+    x.mu.Unlock()
+    return &memorySpan{x, s}
+}
+
+// Histogram implements Meter.
+func (x *Exporter) Histogram(name, unit string) Histogram {
+    return &memoryInstrument{x, name}
+}
+
+// Counter implements Meter.
+func (x *Exporter) Counter(name string) Counter {
+    return &memoryInstrument{x, name}
+}
+
+// Gauge implements Meter.
+func (x *Exporter) Gauge(name string, observe func() int64) {
+    x.mu.Lock()
+    x.gauges[name] = observe
+    x.mu.Unlock()
+}
+
+type memorySpan struct {
+    x *Exporter
+    s *SpanData
+}
+
+func (ms *memorySpan) RecordError(e error) {
+    ms.x.mu.Lock()
+    ms.s.Err = e
+    ms.x.mu.Unlock()
+}
+
+func (ms *memorySpan) End() {
+    ms.x.mu.Lock()
+    ms.s.End = time.Now()
+    ms.s.Ended = true
+    ms.x.mu.Unlock()
+}
+
+type memoryInstrument struct {
+    x    *Exporter
+    name string
+}
+
+func (mi *memoryInstrument) Record(v float64, attrs ...Attr) {
+    mi.x.mu.Lock()
+    mi.x.measurements[mi.name] = append(mi.x.measurements[mi.name], Measurement{v, attrMap(attrs)})
+    mi.x.mu.Unlock()
+}
+
+func (mi *memoryInstrument) Add(n int64, attrs ...Attr) {
+    mi.Record(float64(n), attrs...)
+}
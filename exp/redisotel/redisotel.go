@@ -0,0 +1,325 @@
+// Package redisotel instruments the exp client with OpenTelemetry style
+// traces and metrics.
+//
+// The package does not depend on an OpenTelemetry SDK. Spans and metrics are
+// reported through the small Tracer and Meter interfaces, which are easily
+// adapted to an SDK, or recorded by the in-memory Exporter in tests.
+//
+//      c := redis.NewClient("tcp:127.0.0.1:6379", 0, "")
+//      redisotel.Instrument(c, tracer, meter)
+//
+// Spans
+//
+// Every command gets a span named after the command, pipelines a span named
+// "pipeline". Spans have the attributes db.system, db.statement with the
+// arguments redacted, db.redis.database_index, net.peer.name and
+// net.peer.port.
+//
+// Metrics
+//
+//      db.client.commands.duration    histogram of command latency in seconds
+//                                     by db.operation
+//      db.client.commands.errors      counter of errors by db.operation and
+//                                     error.prefix, e.g. "WRONGTYPE"
+//      db.client.connections.in_use   gauge of connections in use
+//      db.client.connections.idle     gauge of idle connections in the pool
+//      db.client.connections.waits    gauge of waits for a free connection
+package redisotel
+
+import (
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "unicode"
+
+    "insmo.com/godis/exp"
+)
+
+// Attribute keys.
+const (
+    DBSystem    = "db.system"
+    DBStatement = "db.statement"
+    DBOperation = "db.operation"
+    DBIndex     = "db.redis.database_index"
+    DBNumCmd    = "db.redis.num_cmd"
+    PeerName    = "net.peer.name"
+    PeerPort    = "net.peer.port"
+    ErrorPrefix = "error.prefix"
+)
+
+// Metric names.
+const (
+    CommandDuration = "db.client.commands.duration"
+    CommandErrors   = "db.client.commands.errors"
+    ConnsInUse      = "db.client.connections.in_use"
+    ConnsIdle       = "db.client.connections.idle"
+    ConnsWaits      = "db.client.connections.waits"
+)
+
+// Attr is a key value pair attached to spans and measurements.
+type Attr struct {
+    Key   string
+    Value interface{}
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr {
+    return Attr{key, value}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr {
+    return Attr{key, value}
+}
+
+// Tracer starts spans.
+type Tracer interface {
+    Start(name string, attrs ...Attr) Span
+}
+
+// Span is a traced operation.
+type Span interface {
+    // RecordError marks the span as failed.
+    RecordError(e error)
+    End()
+}
+
+// Meter creates instruments.
+type Meter interface {
+    Histogram(name, unit string) Histogram
+    Counter(name string) Counter
+
+    // Gauge registers a function which is called when the gauge is
+    // collected.
+    Gauge(name string, observe func() int64)
+}
+
+// Histogram records a distribution of values.
+type Histogram interface {
+    Record(v float64, attrs ...Attr)
+}
+
+// Counter records a monotonic sum.
+type Counter interface {
+    Add(n int64, attrs ...Attr)
+}
+
+// Hook implements redis.Hook and reports the commands of a client.
+type Hook struct {
+    redis.NopHook
+
+    // Statement formats the db.statement attribute. It defaults to
+    // Redact.
+    Statement func(args []interface{}) string
+
+    tracer   Tracer
+    duration Histogram
+    errors   Counter
+    attrs    []Attr
+
+    mu    sync.Mutex
+    spans map[*redis.Cmd]Span
+    pipes map[*redis.Cmd]Span
+}
+
+// NewHook returns a hook reporting to tracer and meter for commands sent to
+// addr ("tcp:127.0.0.1:6379") and db. Either of tracer and meter may be
+// nil.
+func NewHook(addr string, db int, tracer Tracer, meter Meter) *Hook {
+    h := &Hook{
+        Statement: Redact,
+        tracer:    tracer,
+        attrs:     peerAttrs(addr, db),
+        spans:     make(map[*redis.Cmd]Span),
+        pipes:     make(map[*redis.Cmd]Span),
+    }
+
+    if meter != nil {
+        h.duration = meter.Histogram(CommandDuration, "s")
+        h.errors = meter.Counter(CommandErrors)
+    }
+
+    return h
+}
+
+// Instrument adds a Hook to c and registers the pool gauges with meter.
+func Instrument(c *redis.Client, tracer Tracer, meter Meter) *Hook {
+    h := NewHook(c.Proto+":"+c.Addr, c.Db, tracer, meter)
+    c.AddHook(h)
+
+    if meter != nil {
+        meter.Gauge(ConnsInUse, func() int64 { return c.PoolStats().InUse })
+        meter.Gauge(ConnsIdle, func() int64 { return c.PoolStats().Idle })
+        meter.Gauge(ConnsWaits, func() int64 { return c.PoolStats().Waits })
+    }
+
+    return h
+}
+
+func peerAttrs(addr string, db int) []Attr {
+    attrs := []Attr{String(DBSystem, "redis"), Int(DBIndex, db)}
+
+    if i := strings.Index(addr, ":"); i >= 0 {
+        addr = addr[i+1:]
+    }
+
+    host, port, e := net.SplitHostPort(addr)
+
+    if e != nil {
+        // unix socket
+        return append(attrs, String(PeerName, addr))
+    }
+
+    attrs = append(attrs, String(PeerName, host))
+
+    if n, e := strconv.Atoi(port); e == nil {
+        attrs = append(attrs, Int(PeerPort, n))
+    }
+
+    return attrs
+}
+
+func (h *Hook) BeforeCall(cmd *redis.Cmd) {
+    if h.tracer == nil {
+        return
+    }
+
+    attrs := append(h.attrs[:len(h.attrs):len(h.attrs)], String(DBStatement, h.Statement(cmd.Args)))
+    span := h.tracer.Start(cmd.Name(), attrs...)
+    h.mu.Lock()
+    h.spans[cmd] = span
+    h.mu.Unlock()
+}
+
+func (h *Hook) AfterCall(cmd *redis.Cmd) {
+    h.record(cmd)
+
+    if h.tracer == nil {
+        return
+    }
+
+    h.mu.Lock()
+    span := h.spans[cmd]
+    delete(h.spans, cmd)
+    h.mu.Unlock()
+
+    if span != nil {
+        if cmd.Err != nil {
+            span.RecordError(cmd.Err)
+        }
+
+        span.End()
+    }
+}
+
+func (h *Hook) BeforePipeline(cmds []*redis.Cmd) {
+    if h.tracer == nil || len(cmds) == 0 {
+        return
+    }
+
+    stmts := make([]string, len(cmds))
+
+    for i, cmd := range cmds {
+        stmts[i] = h.Statement(cmd.Args)
+    }
+
+    attrs := append(h.attrs[:len(h.attrs):len(h.attrs)],
+        String(DBStatement, strings.Join(stmts, "\n")),
+        Int(DBNumCmd, len(cmds)))
+    span := h.tracer.Start("pipeline", attrs...)
+    h.mu.Lock()
+    h.pipes[cmds[0]] = span
+    h.mu.Unlock()
+}
+
+func (h *Hook) AfterPipeline(cmds []*redis.Cmd) {
+    for _, cmd := range cmds {
+        h.record(cmd)
+    }
+
+    if h.tracer == nil || len(cmds) == 0 {
+        return
+    }
+
+    h.mu.Lock()
+    span := h.pipes[cmds[0]]
+    delete(h.pipes, cmds[0])
+    h.mu.Unlock()
+
+    if span == nil {
+        return
+    }
+
+    for _, cmd := range cmds {
+        if cmd.Err != nil {
+            span.RecordError(cmd.Err)
+            break
+        }
+    }
+
+    span.End()
+}
+
+// record updates the metrics of a finished command.
+func (h *Hook) record(cmd *redis.Cmd) {
+    op := String(DBOperation, cmd.Name())
+
+    if h.duration != nil && !cmd.Start.IsZero() {
+        h.duration.Record(cmd.Duration.Seconds(), op)
+    }
+
+    if h.errors != nil && cmd.Err != nil {
+        h.errors.Add(1, op, String(ErrorPrefix, errorPrefix(cmd.Err)))
+    }
+}
+
+// errorPrefix returns the error code of a Redis error, e.g. "WRONGTYPE", or
+// "network" for other errors.
+func errorPrefix(e error) string {
+    s := e.Error()
+
+    if i := strings.IndexByte(s, ' '); i > 0 {
+        s = s[:i]
+    }
+
+    for _, r := range s {
+        if !unicode.IsUpper(r) {
+            return "network"
+        }
+    }
+
+    return s
+}
+
+// Redact formats a command keeping only the command name and the key. The
+// other arguments are replaced by "?", e.g. "SET foo ?". All arguments of
+// AUTH are redacted.
+func Redact(args []interface{}) string {
+    if len(args) == 0 {
+        return ""
+    }
+
+    name := strings.ToUpper(fmt.Sprint(args[0]))
+    parts := []string{name}
+
+    for i, arg := range args[1:] {
+        if i == 0 && name != "AUTH" {
+            parts = append(parts, fmt.Sprint(redisArg(arg)))
+        } else {
+            parts = append(parts, "?")
+        }
+    }
+
+    return strings.Join(parts, " ")
+}
+
+// redisArg converts byte slices, which fmt prints as numbers, to strings.
+func redisArg(arg interface{}) interface{} {
+    if b, ok := arg.([]byte); ok {
+        return string(b)
+    }
+
+    return arg
+}
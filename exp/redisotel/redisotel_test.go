@@ -0,0 +1,139 @@
+package redisotel
+
+import (
+    "errors"
+    "net"
+    "testing"
+
+    "insmo.com/godis/exp"
+    "insmo.com/godis/redistest"
+)
+
+func TestRedact(t *testing.T) {
+    tests := []struct {
+        args []interface{}
+        out  string
+    }{
+        {[]interface{}{}, ""},
+        {[]interface{}{"ping"}, "PING"},
+        {[]interface{}{"GET", "foo"}, "GET foo"},
+        {[]interface{}{"SET", []byte("foo"), "secret"}, "SET foo ?"},
+        {[]interface{}{"HMSET", "user:1", "name", "bob", "age", 42}, "HMSET user:1 ? ? ? ?"},
+        {[]interface{}{"AUTH", "password"}, "AUTH ?"},
+    }
+
+    for _, test := range tests {
+        if out := Redact(test.args); out != test.out {
+            t.Errorf("%v: expected %q got %q", test.args, test.out, out)
+        }
+    }
+}
+
+func TestErrorPrefix(t *testing.T) {
+    tests := map[string]string{
+        "ERR unknown command 'FOO'":                 "ERR",
+        "WRONGTYPE Operation against a key holding": "WRONGTYPE",
+        "dial tcp 127.0.0.1:1: connection refused":  "network",
+        "EOF": "EOF",
+    }
+
+    for s, prefix := range tests {
+        if p := errorPrefix(errors.New(s)); p != prefix {
+            t.Errorf("%q: expected %q got %q", s, prefix, p)
+        }
+    }
+}
+
+func TestInstrument(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    x := NewExporter()
+    c := redis.NewClient(s.NetAddr(), 2, "")
+    Instrument(c, x, x)
+
+    c.Call("SET", "foo", "bar")
+    c.Call("GET", "foo")
+    c.Call("INCR", "foo")
+
+    spans := x.Spans()
+
+    if len(spans) != 3 {
+        t.Fatalf("expected 3 spans got %d", len(spans))
+    }
+
+    span := spans[0]
+    host, _, _ := net.SplitHostPort(s.Addr)
+
+    if span.Name != "SET" || !span.Ended || span.Err != nil {
+        t.Errorf("unexpected span %+v", span)
+    }
+
+    for k, v := range map[string]interface{}{
+        DBSystem:    "redis",
+        DBStatement: "SET foo ?",
+        DBIndex:     2,
+        PeerName:    host,
+    } {
+        if span.Attrs[k] != v {
+            t.Errorf("%s: expected %v got %v", k, v, span.Attrs[k])
+        }
+    }
+
+    if _, ok := span.Attrs[PeerPort].(int); !ok {
+        t.Errorf("expected port got %v", span.Attrs[PeerPort])
+    }
+
+    if spans[2].Err == nil {
+        t.Errorf("expected INCR error")
+    }
+
+    if n := len(x.Measurements(CommandDuration)); n != 3 {
+        t.Errorf("expected 3 latencies got %d", n)
+    }
+
+    if n := x.Sum(CommandErrors, String(DBOperation, "INCR"), String(ErrorPrefix, "ERR")); n != 1 {
+        t.Errorf("expected 1 INCR error got %v", n)
+    }
+
+    // the pool rotates through its free slots, so every call dialed
+    if n, _ := x.Observe(ConnsIdle); n != 3 {
+        t.Errorf("expected 3 idle connections got %d", n)
+    }
+
+    if n, ok := x.Observe(ConnsInUse); !ok || n != 0 {
+        t.Errorf("expected no connections in use got %d", n)
+    }
+}
+
+func TestInstrumentPipeline(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    x := NewExporter()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+    Instrument(c, x, x)
+
+    ac := c.AsyncClient()
+    defer ac.Close()
+    ac.Call("SET", "foo", "bar")
+    ac.Call("GET", "foo")
+
+    if _, e := ac.ReadAll(); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    spans := x.Spans()
+
+    if len(spans) != 1 || spans[0].Name != "pipeline" || !spans[0].Ended {
+        t.Fatalf("expected pipeline span got %+v", spans)
+    }
+
+    if stmt := spans[0].Attrs[DBStatement]; stmt != "SET foo ?\nGET foo" {
+        t.Errorf("unexpected statement %q", stmt)
+    }
+
+    if n := x.Sum(CommandDuration, String(DBOperation, "GET")); n <= 0 {
+        t.Errorf("expected GET latency got %v", n)
+    }
+}
@@ -0,0 +1,307 @@
+// Package redisprom exports the counters of exp clients to Prometheus.
+//
+//      c := redis.NewClient("tcp:127.0.0.1:6379", 0, "")
+//      collector := redisprom.NewCollector("godis")
+//      collector.Add("sessions", c)
+//      http.Handle("/metrics", collector)
+//
+// All metrics have a "client" label with the name passed to Add.
+//
+// The collector only serves the Prometheus text exposition format, through
+// ServeHTTP and WriteTo. It does not implement the Collector interface of
+// the Prometheus client library and can not be registered with a registry
+// of that library. The package does not depend on the library, a program
+// using it can wrap the metrics returned by Collect as const metrics:
+//
+//      valueType := map[string]prometheus.ValueType{
+//          redisprom.Counter: prometheus.CounterValue,
+//          redisprom.Gauge:   prometheus.GaugeValue,
+//      }
+//
+//      for _, m := range collector.Collect() {
+//          desc := prometheus.NewDesc(m.Name, m.Help, m.LabelNames(), nil)
+//          ch <- prometheus.MustNewConstMetric(desc, valueType[m.Type], m.Value, m.LabelValues()...)
+//      }
+package redisprom
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "insmo.com/godis/exp"
+)
+
+// Metric types of the Prometheus text format.
+const (
+    Counter = "counter"
+    Gauge   = "gauge"
+)
+
+// desc describes a metric, as prometheus.Desc.
+type desc struct {
+    name   string
+    help   string
+    typ    string
+    labels []string
+}
+
+// Metric is the value of a metric of one client.
+type Metric struct {
+    Name string
+    Help string
+
+    // Type is Counter or Gauge
+    Type   string
+    Labels []Label
+    Value  float64
+}
+
+// Label is a label of a metric.
+type Label struct {
+    Name, Value string
+}
+
+// LabelNames returns the names of the labels of m.
+func (m Metric) LabelNames() []string {
+    names := make([]string, len(m.Labels))
+
+    for i, l := range m.Labels {
+        names[i] = l.Name
+    }
+
+    return names
+}
+
+// LabelValues returns the values of the labels of m.
+func (m Metric) LabelValues() []string {
+    values := make([]string, len(m.Labels))
+
+    for i, l := range m.Labels {
+        values[i] = l.Value
+    }
+
+    return values
+}
+
+// Collector collects the metrics of a set of named clients and serves them
+// in the Prometheus text exposition format.
+type Collector struct {
+    mu      sync.Mutex
+    clients map[string]*redis.Client
+
+    commands     *desc
+    replies      *desc
+    bytesRead    *desc
+    bytesWritten *desc
+    dials        *desc
+    dialFailures *desc
+    poolWaits    *desc
+    connsOpened  *desc
+    connsClosed  *desc
+    poolConns    *desc
+}
+
+// NewCollector returns a collector whose metric names start with
+// namespace, e.g. "godis_commands_total".
+func NewCollector(namespace string) *Collector {
+    counter := func(name, help string, labels ...string) *desc {
+        return &desc{namespace + "_" + name, help, Counter, append([]string{"client"}, labels...)}
+    }
+
+    return &Collector{
+        clients:      make(map[string]*redis.Client),
+        commands:     counter("commands_total", "Number of commands sent."),
+        replies:      counter("replies_total", "Number of replies read by type.", "type"),
+        bytesRead:    counter("read_bytes_total", "Number of bytes read."),
+        bytesWritten: counter("written_bytes_total", "Number of bytes written."),
+        dials:        counter("dials_total", "Number of connection attempts."),
+        dialFailures: counter("dial_failures_total", "Number of failed connection attempts."),
+        poolWaits:    counter("pool_waits_total", "Number of waits for a free connection."),
+        connsOpened:  counter("connections_opened_total", "Number of connections opened."),
+        connsClosed:  counter("connections_closed_total", "Number of connections closed."),
+        poolConns:    &desc{namespace + "_pool_connections", "Number of pooled connections by state.", Gauge, []string{"client", "state"}},
+    }
+}
+
+// Add starts collecting the counters of c labeled with name. A client
+// already added with the same name is replaced.
+func (col *Collector) Add(name string, c *redis.Client) {
+    col.mu.Lock()
+    col.clients[name] = c
+    col.mu.Unlock()
+}
+
+// Remove stops collecting the client added with name.
+func (col *Collector) Remove(name string) {
+    col.mu.Lock()
+    delete(col.clients, name)
+    col.mu.Unlock()
+}
+
+// Collect returns the metrics of all clients, ordered by metric and client
+// name.
+func (col *Collector) Collect() []Metric {
+    samples := col.samples()
+    order := make(map[*desc]int)
+
+    for i, d := range col.descs() {
+        order[d] = i
+    }
+
+    sort.SliceStable(samples, func(i, j int) bool {
+        return order[samples[i].desc] < order[samples[j].desc]
+    })
+
+    metrics := make([]Metric, len(samples))
+
+    for i, s := range samples {
+        m := Metric{Name: s.desc.name, Help: s.desc.help, Type: s.desc.typ, Value: s.value}
+
+        for j, name := range s.desc.labels {
+            m.Labels = append(m.Labels, Label{name, s.labels[j]})
+        }
+
+        metrics[i] = m
+    }
+
+    return metrics
+}
+
+func (col *Collector) descs() []*desc {
+    return []*desc{
+        col.commands,
+        col.replies,
+        col.bytesRead,
+        col.bytesWritten,
+        col.dials,
+        col.dialFailures,
+        col.poolWaits,
+        col.connsOpened,
+        col.connsClosed,
+        col.poolConns,
+    }
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WriteTo writes the metrics of all clients to w in the Prometheus text
+// format.
+func (col *Collector) WriteTo(w io.Writer) (int64, error) {
+    cw := &countWriter{w: w}
+    bw := bufio.NewWriter(cw)
+    var last string
+
+    for _, m := range col.Collect() {
+        if m.Name != last {
+            fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type)
+            last = m.Name
+        }
+
+        bw.WriteString(m.Name)
+
+        for i, l := range m.Labels {
+            sep := ","
+
+            if i == 0 {
+                sep = "{"
+            }
+
+            fmt.Fprintf(bw, `%s%s="%s"`, sep, l.Name, labelEscaper.Replace(l.Value))
+        }
+
+        if len(m.Labels) > 0 {
+            bw.WriteByte('}')
+        }
+
+        fmt.Fprintf(bw, " %s\n", strconv.FormatFloat(m.Value, 'g', -1, 64))
+    }
+
+    e := bw.Flush()
+    return cw.n, e
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (col *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+    col.WriteTo(w)
+}
+
+type countWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+    n, e := cw.w.Write(p)
+    cw.n += int64(n)
+    return n, e
+}
+
+type sample struct {
+    desc   *desc
+    value  float64
+    labels []string
+}
+
+// samples reads the counters of all clients, ordered by client name.
+func (col *Collector) samples() []sample {
+    col.mu.Lock()
+    names := make([]string, 0, len(col.clients))
+
+    for name := range col.clients {
+        names = append(names, name)
+    }
+
+    clients := make([]*redis.Client, len(names))
+    sort.Strings(names)
+
+    for i, name := range names {
+        clients[i] = col.clients[name]
+    }
+
+    col.mu.Unlock()
+
+    var samples []sample
+
+    for i, c := range clients {
+        name := names[i]
+        st := c.Stats()
+        pool := c.PoolStats()
+        counter := func(desc *desc, v int64, labels ...string) {
+            samples = append(samples, sample{desc, float64(v), append([]string{name}, labels...)})
+        }
+
+        counter(col.commands, st.Commands)
+        types := make([]string, 0, len(st.Replies))
+
+        for typ := range st.Replies {
+            types = append(types, typ)
+        }
+
+        sort.Strings(types)
+
+        for _, typ := range types {
+            counter(col.replies, st.Replies[typ], typ)
+        }
+
+        counter(col.bytesRead, st.BytesRead)
+        counter(col.bytesWritten, st.BytesWritten)
+        counter(col.dials, st.Dials)
+        counter(col.dialFailures, st.DialFailures)
+        counter(col.poolWaits, st.PoolWaits)
+        counter(col.connsOpened, st.ConnsOpened)
+        counter(col.connsClosed, st.ConnsClosed)
+
+        samples = append(samples,
+            sample{col.poolConns, float64(pool.InUse), []string{name, "in_use"}},
+            sample{col.poolConns, float64(pool.Idle), []string{name, "idle"}})
+    }
+
+    return samples
+}
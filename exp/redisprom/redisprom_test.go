@@ -0,0 +1,119 @@
+package redisprom
+
+import (
+    "bytes"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "insmo.com/godis/exp"
+    "insmo.com/godis/redistest"
+)
+
+var _ http.Handler = (*Collector)(nil)
+
+func TestCollector(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    c := redis.NewClient(s.NetAddr(), 0, "")
+    c.Call("SET", "foo", "bar")
+    c.Call("GET", "foo")
+    c.Call("INCR", "foo")
+    down := redis.NewClient("tcp:127.0.0.1:1", 0, "")
+    down.Call("PING")
+
+    col := NewCollector("godis")
+    col.Add("main", c)
+    col.Add("down", down)
+    values := make(map[string]float64)
+
+    for _, s := range col.samples() {
+        values[strings.Join(s.labels, ",")+" "+descName(col, s.desc)] = s.value
+    }
+
+    for key, v := range map[string]float64{
+        "main commands":                3,
+        "main,status replies":          1,
+        "main,bulk replies":            1,
+        "main,error replies":           1,
+        "main dials":                   3,
+        "main dial failures":           0,
+        "main connections opened":      3,
+        "main,idle pool connections":   3,
+        "main,in_use pool connections": 0,
+        "down dials":                   1,
+        "down dial failures":           1,
+    } {
+        if values[key] != v {
+            t.Errorf("%s: expected %v got %v", key, v, values[key])
+        }
+    }
+
+    if values["main bytes written"] == 0 || values["main bytes read"] == 0 {
+        t.Errorf("expected bytes read and written got %v", values)
+    }
+
+    col.Remove("main")
+    col.Remove("down")
+
+    if n := len(col.samples()); n != 0 {
+        t.Errorf("expected no samples got %d", n)
+    }
+}
+
+func descName(col *Collector, d *desc) string {
+    return map[*desc]string{
+        col.commands:     "commands",
+        col.replies:      "replies",
+        col.bytesRead:    "bytes read",
+        col.bytesWritten: "bytes written",
+        col.dials:        "dials",
+        col.dialFailures: "dial failures",
+        col.poolWaits:    "pool waits",
+        col.connsOpened:  "connections opened",
+        col.connsClosed:  "connections closed",
+        col.poolConns:    "pool connections",
+    }[d]
+}
+
+func TestWriteTo(t *testing.T) {
+    s := redistest.NewServer()
+    defer s.Close()
+
+    c := redis.NewClient(s.NetAddr(), 0, "")
+    c.Call("PING")
+    col := NewCollector("godis")
+    col.Add(`a"b`, c)
+    var buf bytes.Buffer
+
+    if _, e := col.WriteTo(&buf); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    out := buf.String()
+
+    for _, line := range []string{
+        "# HELP godis_commands_total Number of commands sent.\n# TYPE godis_commands_total counter\n",
+        `godis_commands_total{client="a\"b"} 1` + "\n",
+        `godis_replies_total{client="a\"b",type="status"} 1` + "\n",
+        "# TYPE godis_pool_connections gauge\n",
+        `godis_pool_connections{client="a\"b",state="idle"} 1` + "\n",
+    } {
+        if !strings.Contains(out, line) {
+            t.Errorf("expected %q in\n%s", line, out)
+        }
+    }
+
+    if strings.Count(out, "# TYPE godis_pool_connections") != 1 {
+        t.Errorf("expected one TYPE line per metric got\n%s", out)
+    }
+
+    rec := httptest.NewRecorder()
+    col.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+    if rec.Body.String() != out || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+        t.Errorf("expected the text format got %q", rec.Body.String())
+    }
+}
@@ -11,6 +11,9 @@ type Reply struct {
     Err   error
     Elem  Elem
     Elems []*Reply
+
+    // protocol type byte, zero if the reply could not be read
+    typ byte
 }
 
 type Message struct {
@@ -46,6 +49,13 @@ func (e Elem) Float64() float64 {
     return v
 }
 
+// Type returns the protocol type of the reply, e.g. "status", "bulk" or
+// "array", see Stats.Replies for all types. It is empty for replies which
+// were not read from Redis.
+func (r *Reply) Type() string {
+    return replyTypes[r.typ]
+}
+
 func (r *Reply) Nil() bool {
     return r.Elems == nil && r.Elem == nil && r.Err == nil
 }
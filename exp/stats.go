@@ -0,0 +1,121 @@
+package redis
+
+import (
+    "net"
+    "sync/atomic"
+)
+
+// Stats holds the counters of a Client, see Client.Stats.
+type Stats struct {
+    // Commands is the number of commands sent, including queued
+    // AsyncClient commands.
+    Commands int64
+
+    // Replies counts the replies read by type: "status", "error",
+    // "integer", "bulk", "array", "null", "map", "set", "push", "boolean",
+    // "double", "bignum", "verbatim" and "blob-error".
+    Replies map[string]int64
+
+    BytesRead    int64
+    BytesWritten int64
+
+    // Dials is the number of connection attempts and DialFailures the
+    // number of attempts which failed.
+    Dials        int64
+    DialFailures int64
+
+    // PoolWaits is the number of times a caller had to wait for a free
+    // connection.
+    PoolWaits int64
+
+    // ConnsOpened and ConnsClosed count the connection churn.
+    ConnsOpened int64
+    ConnsClosed int64
+}
+
+// replyTypes names the reply type bytes.
+var replyTypes = map[byte]string{
+    plus:       "status",
+    minus:      "error",
+    colon:      "integer",
+    dollar:     "bulk",
+    star:       "array",
+    underscore: "null",
+    percent:    "map",
+    tilde:      "set",
+    gt:         "push",
+    hash:       "boolean",
+    comma:      "double",
+    lparen:     "bignum",
+    equals:     "verbatim",
+    bang:       "blob-error",
+}
+
+// clientStats is updated atomically by the connections of a client.
+type clientStats struct {
+    commands     int64
+    bytesRead    int64
+    bytesWritten int64
+    dials        int64
+    dialFailures int64
+    connsOpened  int64
+    connsClosed  int64
+    replies      [256]int64
+}
+
+func (s *clientStats) reply(r *Reply) {
+    if r.typ != 0 {
+        atomic.AddInt64(&s.replies[r.typ], 1)
+    }
+}
+
+// Stats returns a snapshot of the counters of c. The counters are shared
+// with the AsyncClients and CachedClients created from c.
+func (c *Client) Stats() Stats {
+    s := c.stats
+    st := Stats{
+        Commands:     atomic.LoadInt64(&s.commands),
+        Replies:      make(map[string]int64, len(replyTypes)),
+        BytesRead:    atomic.LoadInt64(&s.bytesRead),
+        BytesWritten: atomic.LoadInt64(&s.bytesWritten),
+        Dials:        atomic.LoadInt64(&s.dials),
+        DialFailures: atomic.LoadInt64(&s.dialFailures),
+        PoolWaits:    c.PoolStats().Waits,
+        ConnsOpened:  atomic.LoadInt64(&s.connsOpened),
+        ConnsClosed:  atomic.LoadInt64(&s.connsClosed),
+    }
+
+    for typ, name := range replyTypes {
+        st.Replies[name] = atomic.LoadInt64(&s.replies[typ])
+    }
+
+    return st
+}
+
+// statsConn counts the bytes read and written on a connection and when it
+// is closed.
+type statsConn struct {
+    net.Conn
+    stats  *clientStats
+    closed int32
+}
+
+func (c *statsConn) Read(p []byte) (int, error) {
+    n, e := c.Conn.Read(p)
+    atomic.AddInt64(&c.stats.bytesRead, int64(n))
+    return n, e
+}
+
+func (c *statsConn) Write(p []byte) (int, error) {
+    n, e := c.Conn.Write(p)
+    atomic.AddInt64(&c.stats.bytesWritten, int64(n))
+    return n, e
+}
+
+func (c *statsConn) Close() error {
+    if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+        atomic.AddInt64(&c.stats.connsClosed, 1)
+    }
+
+    return c.Conn.Close()
+}
@@ -0,0 +1,124 @@
+// Package logging implements the log levels, loggers and the redaction of
+// commands shared by the clients of the redis and exp packages.
+package logging
+
+import (
+    "log"
+    "strconv"
+    "strings"
+)
+
+// Level is the verbosity of a log message. A message is logged if its
+// level is at most the level of the client.
+type Level int32
+
+const (
+    LevelOff Level = iota
+    LevelError
+    LevelWarn
+    LevelInfo
+    LevelDebug
+)
+
+var levelNames = []string{"OFF", "ERROR", "WARN", "INFO", "DEBUG"}
+
+func (l Level) String() string {
+    if l < 0 || int(l) >= len(levelNames) {
+        return "Level(" + strconv.Itoa(int(l)) + ")"
+    }
+
+    return levelNames[l]
+}
+
+// ParseLevel parses a level name such as "debug" or "WARN".
+func ParseLevel(s string) (Level, bool) {
+    for i, name := range levelNames {
+        if strings.EqualFold(s, name) {
+            return Level(i), true
+        }
+    }
+
+    return LevelOff, false
+}
+
+// Logger receives the log messages of a client. Debug messages contain the
+// commands sent and the replies read, with arguments redacted.
+type Logger interface {
+    Log(level Level, msg string)
+}
+
+type stdLogger struct {
+    l *log.Logger
+}
+
+// StdLogger returns a Logger which writes to l, or to the standard logger
+// if l is nil.
+func StdLogger(l *log.Logger) Logger {
+    return &stdLogger{l}
+}
+
+func (s *stdLogger) Log(level Level, msg string) {
+    if s.l == nil {
+        log.Printf("godis %s: %s", level, msg)
+    } else {
+        s.l.Printf("godis %s: %s", level, msg)
+    }
+}
+
+// Config configures the logging of a client. A nil Logger is the standard
+// logger.
+type Config struct {
+    Logger Logger
+    Level  Level
+
+    // MaxArgLen is the number of bytes of an argument or reply which are
+    // logged. Longer values are replaced by their length.
+    MaxArgLen int
+}
+
+// Redact formats a command for logging. Passwords are hidden and arguments
+// longer than max bytes are replaced by their length.
+func Redact(args [][]byte, max int) string {
+    parts := make([]string, len(args))
+    name := ""
+
+    for i, arg := range args {
+        switch {
+        case i == 0:
+            name = strings.ToUpper(string(arg))
+            parts[i] = name
+        case secret(name, args, i):
+            parts[i] = "(redacted)"
+        default:
+            parts[i] = Truncate(arg, max)
+        }
+    }
+
+    return strings.Join(parts, " ")
+}
+
+// secret reports whether args[i] is a password: any argument of AUTH, and
+// the arguments following AUTH in HELLO and MIGRATE.
+func secret(name string, args [][]byte, i int) bool {
+    switch name {
+    case "AUTH":
+        return true
+    case "HELLO", "MIGRATE":
+        for j := 1; j < i; j++ {
+            if strings.EqualFold(string(args[j]), "AUTH") {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+// Truncate quotes b, or returns its length if it is longer than max bytes.
+func Truncate(b []byte, max int) string {
+    if len(b) > max {
+        return "(" + strconv.Itoa(len(b)) + " bytes)"
+    }
+
+    return strconv.Quote(string(b))
+}
@@ -5,7 +5,6 @@ import (
     "bytes"
     "errors"
     "io"
-    "log"
     "net"
 
     "strconv"
@@ -21,8 +20,6 @@ const (
     minus  byte = 45
     plus   byte = 43
     star   byte = 42
-
-    debug = false
 )
 
 var (
@@ -31,16 +28,14 @@ var (
 
     // protocol bytes
     delim = []byte{cr, lf}
-
-    // misc
-    connCount int
-    cmdCount  = map[byte]int{dollar: 0, colon: 0, minus: 0, plus: 0, star: 0}
 )
 
 type conn struct {
-    rwc net.Conn
-    r   *bufio.Reader
-    w   *bufio.Writer
+    rwc   net.Conn
+    r     *bufio.Reader
+    w     *bufio.Writer
+    stats *stats
+    log   *clientLog
 }
 
 type pool struct {
@@ -78,10 +73,6 @@ func buildCmd(args [][]byte) []byte {
         buf.Write(delim)
     }
 
-    if debug {
-        log.Printf("GODIS: %q", string(buf.Bytes()))
-    }
-
     return buf.Bytes()
 }
 
@@ -191,36 +182,20 @@ func (r *Reply) Message() *Message {
 
 func (r *Reply) parseErr(res []byte) {
     r.Err = errors.New(string(res))
-
-    if debug {
-        log.Println("GODIS-ERR: " + string(res))
-    }
 }
 
 func (r *Reply) parseStr(res []byte) {
     r.Elem = res
-
-    if debug {
-        log.Println("GODIS-STR: " + string(res))
-    }
 }
 
 func (r *Reply) parseInt(res []byte) {
     r.Elem = res
-
-    if debug {
-        log.Println("GODIS-INT: " + string(res))
-    }
 }
 
 func (r *Reply) parseBulk(res []byte) {
     l, _ := strconv.Atoi(string(res))
 
     if l == -1 {
-        if debug {
-            log.Println("GODIS-BULK: Key does not exist")
-        }
-
         r.Err = nil
         return
     }
@@ -236,10 +211,6 @@ func (r *Reply) parseBulk(res []byte) {
 
     l -= 2
     r.Elem = data[:l]
-
-    if debug {
-        log.Printf("GODIS-BULK: read %d byte, bulk-data %q\n", l, data)
-    }
 }
 
 func (r *Reply) parseMultiBulk(res []byte) {
@@ -259,27 +230,18 @@ func (r *Reply) parseMultiBulk(res []byte) {
             r.Err = rr.Err
         }
 
-        // key not found, ignore `nil` value
-        //if rr.Elem == nil {
-        //    i -= 1
-        //    l -= 1
-
-        //    if debug {
-        //        log.Printf("KEY NOT FOUND")
-        //    }
-
-        //    continue
-        //}
-
         r.Elems[i] = rr
     }
 
     // buffer is reduced to account for `nil` value returns
     r.Elems = r.Elems[:l]
+}
 
-    if debug {
-        //log.Printf("GODIS: %d == %d %q\n", l, len(r.Elems), r.Elems)
-    }
+// read reads a reply and logs it.
+func (c *conn) read() *Reply {
+    r := c.readReply()
+    c.log.reply(r)
+    return r
 }
 
 func (c *conn) readReply() *Reply {
@@ -288,6 +250,7 @@ func (c *conn) readReply() *Reply {
     res, err := c.r.ReadBytes(lf)
 
     if err != nil {
+        c.log.logf(LevelError, "read: %s", err)
         r.Err = err
         return r
     }
@@ -295,9 +258,8 @@ func (c *conn) readReply() *Reply {
     typ := res[0]
     line := res[1 : len(res)-2]
 
-    if debug {
-        cmdCount[typ]++
-        //log.Printf("CONN: alloc new Reply for `%c` %s\n", typ, string(line))
+    if c.stats != nil {
+        c.stats.reply(typ)
     }
 
     switch typ {
@@ -325,7 +287,6 @@ func newConn(netTyp, addr string, db int, password string) (*conn, error) {
         return nil, errors.New("Connection error " + addr)
     }
 
-    connCount++
     cc := &conn{
         rwc: rwc,
         r:   bufio.NewReader(rwc),
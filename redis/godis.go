@@ -8,7 +8,6 @@ import (
     "errors"
     "fmt"
     "io"
-    "log"
 
     "strings"
 )
@@ -33,6 +32,8 @@ type Sync struct {
     Password string
     net      string
     pool     *pool
+    stats    *stats
+    log      *clientLog
 }
 
 type Pipe struct {
@@ -66,7 +67,7 @@ func newSync(netaddr string, db int, password string) *Sync {
 
     na := strings.SplitN(netaddr, ":", 2)
 
-    return &Sync{Addr: na[1], Db: db, Password: password, net: na[0], pool: newPool()}
+    return &Sync{Addr: na[1], Db: db, Password: password, net: na[0], pool: newPool(), stats: new(stats), log: newClientLog()}
 }
 
 // PipeClient include support for MULTI/EXEC operations. 
@@ -99,7 +100,7 @@ func NewSub(addr string, db int, password string) *Sub {
 // rw interface 
 
 func (c *Sync) read(conn *conn) *Reply {
-    r := conn.readReply()
+    r := conn.read()
 
     if r.Err == io.EOF {
         conn = nil
@@ -119,6 +120,8 @@ func (c *Sync) write(cmd []byte) (conn *conn, err error) {
         return nil, err
     }
 
+    c.stats.command()
+    c.log.command(cmd)
     conn.w.Flush()
     return conn, err
 }
@@ -135,7 +138,21 @@ func (c *Sync) getConn() (*conn, error) {
         return cc, nil
     }
 
-    return newConn(c.net, c.Addr, c.Db, c.Password)
+    cc, err := newConn(c.net, c.Addr, c.Db, c.Password)
+
+    if cc != nil {
+        cc.stats = c.stats
+        cc.log = c.log
+        c.stats.conn()
+    }
+
+    if err != nil {
+        c.log.logf(LevelWarn, "dial %s:%s: %s", c.net, c.Addr, err)
+    } else {
+        c.log.logf(LevelInfo, "connected to %s:%s", c.net, c.Addr)
+    }
+
+    return cc, err
 }
 
 // pipe interface implementation
@@ -145,16 +162,13 @@ func (p *Pipe) read(conn *conn) *Reply {
     }
 
     if p.b.Len() > 0 {
-        if debug {
-            log.Printf("%d bytes were written to socket\n", p.b.Len())
-        }
-
+        p.log.logf(LevelDebug, "flush %d commands, %d bytes", p.replyCount+1, p.b.Len())
         p.conn.w.Write(p.b.Bytes())
         p.conn.w.Flush()
         p.b.Reset()
     }
 
-    reply := conn.readReply()
+    reply := conn.read()
 
     if p.count() == 0 {
         p.free()
@@ -178,6 +192,8 @@ func (p *Pipe) write(cmd []byte) (*conn, error) {
     }
 
     p.replyCount++
+    p.stats.command()
+    p.log.command(cmd)
     p.appendMode = true
     return p.conn, nil
 }
@@ -208,7 +224,7 @@ func (p *Pipe) free() {
 }
 
 func (s *Sub) read(conn *conn) *Reply {
-    return s.conn.readReply()
+    return s.conn.read()
 }
 
 func (s *Sub) write(cmd []byte) (*conn, error) {
@@ -227,6 +243,8 @@ func (s *Sub) write(cmd []byte) (*conn, error) {
         return nil, err
     }
 
+    s.c.stats.command()
+    s.c.log.command(cmd)
     s.conn.w.Flush()
     return s.conn, nil
 }
@@ -15,10 +15,10 @@ func error_(t *testing.T, name string, expected, got interface{}, err error) {
     t.Errorf("`%s` expected `%v` got `%v`, err(%v)", name, expected, got, err)
 }
 
-func printCmdCount() {
-    log.Println("command | count ")
-    for k, v := range cmdCount {
-        log.Printf("      %c | %d\n", k, v)
+func printCmdCount(c *Client) {
+    log.Println("reply | count ")
+    for k, v := range c.Stats().Replies {
+        log.Printf("%5s | %d\n", k, v)
     }
 }
 
@@ -274,11 +274,9 @@ func TestMemory(t *testing.T) {
     }
 
     stop := time.Now().Sub(start)
-    if debug {
-        log.Printf("time: %.2f\n", float32(stop/1.0e+9))
-    }
+    t.Logf("time: %.2f", float32(stop/1.0e+9))
     //time.Sleep(1.0e+9 * 10)
-    //printCmdCount()
+    //printCmdCount(c)
 }
 
 // for this test to work redis.conf has to be set timeout to 1sec
@@ -0,0 +1,169 @@
+package redis
+
+import (
+    "bytes"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "sync/atomic"
+
+    "insmo.com/godis/internal/logging"
+)
+
+// Level is the verbosity of a log message. A message is logged if its
+// level is at most the level of the client.
+type Level = logging.Level
+
+const (
+    LevelOff   = logging.LevelOff
+    LevelError = logging.LevelError
+    LevelWarn  = logging.LevelWarn
+    LevelInfo  = logging.LevelInfo
+    LevelDebug = logging.LevelDebug
+)
+
+// Parses a level name such as "debug" or "WARN".
+func ParseLevel(s string) (Level, bool) {
+    return logging.ParseLevel(s)
+}
+
+// Logger receives the log messages of a client.
+type Logger = logging.Logger
+
+// Returns a Logger which writes to l, or to the standard logger if l is
+// nil.
+func StdLogger(l *log.Logger) Logger {
+    return logging.StdLogger(l)
+}
+
+// Used when LogConfig.MaxArgLen is not set.
+var DefaultMaxArgLen = 64
+
+// LogConfig configures the logging of a client. MaxArgLen is the number of
+// bytes of an argument or reply which are logged, longer values are
+// replaced by their length. A nil Logger is the standard logger, LevelOff
+// turns logging off.
+type LogConfig = logging.Config
+
+// Sets the logger of the client. It must be called before the client is
+// used, SetLogLevel can be called at any time. Without a logger clients
+// log to the standard logger at the level set by the GODIS_LOG environment
+// variable, e.g. GODIS_LOG=debug.
+func (c *Client) SetLogger(config LogConfig) {
+    c.Rw.sync().log.set(config)
+}
+
+// Changes the log level of the client, safe to call while it is in use.
+func (c *Client) SetLogLevel(level Level) {
+    atomic.StoreInt32(&c.Rw.sync().log.level, int32(level))
+}
+
+// clientLog is shared by the connections of a client.
+type clientLog struct {
+    level     int32
+    logger    Logger
+    maxArgLen int
+}
+
+func newClientLog() *clientLog {
+    l := &clientLog{logger: StdLogger(nil), maxArgLen: DefaultMaxArgLen}
+
+    if level, ok := ParseLevel(os.Getenv("GODIS_LOG")); ok {
+        l.level = int32(level)
+    }
+
+    return l
+}
+
+func (l *clientLog) set(config LogConfig) {
+    l.logger = config.Logger
+    l.maxArgLen = config.MaxArgLen
+    atomic.StoreInt32(&l.level, int32(config.Level))
+
+    if l.logger == nil {
+        l.logger = StdLogger(nil)
+    }
+
+    if l.maxArgLen == 0 {
+        l.maxArgLen = DefaultMaxArgLen
+    }
+}
+
+func (l *clientLog) enabled(level Level) bool {
+    return l != nil && Level(atomic.LoadInt32(&l.level)) >= level
+}
+
+func (l *clientLog) logf(level Level, format string, args ...interface{}) {
+    if l.enabled(level) {
+        l.logger.Log(level, fmt.Sprintf(format, args...))
+    }
+}
+
+// command logs a command formatted by buildCmd.
+func (l *clientLog) command(cmd []byte) {
+    if l.enabled(LevelDebug) {
+        l.logger.Log(LevelDebug, "command "+redactCommand(splitCmd(cmd), l.maxArgLen))
+    }
+}
+
+func (l *clientLog) reply(r *Reply) {
+    if l.enabled(LevelDebug) {
+        l.logger.Log(LevelDebug, "reply "+formatReply(r, l.maxArgLen))
+    }
+}
+
+// splitCmd returns the arguments of a command formatted by buildCmd.
+func splitCmd(cmd []byte) [][]byte {
+    var args [][]byte
+
+    for {
+        i := bytes.Index(cmd, delim)
+
+        if i < 0 {
+            return args
+        }
+
+        line := cmd[:i]
+        cmd = cmd[i+2:]
+
+        if len(line) == 0 || line[0] != dollar {
+            continue
+        }
+
+        n, err := strconv.Atoi(string(line[1:]))
+
+        if err != nil || n < 0 || n > len(cmd) {
+            return args
+        }
+
+        args = append(args, cmd[:n])
+        cmd = cmd[n:]
+    }
+}
+
+// redactCommand formats a command for logging. Passwords are hidden and
+// arguments longer than max bytes are replaced by their length.
+func redactCommand(args [][]byte, max int) string {
+    return logging.Redact(args, max)
+}
+
+func formatReply(r *Reply, max int) string {
+    switch {
+    case r.Elems != nil:
+        parts := make([]string, len(r.Elems))
+
+        for i, e := range r.Elems {
+            parts[i] = formatReply(e, max)
+        }
+
+        return "[" + strings.Join(parts, ", ") + "]"
+    case r.Err != nil:
+        return "-" + r.Err.Error()
+    case r.Elem == nil:
+        return "nil"
+    }
+
+    return logging.Truncate(r.Elem, max)
+}
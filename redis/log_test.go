@@ -0,0 +1,56 @@
+package redis
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestRedactCommand(t *testing.T) {
+    tests := []struct {
+        args []string
+        out  string
+    }{
+        {[]string{"get", "foo"}, `GET "foo"`},
+        {[]string{"SET", "foo", strings.Repeat("x", 11)}, `SET "foo" (11 bytes)`},
+        {[]string{"SET", "foo", "a\r\nb"}, `SET "foo" "a\r\nb"`},
+        {[]string{"AUTH", "secret"}, `AUTH (redacted)`},
+        {[]string{"HELLO", "3", "AUTH", "user", "secret"}, `HELLO "3" "AUTH" (redacted) (redacted)`},
+    }
+
+    for _, test := range tests {
+        buf := make([][]byte, len(test.args))
+
+        for i, arg := range test.args {
+            buf[i] = []byte(arg)
+        }
+
+        if out := redactCommand(splitCmd(buildCmd(buf)), 10); out != test.out {
+            t.Errorf("%v: expected %s got %s", test.args, test.out, out)
+        }
+    }
+}
+
+type bufLogger []string
+
+func (b *bufLogger) Log(level Level, msg string) {
+    *b = append(*b, level.String()+" "+msg)
+}
+
+func TestLogger(t *testing.T) {
+    b := &bufLogger{}
+    c := New("", 0, "")
+    c.SetLogger(LogConfig{Logger: b, Level: LevelDebug})
+    c.Set("foo", "bar")
+    c.SetLogLevel(LevelOff)
+    c.Get("foo")
+
+    expected := []string{
+        `INFO connected to tcp:127.0.0.1:6379`,
+        `DEBUG command SET "foo" "bar"`,
+        `DEBUG reply "OK"`,
+    }
+
+    if strings.Join(*b, "\n") != strings.Join(expected, "\n") {
+        t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(*b, "\n"))
+    }
+}
@@ -33,7 +33,7 @@ func TestPoolSimple(t *testing.T) {
 func TestPoolSize(t *testing.T) {
     c1 := New("", 0, "")
     c2 := New("", 0, "")
-    expected := MaxClientConn*2 + connCount
+    expected := int64(MaxClientConn * 2)
 
     if r := SendStr(c1.Rw, "SET", "foo", "foo"); r.Err != nil {
         t.Fatalf("'%s': %s", "SET", r.Err)
@@ -56,7 +56,7 @@ func TestPoolSize(t *testing.T) {
     stop := time.Now().Sub(start)
     t.Logf("time: %.3f\n", float32(stop/1.0e+6)/1000.0)
 
-    if expected != connCount {
-        t.Errorf("connCount: expected %d got %d ", expected, connCount)
+    if conns := c1.Stats().Conns + c2.Stats().Conns; expected != conns {
+        t.Errorf("conns: expected %d got %d ", expected, conns)
     }
 }
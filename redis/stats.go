@@ -0,0 +1,61 @@
+package redis
+
+import (
+    "sync/atomic"
+)
+
+// Stats holds the counters of a Client.
+type Stats struct {
+    // Conns is the number of connections opened
+    Conns int64
+
+    // Commands is the number of commands sent
+    Commands int64
+
+    // Replies counts the replies read by type: "status", "error",
+    // "integer", "bulk" and "array"
+    Replies map[string]int64
+}
+
+var replyTypes = map[byte]string{
+    plus:   "status",
+    minus:  "error",
+    colon:  "integer",
+    dollar: "bulk",
+    star:   "array",
+}
+
+// stats is shared by the connections of a client and updated atomically.
+type stats struct {
+    conns    int64
+    commands int64
+    replies  [256]int64
+}
+
+func (s *stats) conn() {
+    atomic.AddInt64(&s.conns, 1)
+}
+
+func (s *stats) command() {
+    atomic.AddInt64(&s.commands, 1)
+}
+
+func (s *stats) reply(typ byte) {
+    atomic.AddInt64(&s.replies[typ], 1)
+}
+
+// Returns a snapshot of the counters of the client.
+func (c *Client) Stats() Stats {
+    s := c.Rw.sync().stats
+    st := Stats{
+        Conns:    atomic.LoadInt64(&s.conns),
+        Commands: atomic.LoadInt64(&s.commands),
+        Replies:  make(map[string]int64, len(replyTypes)),
+    }
+
+    for typ, name := range replyTypes {
+        st.Replies[name] = atomic.LoadInt64(&s.replies[typ])
+    }
+
+    return st
+}
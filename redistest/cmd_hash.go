@@ -0,0 +1,276 @@
+package redistest
+
+import (
+    "sort"
+    "strconv"
+)
+
+func init() {
+    register("HSET", -4, 0, cmdHset)
+    register("HMSET", -4, 0, cmdHmset)
+    register("HSETNX", 4, 0, cmdHsetnx)
+    register("HGET", 3, 0, cmdHget)
+    register("HMGET", -3, 0, cmdHmget)
+    register("HGETALL", 2, 0, cmdHgetall)
+    register("HDEL", -3, 0, cmdHdel)
+    register("HEXISTS", 3, 0, cmdHexists)
+    register("HLEN", 2, 0, cmdHlen)
+    register("HKEYS", 2, 0, cmdHkeys)
+    register("HVALS", 2, 0, cmdHvals)
+    register("HINCRBY", 4, 0, cmdHincrby)
+    register("HINCRBYFLOAT", 4, 0, cmdHincrbyfloat)
+}
+
+// hset sets field value pairs and returns the number of new fields.
+func hset(c *client, args []string) (int, bool) {
+    if len(args)%2 != 1 {
+        c.error("ERR wrong number of arguments for HMSET")
+        return 0, false
+    }
+
+    h, e := c.database().getHash(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return 0, false
+    }
+
+    n := 0
+
+    for i := 1; i < len(args); i += 2 {
+        if _, ok := h[args[i]]; !ok {
+            n++
+        }
+
+        h[args[i]] = args[i+1]
+    }
+
+    return n, true
+}
+
+func cmdHset(c *client, args []string) {
+    if n, ok := hset(c, args); ok {
+        c.int(int64(n))
+    }
+}
+
+func cmdHmset(c *client, args []string) {
+    if _, ok := hset(c, args); ok {
+        c.ok()
+    }
+}
+
+func cmdHsetnx(c *client, args []string) {
+    h, e := c.database().getHash(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    if _, ok := h[args[1]]; ok {
+        c.int(0)
+        return
+    }
+
+    h[args[1]] = args[2]
+    c.int(1)
+}
+
+func cmdHget(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    if v, ok := h[args[1]]; ok {
+        c.bulk(v)
+    } else {
+        c.null()
+    }
+}
+
+func cmdHmget(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.array(len(args) - 1)
+
+    for _, f := range args[1:] {
+        if v, ok := h[f]; ok {
+            c.bulk(v)
+        } else {
+            c.null()
+        }
+    }
+}
+
+// sortedFields returns the fields of h in a stable order.
+func sortedFields(h hash) []string {
+    fields := make([]string, 0, len(h))
+
+    for f := range h {
+        fields = append(fields, f)
+    }
+
+    sort.Strings(fields)
+    return fields
+}
+
+func cmdHgetall(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.array(len(h) * 2)
+
+    for _, f := range sortedFields(h) {
+        c.bulk(f)
+        c.bulk(h[f])
+    }
+}
+
+func cmdHdel(c *client, args []string) {
+    d := c.database()
+    h, e := d.getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for _, f := range args[1:] {
+        if _, ok := h[f]; ok {
+            delete(h, f)
+            n++
+        }
+    }
+
+    if n > 0 {
+        d.touch(args[0])
+        d.clean(args[0])
+    }
+
+    c.int(int64(n))
+}
+
+func cmdHexists(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    _, ok := h[args[1]]
+    c.bool(ok)
+}
+
+func cmdHlen(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.int(int64(len(h)))
+}
+
+func cmdHkeys(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.strings(sortedFields(h))
+}
+
+func cmdHvals(c *client, args []string) {
+    h, e := c.database().getHash(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    fields := sortedFields(h)
+    c.array(len(fields))
+
+    for _, f := range fields {
+        c.bulk(h[f])
+    }
+}
+
+func cmdHincrby(c *client, args []string) {
+    by, e := parseInt(args[2])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    h, e := c.database().getHash(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := int64(0)
+
+    if v, ok := h[args[1]]; ok {
+        if n, e = parseInt(v); e != nil {
+            c.error("ERR hash value is not an integer")
+            c.database().clean(args[0])
+            return
+        }
+    }
+
+    n += by
+    h[args[1]] = strconv.FormatInt(n, 10)
+    c.int(n)
+}
+
+func cmdHincrbyfloat(c *client, args []string) {
+    by, e := parseFloat(args[2])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    h, e := c.database().getHash(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    f := 0.0
+
+    if v, ok := h[args[1]]; ok {
+        if f, e = parseFloat(v); e != nil {
+            c.error("ERR hash value is not a float")
+            c.database().clean(args[0])
+            return
+        }
+    }
+
+    f += by
+    h[args[1]] = formatFloat(f)
+    c.float(f)
+}
@@ -0,0 +1,378 @@
+package redistest
+
+import (
+    "strings"
+)
+
+func init() {
+    register("LPUSH", -3, 0, cmdLpush)
+    register("RPUSH", -3, 0, cmdRpush)
+    register("LPUSHX", -3, 0, cmdLpushx)
+    register("RPUSHX", -3, 0, cmdRpushx)
+    register("LPOP", 2, 0, cmdLpop)
+    register("RPOP", 2, 0, cmdRpop)
+    register("RPOPLPUSH", 3, 0, cmdRpoplpush)
+    register("LLEN", 2, 0, cmdLlen)
+    register("LRANGE", 4, 0, cmdLrange)
+    register("LINDEX", 3, 0, cmdLindex)
+    register("LSET", 4, 0, cmdLset)
+    register("LREM", 4, 0, cmdLrem)
+    register("LTRIM", 4, 0, cmdLtrim)
+    register("LINSERT", 5, 0, cmdLinsert)
+}
+
+func pushGen(c *client, args []string, left, exists bool) {
+    d := c.database()
+
+    if exists && !d.exists(args[0]) {
+        c.int(0)
+        return
+    }
+
+    l, e := d.getList(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    for _, v := range args[1:] {
+        if left {
+            l.items = append([]string{v}, l.items...)
+        } else {
+            l.items = append(l.items, v)
+        }
+    }
+
+    c.int(int64(len(l.items)))
+}
+
+func cmdLpush(c *client, args []string) {
+    pushGen(c, args, true, false)
+}
+
+func cmdRpush(c *client, args []string) {
+    pushGen(c, args, false, false)
+}
+
+func cmdLpushx(c *client, args []string) {
+    pushGen(c, args, true, true)
+}
+
+func cmdRpushx(c *client, args []string) {
+    pushGen(c, args, false, true)
+}
+
+// pop removes an element from the head or tail of a list.
+func pop(c *client, key string, left bool) (string, bool, error) {
+    d := c.database()
+    l, e := d.getList(key, false)
+
+    if e != nil || l == nil {
+        return "", false, e
+    }
+
+    var v string
+
+    if left {
+        v, l.items = l.items[0], l.items[1:]
+    } else {
+        v, l.items = l.items[len(l.items)-1], l.items[:len(l.items)-1]
+    }
+
+    d.touch(key)
+    d.clean(key)
+    return v, true, nil
+}
+
+func popGen(c *client, key string, left bool) {
+    v, ok, e := pop(c, key, left)
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case !ok:
+        c.null()
+    default:
+        c.bulk(v)
+    }
+}
+
+func cmdLpop(c *client, args []string) {
+    popGen(c, args[0], true)
+}
+
+func cmdRpop(c *client, args []string) {
+    popGen(c, args[0], false)
+}
+
+func cmdRpoplpush(c *client, args []string) {
+    d := c.database()
+
+    if _, e := d.getList(args[1], false); e != nil {
+        c.err(e)
+        return
+    }
+
+    v, ok, e := pop(c, args[0], false)
+
+    switch {
+    case e != nil:
+        c.err(e)
+        return
+    case !ok:
+        c.null()
+        return
+    }
+
+    l, _ := d.getList(args[1], true)
+    l.items = append([]string{v}, l.items...)
+    c.bulk(v)
+}
+
+func cmdLlen(c *client, args []string) {
+    l, e := c.database().getList(args[0], false)
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case l == nil:
+        c.int(0)
+    default:
+        c.int(int64(len(l.items)))
+    }
+}
+
+func cmdLrange(c *client, args []string) {
+    l, e := c.database().getList(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    start, e1 := parseInt(args[1])
+    stop, e2 := parseInt(args[2])
+
+    if e1 != nil || e2 != nil {
+        c.err(errNotInt)
+        return
+    }
+
+    if l == nil {
+        c.array(0)
+        return
+    }
+
+    if i, j, ok := normalize(start, stop, len(l.items)); ok {
+        c.strings(l.items[i:j])
+    } else {
+        c.array(0)
+    }
+}
+
+// index returns the position of a Redis list index, or -1.
+func index(l *list, arg string) (int, error) {
+    i, e := parseInt(arg)
+
+    if e != nil {
+        return 0, e
+    }
+
+    if l == nil {
+        return -1, nil
+    }
+
+    if i < 0 {
+        i += int64(len(l.items))
+    }
+
+    if i < 0 || i >= int64(len(l.items)) {
+        return -1, nil
+    }
+
+    return int(i), nil
+}
+
+func cmdLindex(c *client, args []string) {
+    l, e := c.database().getList(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    i, e := index(l, args[1])
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case i < 0:
+        c.null()
+    default:
+        c.bulk(l.items[i])
+    }
+}
+
+func cmdLset(c *client, args []string) {
+    d := c.database()
+    l, e := d.getList(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    if l == nil {
+        c.err(errNoKey)
+        return
+    }
+
+    i, e := index(l, args[1])
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case i < 0:
+        c.err(errOutOfRange)
+    default:
+        l.items[i] = args[2]
+        d.touch(args[0])
+        c.ok()
+    }
+}
+
+func cmdLrem(c *client, args []string) {
+    d := c.database()
+    count, e := parseInt(args[1])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    l, e := d.getList(args[0], false)
+
+    if e != nil || l == nil {
+        if e != nil {
+            c.err(e)
+        } else {
+            c.int(0)
+        }
+
+        return
+    }
+
+    items := l.items
+    reverse := count < 0
+
+    if reverse {
+        count = -count
+        items = reversed(items)
+    }
+
+    kept := make([]string, 0, len(items))
+    n := int64(0)
+
+    for _, v := range items {
+        if v == args[2] && (count == 0 || n < count) {
+            n++
+            continue
+        }
+
+        kept = append(kept, v)
+    }
+
+    if reverse {
+        kept = reversed(kept)
+    }
+
+    l.items = kept
+
+    if n > 0 {
+        d.touch(args[0])
+        d.clean(args[0])
+    }
+
+    c.int(n)
+}
+
+func reversed(a []string) []string {
+    b := make([]string, len(a))
+
+    for i, v := range a {
+        b[len(a)-1-i] = v
+    }
+
+    return b
+}
+
+func cmdLtrim(c *client, args []string) {
+    d := c.database()
+    l, e := d.getList(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    start, e1 := parseInt(args[1])
+    stop, e2 := parseInt(args[2])
+
+    if e1 != nil || e2 != nil {
+        c.err(errNotInt)
+        return
+    }
+
+    if l != nil {
+        if i, j, ok := normalize(start, stop, len(l.items)); ok {
+            l.items = l.items[i:j]
+        } else {
+            l.items = nil
+        }
+
+        d.touch(args[0])
+        d.clean(args[0])
+    }
+
+    c.ok()
+}
+
+func cmdLinsert(c *client, args []string) {
+    d := c.database()
+    where := strings.ToUpper(args[1])
+
+    if where != "BEFORE" && where != "AFTER" {
+        c.err(errSyntax)
+        return
+    }
+
+    l, e := d.getList(args[0], false)
+
+    if e != nil || l == nil {
+        if e != nil {
+            c.err(e)
+        } else {
+            c.int(0)
+        }
+
+        return
+    }
+
+    for i, v := range l.items {
+        if v != args[2] {
+            continue
+        }
+
+        if where == "AFTER" {
+            i++
+        }
+
+        l.items = append(l.items[:i], append([]string{args[3]}, l.items[i:]...)...)
+        d.touch(args[0])
+        c.int(int64(len(l.items)))
+        return
+    }
+
+    c.int(-1)
+}
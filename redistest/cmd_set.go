@@ -0,0 +1,272 @@
+package redistest
+
+import (
+    "sort"
+)
+
+func init() {
+    register("SADD", -3, 0, cmdSadd)
+    register("SREM", -3, 0, cmdSrem)
+    register("SMEMBERS", 2, 0, cmdSmembers)
+    register("SISMEMBER", 3, 0, cmdSismember)
+    register("SCARD", 2, 0, cmdScard)
+    register("SPOP", 2, 0, cmdSpop)
+    register("SRANDMEMBER", 2, 0, cmdSrandmember)
+    register("SMOVE", 4, 0, cmdSmove)
+    register("SINTER", -2, 0, cmdSinter)
+    register("SUNION", -2, 0, cmdSunion)
+    register("SDIFF", -2, 0, cmdSdiff)
+    register("SINTERSTORE", -3, 0, cmdSinterstore)
+    register("SUNIONSTORE", -3, 0, cmdSunionstore)
+    register("SDIFFSTORE", -3, 0, cmdSdiffstore)
+}
+
+func members(s set) []string {
+    m := make([]string, 0, len(s))
+
+    for v := range s {
+        m = append(m, v)
+    }
+
+    sort.Strings(m)
+    return m
+}
+
+func cmdSadd(c *client, args []string) {
+    s, e := c.database().getSet(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for _, v := range args[1:] {
+        if !s[v] {
+            s[v] = true
+            n++
+        }
+    }
+
+    c.int(int64(n))
+}
+
+func cmdSrem(c *client, args []string) {
+    d := c.database()
+    s, e := d.getSet(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for _, v := range args[1:] {
+        if s[v] {
+            delete(s, v)
+            n++
+        }
+    }
+
+    if n > 0 {
+        d.touch(args[0])
+        d.clean(args[0])
+    }
+
+    c.int(int64(n))
+}
+
+func cmdSmembers(c *client, args []string) {
+    s, e := c.database().getSet(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.strings(members(s))
+}
+
+func cmdSismember(c *client, args []string) {
+    s, e := c.database().getSet(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.bool(s[args[1]])
+}
+
+func cmdScard(c *client, args []string) {
+    s, e := c.database().getSet(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.int(int64(len(s)))
+}
+
+// random returns any member of a non-empty set.
+func random(s set) string {
+    for v := range s {
+        return v
+    }
+
+    return ""
+}
+
+func cmdSpop(c *client, args []string) {
+    d := c.database()
+    s, e := d.getSet(args[0], false)
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case len(s) == 0:
+        c.null()
+    default:
+        v := random(s)
+        delete(s, v)
+        d.touch(args[0])
+        d.clean(args[0])
+        c.bulk(v)
+    }
+}
+
+func cmdSrandmember(c *client, args []string) {
+    s, e := c.database().getSet(args[0], false)
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case len(s) == 0:
+        c.null()
+    default:
+        c.bulk(random(s))
+    }
+}
+
+func cmdSmove(c *client, args []string) {
+    d := c.database()
+    src, e := d.getSet(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    if _, e = d.getSet(args[1], false); e != nil {
+        c.err(e)
+        return
+    }
+
+    if !src[args[2]] {
+        c.int(0)
+        return
+    }
+
+    delete(src, args[2])
+    d.touch(args[0])
+    d.clean(args[0])
+
+    dst, _ := d.getSet(args[1], true)
+    dst[args[2]] = true
+    c.int(1)
+}
+
+// combine computes the intersection, union or difference of sets.
+func combine(c *client, op string, keys []string) (set, error) {
+    var res set
+
+    for i, k := range keys {
+        s, e := c.database().getSet(k, false)
+
+        if e != nil {
+            return nil, e
+        }
+
+        if i == 0 {
+            res = make(set, len(s))
+
+            for v := range s {
+                res[v] = true
+            }
+
+            continue
+        }
+
+        switch op {
+        case "inter":
+            for v := range res {
+                if !s[v] {
+                    delete(res, v)
+                }
+            }
+        case "union":
+            for v := range s {
+                res[v] = true
+            }
+        case "diff":
+            for v := range s {
+                delete(res, v)
+            }
+        }
+    }
+
+    return res, nil
+}
+
+func combineGen(c *client, op string, keys []string) {
+    if s, e := combine(c, op, keys); e != nil {
+        c.err(e)
+    } else {
+        c.strings(members(s))
+    }
+}
+
+func combineStoreGen(c *client, op string, args []string) {
+    s, e := combine(c, op, args[1:])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    d := c.database()
+    d.del(args[0])
+
+    if len(s) > 0 {
+        d.set(args[0], s)
+    }
+
+    c.int(int64(len(s)))
+}
+
+func cmdSinter(c *client, args []string) {
+    combineGen(c, "inter", args)
+}
+
+func cmdSunion(c *client, args []string) {
+    combineGen(c, "union", args)
+}
+
+func cmdSdiff(c *client, args []string) {
+    combineGen(c, "diff", args)
+}
+
+func cmdSinterstore(c *client, args []string) {
+    combineStoreGen(c, "inter", args)
+}
+
+func cmdSunionstore(c *client, args []string) {
+    combineStoreGen(c, "union", args)
+}
+
+func cmdSdiffstore(c *client, args []string) {
+    combineStoreGen(c, "diff", args)
+}
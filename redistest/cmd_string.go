@@ -0,0 +1,381 @@
+package redistest
+
+import (
+    "strconv"
+    "strings"
+    "time"
+)
+
+func init() {
+    register("GET", 2, 0, cmdGet)
+    register("SET", -3, 0, cmdSet)
+    register("SETNX", 3, 0, cmdSetnx)
+    register("SETEX", 4, 0, cmdSetex)
+    register("PSETEX", 4, 0, cmdPsetex)
+    register("GETSET", 3, 0, cmdGetset)
+    register("MGET", -2, 0, cmdMget)
+    register("MSET", -3, 0, cmdMset)
+    register("MSETNX", -3, 0, cmdMsetnx)
+    register("INCR", 2, 0, cmdIncr)
+    register("INCRBY", 3, 0, cmdIncrby)
+    register("DECR", 2, 0, cmdDecr)
+    register("DECRBY", 3, 0, cmdDecrby)
+    register("INCRBYFLOAT", 3, 0, cmdIncrbyfloat)
+    register("APPEND", 3, 0, cmdAppend)
+    register("STRLEN", 2, 0, cmdStrlen)
+    register("GETRANGE", 4, 0, cmdGetrange)
+    register("SETRANGE", 4, 0, cmdSetrange)
+}
+
+func cmdGet(c *client, args []string) {
+    s, ok, e := c.database().getString(args[0])
+
+    switch {
+    case e != nil:
+        c.err(e)
+    case !ok:
+        c.null()
+    default:
+        c.bulk(s)
+    }
+}
+
+// SET key value [EX seconds|PX milliseconds] [NX|XX] [GET]
+func cmdSet(c *client, args []string) {
+    d := c.database()
+    key, value := args[0], args[1]
+    var ttl time.Duration
+    var nx, xx, get bool
+
+    for i := 2; i < len(args); i++ {
+        switch opt := strings.ToUpper(args[i]); opt {
+        case "NX":
+            nx = true
+        case "XX":
+            xx = true
+        case "GET":
+            get = true
+        case "EX", "PX":
+            if i+1 >= len(args) {
+                c.err(errSyntax)
+                return
+            }
+
+            i++
+            n, e := parseInt(args[i])
+
+            if e != nil || n <= 0 {
+                c.error("ERR invalid expire time in 'set' command")
+                return
+            }
+
+            ttl = time.Duration(n) * time.Second
+
+            if opt == "PX" {
+                ttl = time.Duration(n) * time.Millisecond
+            }
+        default:
+            c.err(errSyntax)
+            return
+        }
+    }
+
+    if nx && xx {
+        c.err(errSyntax)
+        return
+    }
+
+    old, exists, e := d.getString(key)
+
+    if e != nil && get {
+        c.err(e)
+        return
+    }
+
+    if (nx && d.exists(key)) || (xx && !d.exists(key)) {
+        if get && exists {
+            c.bulk(old)
+        } else {
+            c.null()
+        }
+
+        return
+    }
+
+    d.set(key, value)
+
+    if ttl > 0 {
+        d.setExpire(key, c.s.now().Add(ttl))
+    }
+
+    switch {
+    case !get:
+        c.ok()
+    case exists:
+        c.bulk(old)
+    default:
+        c.null()
+    }
+}
+
+func cmdSetnx(c *client, args []string) {
+    d := c.database()
+
+    if d.exists(args[0]) {
+        c.int(0)
+        return
+    }
+
+    d.set(args[0], args[1])
+    c.int(1)
+}
+
+func setexGen(c *client, args []string, unit time.Duration) {
+    n, e := parseInt(args[1])
+
+    if e != nil || n <= 0 {
+        c.error("ERR invalid expire time")
+        return
+    }
+
+    d := c.database()
+    d.set(args[0], args[2])
+    d.setExpire(args[0], c.s.now().Add(time.Duration(n)*unit))
+    c.ok()
+}
+
+func cmdSetex(c *client, args []string) {
+    setexGen(c, args, time.Second)
+}
+
+func cmdPsetex(c *client, args []string) {
+    setexGen(c, args, time.Millisecond)
+}
+
+func cmdGetset(c *client, args []string) {
+    d := c.database()
+    old, ok, e := d.getString(args[0])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    d.set(args[0], args[1])
+
+    if ok {
+        c.bulk(old)
+    } else {
+        c.null()
+    }
+}
+
+func cmdMget(c *client, args []string) {
+    c.array(len(args))
+
+    for _, k := range args {
+        // MGET returns nil for keys of other types
+        if s, ok, _ := c.database().getString(k); ok {
+            c.bulk(s)
+        } else {
+            c.null()
+        }
+    }
+}
+
+func cmdMset(c *client, args []string) {
+    if len(args)%2 != 0 {
+        c.error("ERR wrong number of arguments for 'mset' command")
+        return
+    }
+
+    for i := 0; i < len(args); i += 2 {
+        c.database().set(args[i], args[i+1])
+    }
+
+    c.ok()
+}
+
+func cmdMsetnx(c *client, args []string) {
+    if len(args)%2 != 0 {
+        c.error("ERR wrong number of arguments for 'msetnx' command")
+        return
+    }
+
+    d := c.database()
+
+    for i := 0; i < len(args); i += 2 {
+        if d.exists(args[i]) {
+            c.int(0)
+            return
+        }
+    }
+
+    for i := 0; i < len(args); i += 2 {
+        d.set(args[i], args[i+1])
+    }
+
+    c.int(1)
+}
+
+func incrGen(c *client, key string, by int64) {
+    d := c.database()
+    s, ok, e := d.getString(key)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := int64(0)
+
+    if ok {
+        if n, e = parseInt(s); e != nil {
+            c.err(e)
+            return
+        }
+    }
+
+    n += by
+    d.keys[key] = strconv.FormatInt(n, 10)
+    d.touch(key)
+    c.int(n)
+}
+
+func cmdIncr(c *client, args []string) {
+    incrGen(c, args[0], 1)
+}
+
+func cmdDecr(c *client, args []string) {
+    incrGen(c, args[0], -1)
+}
+
+func cmdIncrby(c *client, args []string) {
+    if n, e := parseInt(args[1]); e != nil {
+        c.err(e)
+    } else {
+        incrGen(c, args[0], n)
+    }
+}
+
+func cmdDecrby(c *client, args []string) {
+    if n, e := parseInt(args[1]); e != nil {
+        c.err(e)
+    } else {
+        incrGen(c, args[0], -n)
+    }
+}
+
+func cmdIncrbyfloat(c *client, args []string) {
+    d := c.database()
+    by, e := parseFloat(args[1])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    s, ok, e := d.getString(args[0])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    f := 0.0
+
+    if ok {
+        if f, e = parseFloat(s); e != nil {
+            c.err(e)
+            return
+        }
+    }
+
+    f += by
+    d.keys[args[0]] = formatFloat(f)
+    d.touch(args[0])
+    c.float(f)
+}
+
+func cmdAppend(c *client, args []string) {
+    d := c.database()
+    s, _, e := d.getString(args[0])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    s += args[1]
+    d.keys[args[0]] = s
+    d.touch(args[0])
+    c.int(int64(len(s)))
+}
+
+func cmdStrlen(c *client, args []string) {
+    s, _, e := c.database().getString(args[0])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.int(int64(len(s)))
+}
+
+func cmdGetrange(c *client, args []string) {
+    s, _, e := c.database().getString(args[0])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    start, e1 := parseInt(args[1])
+    stop, e2 := parseInt(args[2])
+
+    if e1 != nil || e2 != nil {
+        c.err(errNotInt)
+        return
+    }
+
+    if i, j, ok := normalize(start, stop, len(s)); ok {
+        c.bulk(s[i:j])
+    } else {
+        c.bulk("")
+    }
+}
+
+func cmdSetrange(c *client, args []string) {
+    d := c.database()
+    s, _, e := d.getString(args[0])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    offset, e := parseInt(args[1])
+
+    if e != nil || offset < 0 || offset > 512*1024*1024 {
+        c.error("ERR offset is out of range")
+        return
+    }
+
+    if args[2] == "" {
+        c.int(int64(len(s)))
+        return
+    }
+
+    b := []byte(s)
+    end := int(offset) + len(args[2])
+
+    if end > len(b) {
+        b = append(b, make([]byte, end-len(b))...)
+    }
+
+    copy(b[offset:], args[2])
+    d.keys[args[0]] = string(b)
+    d.touch(args[0])
+    c.int(int64(len(b)))
+}
@@ -0,0 +1,671 @@
+package redistest
+
+import (
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+func init() {
+    register("ZADD", -4, 0, cmdZadd)
+    register("ZREM", -3, 0, cmdZrem)
+    register("ZSCORE", 3, 0, cmdZscore)
+    register("ZINCRBY", 4, 0, cmdZincrby)
+    register("ZCARD", 2, 0, cmdZcard)
+    register("ZCOUNT", 4, 0, cmdZcount)
+    register("ZRANK", 3, 0, cmdZrank)
+    register("ZREVRANK", 3, 0, cmdZrevrank)
+    register("ZRANGE", -4, 0, cmdZrange)
+    register("ZREVRANGE", -4, 0, cmdZrevrange)
+    register("ZRANGEBYSCORE", -4, 0, cmdZrangebyscore)
+    register("ZREVRANGEBYSCORE", -4, 0, cmdZrevrangebyscore)
+    register("ZREMRANGEBYRANK", 4, 0, cmdZremrangebyrank)
+    register("ZREMRANGEBYSCORE", 4, 0, cmdZremrangebyscore)
+    register("ZINTERSTORE", -4, 0, cmdZinterstore)
+    register("ZUNIONSTORE", -4, 0, cmdZunionstore)
+}
+
+type zmember struct {
+    member string
+    score  float64
+}
+
+// sorted returns the members ordered by score, then member.
+func sorted(z zset, reverse bool) []zmember {
+    m := make([]zmember, 0, len(z))
+
+    for k, v := range z {
+        m = append(m, zmember{k, v})
+    }
+
+    sort.Slice(m, func(i, j int) bool {
+        if m[i].score != m[j].score {
+            return m[i].score < m[j].score
+        }
+
+        return m[i].member < m[j].member
+    })
+
+    if reverse {
+        for i, j := 0, len(m)-1; i < j; i, j = i+1, j-1 {
+            m[i], m[j] = m[j], m[i]
+        }
+    }
+
+    return m
+}
+
+func (c *client) zmembers(m []zmember, withScores bool) {
+    if withScores {
+        c.array(len(m) * 2)
+    } else {
+        c.array(len(m))
+    }
+
+    for _, v := range m {
+        c.bulk(v.member)
+
+        if withScores {
+            c.float(v.score)
+        }
+    }
+}
+
+// ZADD key [NX|XX] [CH] [INCR] score member [score member ...]
+func cmdZadd(c *client, args []string) {
+    var nx, xx, ch, incr bool
+    i := 1
+
+options:
+    for ; i < len(args); i++ {
+        switch strings.ToUpper(args[i]) {
+        case "NX":
+            nx = true
+        case "XX":
+            xx = true
+        case "CH":
+            ch = true
+        case "INCR":
+            incr = true
+        default:
+            break options
+        }
+    }
+
+    pairs := args[i:]
+
+    if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (incr && len(pairs) != 2) {
+        c.err(errSyntax)
+        return
+    }
+
+    scores := make([]float64, len(pairs)/2)
+
+    for j := range scores {
+        f, e := parseFloat(pairs[j*2])
+
+        if e != nil {
+            c.err(e)
+            return
+        }
+
+        scores[j] = f
+    }
+
+    d := c.database()
+    z, e := d.getZset(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for j, score := range scores {
+        member := pairs[j*2+1]
+        old, exists := z[member]
+
+        if (nx && exists) || (xx && !exists) {
+            if incr {
+                d.clean(args[0])
+                c.null()
+                return
+            }
+
+            continue
+        }
+
+        if incr {
+            score += old
+        }
+
+        if !exists || (ch && old != score) {
+            n++
+        }
+
+        z[member] = score
+
+        if incr {
+            c.float(score)
+            return
+        }
+    }
+
+    d.clean(args[0])
+    c.int(int64(n))
+}
+
+func cmdZrem(c *client, args []string) {
+    d := c.database()
+    z, e := d.getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for _, m := range args[1:] {
+        if _, ok := z[m]; ok {
+            delete(z, m)
+            n++
+        }
+    }
+
+    if n > 0 {
+        d.touch(args[0])
+        d.clean(args[0])
+    }
+
+    c.int(int64(n))
+}
+
+func cmdZscore(c *client, args []string) {
+    z, e := c.database().getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    if f, ok := z[args[1]]; ok {
+        c.float(f)
+    } else {
+        c.null()
+    }
+}
+
+func cmdZincrby(c *client, args []string) {
+    by, e := parseFloat(args[1])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    z, e := c.database().getZset(args[0], true)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    z[args[2]] += by
+    c.float(z[args[2]])
+}
+
+func cmdZcard(c *client, args []string) {
+    z, e := c.database().getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    c.int(int64(len(z)))
+}
+
+// scoreRange is a parsed min/max pair like `(1 +inf`.
+type scoreRange struct {
+    min, max         float64
+    minExcl, maxExcl bool
+}
+
+func parseBound(s string) (float64, bool, error) {
+    excl := strings.HasPrefix(s, "(")
+
+    if excl {
+        s = s[1:]
+    }
+
+    f, e := parseFloat(s)
+
+    if e != nil {
+        return 0, false, errMinMax
+    }
+
+    return f, excl, nil
+}
+
+func parseRange(min, max string) (*scoreRange, error) {
+    var r scoreRange
+    var e error
+
+    if r.min, r.minExcl, e = parseBound(min); e != nil {
+        return nil, e
+    }
+
+    if r.max, r.maxExcl, e = parseBound(max); e != nil {
+        return nil, e
+    }
+
+    return &r, nil
+}
+
+func (r *scoreRange) contains(f float64) bool {
+    if f < r.min || (r.minExcl && f == r.min) {
+        return false
+    }
+
+    if f > r.max || (r.maxExcl && f == r.max) {
+        return false
+    }
+
+    return true
+}
+
+func cmdZcount(c *client, args []string) {
+    z, e := c.database().getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    r, e := parseRange(args[1], args[2])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for _, f := range z {
+        if r.contains(f) {
+            n++
+        }
+    }
+
+    c.int(int64(n))
+}
+
+func rankGen(c *client, args []string, reverse bool) {
+    z, e := c.database().getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    for i, m := range sorted(z, reverse) {
+        if m.member == args[1] {
+            c.int(int64(i))
+            return
+        }
+    }
+
+    c.null()
+}
+
+func cmdZrank(c *client, args []string) {
+    rankGen(c, args, false)
+}
+
+func cmdZrevrank(c *client, args []string) {
+    rankGen(c, args, true)
+}
+
+func rangeGen(c *client, args []string, reverse bool) {
+    z, e := c.database().getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    start, e1 := parseInt(args[1])
+    stop, e2 := parseInt(args[2])
+
+    if e1 != nil || e2 != nil {
+        c.err(errNotInt)
+        return
+    }
+
+    withScores := false
+
+    for _, opt := range args[3:] {
+        if strings.ToUpper(opt) != "WITHSCORES" {
+            c.err(errSyntax)
+            return
+        }
+
+        withScores = true
+    }
+
+    m := sorted(z, reverse)
+
+    if i, j, ok := normalize(start, stop, len(m)); ok {
+        c.zmembers(m[i:j], withScores)
+    } else {
+        c.array(0)
+    }
+}
+
+func cmdZrange(c *client, args []string) {
+    rangeGen(c, args, false)
+}
+
+func cmdZrevrange(c *client, args []string) {
+    rangeGen(c, args, true)
+}
+
+// rangeByScoreGen implements ZRANGEBYSCORE and ZREVRANGEBYSCORE, the latter
+// takes max before min.
+func rangeByScoreGen(c *client, args []string, reverse bool) {
+    z, e := c.database().getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    min, max := args[1], args[2]
+
+    if reverse {
+        min, max = max, min
+    }
+
+    r, e := parseRange(min, max)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    withScores := false
+    offset, count := int64(0), int64(-1)
+
+    for i := 3; i < len(args); i++ {
+        switch strings.ToUpper(args[i]) {
+        case "WITHSCORES":
+            withScores = true
+        case "LIMIT":
+            if i+2 >= len(args) {
+                c.err(errSyntax)
+                return
+            }
+
+            var e1, e2 error
+            offset, e1 = parseInt(args[i+1])
+            count, e2 = parseInt(args[i+2])
+
+            if e1 != nil || e2 != nil {
+                c.err(errNotInt)
+                return
+            }
+
+            i += 2
+        default:
+            c.err(errSyntax)
+            return
+        }
+    }
+
+    res := []zmember{}
+
+    for _, m := range sorted(z, reverse) {
+        if !r.contains(m.score) {
+            continue
+        }
+
+        if offset > 0 {
+            offset--
+            continue
+        }
+
+        if count == 0 || offset < 0 {
+            break
+        }
+
+        res = append(res, m)
+        count--
+    }
+
+    c.zmembers(res, withScores)
+}
+
+func cmdZrangebyscore(c *client, args []string) {
+    rangeByScoreGen(c, args, false)
+}
+
+func cmdZrevrangebyscore(c *client, args []string) {
+    rangeByScoreGen(c, args, true)
+}
+
+func cmdZremrangebyrank(c *client, args []string) {
+    d := c.database()
+    z, e := d.getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    start, e1 := parseInt(args[1])
+    stop, e2 := parseInt(args[2])
+
+    if e1 != nil || e2 != nil {
+        c.err(errNotInt)
+        return
+    }
+
+    m := sorted(z, false)
+    i, j, ok := normalize(start, stop, len(m))
+
+    if !ok {
+        c.int(0)
+        return
+    }
+
+    for _, v := range m[i:j] {
+        delete(z, v.member)
+    }
+
+    d.touch(args[0])
+    d.clean(args[0])
+    c.int(int64(j - i))
+}
+
+func cmdZremrangebyscore(c *client, args []string) {
+    d := c.database()
+    z, e := d.getZset(args[0], false)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    r, e := parseRange(args[1], args[2])
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    n := 0
+
+    for m, f := range z {
+        if r.contains(f) {
+            delete(z, m)
+            n++
+        }
+    }
+
+    if n > 0 {
+        d.touch(args[0])
+        d.clean(args[0])
+    }
+
+    c.int(int64(n))
+}
+
+// ZINTERSTORE/ZUNIONSTORE dst numkeys key [key ...] [WEIGHTS w [w ...]]
+// [AGGREGATE SUM|MIN|MAX]. Plain sets count as members with score 1.
+func zstoreGen(c *client, args []string, inter bool) {
+    n, e := strconv.Atoi(args[1])
+
+    if e != nil || n < 1 || len(args) < 2+n {
+        c.err(errSyntax)
+        return
+    }
+
+    keys := args[2 : 2+n]
+    weights := make([]float64, n)
+    aggregate := "SUM"
+
+    for i := range weights {
+        weights[i] = 1
+    }
+
+    for i := 2 + n; i < len(args); i++ {
+        switch strings.ToUpper(args[i]) {
+        case "WEIGHTS":
+            if i+n >= len(args) {
+                c.err(errSyntax)
+                return
+            }
+
+            for j := range weights {
+                if weights[j], e = parseFloat(args[i+1+j]); e != nil {
+                    c.error("ERR weight value is not a float")
+                    return
+                }
+            }
+
+            i += n
+        case "AGGREGATE":
+            if i+1 >= len(args) {
+                c.err(errSyntax)
+                return
+            }
+
+            aggregate = strings.ToUpper(args[i+1])
+
+            if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
+                c.err(errSyntax)
+                return
+            }
+
+            i++
+        default:
+            c.err(errSyntax)
+            return
+        }
+    }
+
+    d := c.database()
+    var res zset
+
+    for i, k := range keys {
+        z, e := readZset(d, k)
+
+        if e != nil {
+            c.err(e)
+            return
+        }
+
+        next := make(zset)
+
+        for m, f := range z {
+            f *= weights[i]
+
+            if i == 0 {
+                next[m] = f
+                continue
+            }
+
+            old, ok := res[m]
+
+            if !ok {
+                if !inter {
+                    next[m] = f
+                }
+
+                continue
+            }
+
+            switch aggregate {
+            case "SUM":
+                next[m] = old + f
+            case "MIN":
+                next[m] = math.Min(old, f)
+            case "MAX":
+                next[m] = math.Max(old, f)
+            }
+        }
+
+        if !inter && i > 0 {
+            for m, f := range res {
+                if _, ok := next[m]; !ok {
+                    next[m] = f
+                }
+            }
+        }
+
+        res = next
+    }
+
+    d.del(args[0])
+
+    if len(res) > 0 {
+        d.set(args[0], res)
+    }
+
+    c.int(int64(len(res)))
+}
+
+// readZset reads a sorted set or a set as a sorted set.
+func readZset(d *db, key string) (zset, error) {
+    v, ok := d.get(key)
+
+    if !ok {
+        return nil, nil
+    }
+
+    switch v := v.(type) {
+    case zset:
+        return v, nil
+    case set:
+        z := make(zset, len(v))
+
+        for m := range v {
+            z[m] = 1
+        }
+
+        return z, nil
+    }
+
+    return nil, errWrongType
+}
+
+func cmdZinterstore(c *client, args []string) {
+    zstoreGen(c, args, true)
+}
+
+func cmdZunionstore(c *client, args []string) {
+    zstoreGen(c, args, false)
+}
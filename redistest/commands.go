@@ -0,0 +1,357 @@
+package redistest
+
+import (
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    // flagNoQueue commands are executed directly inside MULTI
+    flagNoQueue = 1 << iota
+    // flagPubsub commands are allowed in subscribed state
+    flagPubsub
+)
+
+type command struct {
+    // arity includes the command name. A negative arity means at least
+    // -arity arguments.
+    arity int
+    flags int
+    fn    func(c *client, args []string)
+}
+
+var commands = map[string]*command{}
+
+func register(name string, arity, flags int, fn func(c *client, args []string)) {
+    commands[name] = &command{arity, flags, fn}
+}
+
+func init() {
+    // connection
+    register("AUTH", 2, 0, cmdAuth)
+    register("ECHO", 2, 0, cmdEcho)
+    register("PING", -1, flagPubsub, cmdPing)
+    register("QUIT", 1, flagPubsub|flagNoQueue, cmdQuit)
+    register("SELECT", 2, 0, cmdSelect)
+
+    // server
+    register("DBSIZE", 1, 0, cmdDbsize)
+    register("FLUSHALL", -1, 0, cmdFlushall)
+    register("FLUSHDB", -1, 0, cmdFlushdb)
+    register("TIME", 1, 0, cmdTime)
+
+    // keys
+    register("DEL", -2, 0, cmdDel)
+    register("UNLINK", -2, 0, cmdDel)
+    register("EXISTS", -2, 0, cmdExists)
+    register("EXPIRE", 3, 0, cmdExpire)
+    register("PEXPIRE", 3, 0, cmdPexpire)
+    register("EXPIREAT", 3, 0, cmdExpireat)
+    register("PEXPIREAT", 3, 0, cmdPexpireat)
+    register("PERSIST", 2, 0, cmdPersist)
+    register("TTL", 2, 0, cmdTtl)
+    register("PTTL", 2, 0, cmdPttl)
+    register("KEYS", 2, 0, cmdKeys)
+    register("SCAN", -2, 0, cmdScan)
+    register("RANDOMKEY", 1, 0, cmdRandomkey)
+    register("RENAME", 3, 0, cmdRename)
+    register("RENAMENX", 3, 0, cmdRenamenx)
+    register("TYPE", 2, 0, cmdType)
+}
+
+func cmdAuth(c *client, args []string) {
+    switch {
+    case c.s.Password == "":
+        c.error("ERR Client sent AUTH, but no password is set")
+    case c.s.Password != args[0]:
+        c.error("WRONGPASS invalid username-password pair")
+    default:
+        c.authd = true
+        c.ok()
+    }
+}
+
+func cmdEcho(c *client, args []string) {
+    c.bulk(args[0])
+}
+
+func cmdPing(c *client, args []string) {
+    if len(c.channels)+len(c.patterns) > 0 {
+        msg := ""
+
+        if len(args) > 0 {
+            msg = args[0]
+        }
+
+        c.strings([]string{"pong", msg})
+        return
+    }
+
+    if len(args) > 0 {
+        c.bulk(args[0])
+        return
+    }
+
+    c.status("PONG")
+}
+
+func cmdQuit(c *client, args []string) {
+    c.ok()
+}
+
+func cmdSelect(c *client, args []string) {
+    n, e := strconv.Atoi(args[0])
+
+    if e != nil || n < 0 || n >= Databases {
+        c.error("ERR DB index is out of range")
+        return
+    }
+
+    c.db = n
+    c.ok()
+}
+
+func cmdDbsize(c *client, args []string) {
+    c.int(int64(len(c.database().sortedKeys("*"))))
+}
+
+func cmdFlushall(c *client, args []string) {
+    for _, d := range c.s.dbs {
+        d.flush()
+    }
+
+    c.ok()
+}
+
+func cmdFlushdb(c *client, args []string) {
+    c.database().flush()
+    c.ok()
+}
+
+func cmdTime(c *client, args []string) {
+    now := c.s.now()
+    c.strings([]string{
+        strconv.FormatInt(now.Unix(), 10),
+        strconv.Itoa(now.Nanosecond() / 1000),
+    })
+}
+
+func cmdDel(c *client, args []string) {
+    n := 0
+
+    for _, k := range args {
+        if c.database().del(k) {
+            n++
+        }
+    }
+
+    c.int(int64(n))
+}
+
+func cmdExists(c *client, args []string) {
+    n := 0
+
+    for _, k := range args {
+        if c.database().exists(k) {
+            n++
+        }
+    }
+
+    c.int(int64(n))
+}
+
+func expireGen(c *client, key, arg string, fn func(n int64) time.Time) {
+    n, e := parseInt(arg)
+
+    if e != nil {
+        c.err(e)
+        return
+    }
+
+    d := c.database()
+
+    if !d.exists(key) {
+        c.int(0)
+        return
+    }
+
+    d.setExpire(key, fn(n))
+    c.int(1)
+}
+
+func cmdExpire(c *client, args []string) {
+    expireGen(c, args[0], args[1], func(n int64) time.Time {
+        return c.s.now().Add(time.Duration(n) * time.Second)
+    })
+}
+
+func cmdPexpire(c *client, args []string) {
+    expireGen(c, args[0], args[1], func(n int64) time.Time {
+        return c.s.now().Add(time.Duration(n) * time.Millisecond)
+    })
+}
+
+func cmdExpireat(c *client, args []string) {
+    expireGen(c, args[0], args[1], func(n int64) time.Time {
+        return time.Unix(n, 0)
+    })
+}
+
+func cmdPexpireat(c *client, args []string) {
+    expireGen(c, args[0], args[1], func(n int64) time.Time {
+        return time.Unix(0, n*int64(time.Millisecond))
+    })
+}
+
+func cmdPersist(c *client, args []string) {
+    d := c.database()
+
+    if _, ok := d.expires[args[0]]; !ok || !d.exists(args[0]) {
+        c.int(0)
+        return
+    }
+
+    delete(d.expires, args[0])
+    d.touch(args[0])
+    c.int(1)
+}
+
+func cmdTtl(c *client, args []string) {
+    ttl := c.database().ttl(args[0])
+
+    if ttl < 0 {
+        c.int(int64(ttl))
+        return
+    }
+
+    // round up like Redis does
+    c.int(int64((ttl + time.Second - 1) / time.Second))
+}
+
+func cmdPttl(c *client, args []string) {
+    ttl := c.database().ttl(args[0])
+
+    if ttl < 0 {
+        c.int(int64(ttl))
+        return
+    }
+
+    c.int(int64(ttl / time.Millisecond))
+}
+
+func cmdKeys(c *client, args []string) {
+    c.strings(c.database().sortedKeys(args[0]))
+}
+
+// cmdScan examines COUNT keys per iteration, 10 when COUNT is not
+// given like Redis. The cursor is an offset into the sorted keys.
+func cmdScan(c *client, args []string) {
+    cursor, e := strconv.Atoi(args[0])
+
+    if e != nil || cursor < 0 {
+        c.error("ERR invalid cursor")
+        return
+    }
+
+    pattern, typ, count := "*", "", 10
+
+    for i := 1; i < len(args); i += 2 {
+        if i+1 >= len(args) {
+            c.err(errSyntax)
+            return
+        }
+
+        switch strings.ToUpper(args[i]) {
+        case "MATCH":
+            pattern = args[i+1]
+        case "TYPE":
+            typ = strings.ToLower(args[i+1])
+        case "COUNT":
+            if count, e = strconv.Atoi(args[i+1]); e != nil || count < 1 {
+                c.err(errSyntax)
+                return
+            }
+        default:
+            c.err(errSyntax)
+            return
+        }
+    }
+
+    d := c.database()
+    keys := d.sortedKeys("*")
+    found := []string{}
+    next := cursor + count
+
+    if next >= len(keys) {
+        next = 0
+    }
+
+    for i := cursor; i < len(keys) && i < cursor+count; i++ {
+        v, _ := d.get(keys[i])
+
+        if match(pattern, keys[i]) && (typ == "" || typ == typeName(v)) {
+            found = append(found, keys[i])
+        }
+    }
+
+    c.array(2)
+    c.bulk(strconv.Itoa(next))
+    c.strings(found)
+}
+
+func cmdRandomkey(c *client, args []string) {
+    // map iteration order is random enough for tests
+    for k := range c.database().keys {
+        if c.database().exists(k) {
+            c.bulk(k)
+            return
+        }
+    }
+
+    c.null()
+}
+
+func rename(c *client, from, to string, nx bool) bool {
+    d := c.database()
+    v, ok := d.get(from)
+
+    if !ok {
+        c.err(errNoKey)
+        return false
+    }
+
+    if nx && d.exists(to) {
+        return false
+    }
+
+    t, hasTtl := d.expires[from]
+    d.del(from)
+    d.set(to, v)
+
+    if hasTtl {
+        d.setExpire(to, t)
+    }
+
+    return true
+}
+
+func cmdRename(c *client, args []string) {
+    if rename(c, args[0], args[1], false) {
+        c.ok()
+    }
+}
+
+func cmdRenamenx(c *client, args []string) {
+    if !c.database().exists(args[0]) {
+        c.err(errNoKey)
+        return
+    }
+
+    c.bool(rename(c, args[0], args[1], true))
+}
+
+func cmdType(c *client, args []string) {
+    v, _ := c.database().get(args[0])
+    c.status(typeName(v))
+}
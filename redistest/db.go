@@ -0,0 +1,426 @@
+package redistest
+
+import (
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Values are stored as the following types.
+type (
+    hash map[string]string
+    set  map[string]bool
+    zset map[string]float64
+)
+
+type list struct {
+    items []string
+}
+
+type db struct {
+    s       *Server
+    keys    map[string]interface{}
+    expires map[string]time.Time
+
+    // version is incremented each time a key is written, see WATCH.
+    version map[string]int64
+    serial  int64
+}
+
+func newDb(s *Server) *db {
+    d := &db{s: s, version: make(map[string]int64)}
+    d.flush()
+    return d
+}
+
+func (d *db) flush() {
+    for k := range d.keys {
+        d.touch(k)
+    }
+
+    d.keys = make(map[string]interface{})
+    d.expires = make(map[string]time.Time)
+}
+
+// touch marks a key as modified.
+func (d *db) touch(key string) {
+    d.serial++
+    d.version[key] = d.serial
+}
+
+// expire removes the key if its ttl has passed.
+func (d *db) expire(key string) {
+    if t, ok := d.expires[key]; ok && !d.s.now().Before(t) {
+        delete(d.keys, key)
+        delete(d.expires, key)
+        d.touch(key)
+    }
+}
+
+func (d *db) get(key string) (interface{}, bool) {
+    d.expire(key)
+    v, ok := d.keys[key]
+    return v, ok
+}
+
+func (d *db) exists(key string) bool {
+    _, ok := d.get(key)
+    return ok
+}
+
+// set stores a value and clears any ttl.
+func (d *db) set(key string, v interface{}) {
+    d.keys[key] = v
+    delete(d.expires, key)
+    d.touch(key)
+}
+
+func (d *db) del(key string) bool {
+    if !d.exists(key) {
+        return false
+    }
+
+    delete(d.keys, key)
+    delete(d.expires, key)
+    d.touch(key)
+    return true
+}
+
+// clean removes a key holding an empty aggregate, Redis never stores them.
+func (d *db) clean(key string) {
+    v, ok := d.keys[key]
+
+    if !ok {
+        return
+    }
+
+    n := -1
+
+    switch v := v.(type) {
+    case hash:
+        n = len(v)
+    case *list:
+        n = len(v.items)
+    case set:
+        n = len(v)
+    case zset:
+        n = len(v)
+    }
+
+    if n == 0 {
+        d.del(key)
+    }
+}
+
+// ttl returns the time left until the key expires, or -1 for keys without
+// a ttl and -2 for missing keys.
+func (d *db) ttl(key string) time.Duration {
+    if !d.exists(key) {
+        return -2
+    }
+
+    t, ok := d.expires[key]
+
+    if !ok {
+        return -1
+    }
+
+    return t.Sub(d.s.now())
+}
+
+func (d *db) setExpire(key string, t time.Time) {
+    d.expires[key] = t
+    d.touch(key)
+    d.expire(key)
+}
+
+// sortedKeys returns all keys matching pattern.
+func (d *db) sortedKeys(pattern string) []string {
+    keys := make([]string, 0, len(d.keys))
+
+    for k := range d.keys {
+        if d.exists(k) && match(pattern, k) {
+            keys = append(keys, k)
+        }
+    }
+
+    sort.Strings(keys)
+    return keys
+}
+
+// typed lookups; write lookups create missing keys and mark them modified.
+
+func (d *db) getString(key string) (string, bool, error) {
+    v, ok := d.get(key)
+
+    if !ok {
+        return "", false, nil
+    }
+
+    s, ok := v.(string)
+
+    if !ok {
+        return "", false, errWrongType
+    }
+
+    return s, true, nil
+}
+
+func (d *db) getHash(key string, write bool) (hash, error) {
+    v, ok := d.get(key)
+
+    if !ok {
+        if !write {
+            return nil, nil
+        }
+
+        v = make(hash)
+        d.keys[key] = v
+    }
+
+    h, ok := v.(hash)
+
+    if !ok {
+        return nil, errWrongType
+    }
+
+    if write {
+        d.touch(key)
+    }
+
+    return h, nil
+}
+
+func (d *db) getList(key string, write bool) (*list, error) {
+    v, ok := d.get(key)
+
+    if !ok {
+        if !write {
+            return nil, nil
+        }
+
+        v = &list{}
+        d.keys[key] = v
+    }
+
+    l, ok := v.(*list)
+
+    if !ok {
+        return nil, errWrongType
+    }
+
+    if write {
+        d.touch(key)
+    }
+
+    return l, nil
+}
+
+func (d *db) getSet(key string, write bool) (set, error) {
+    v, ok := d.get(key)
+
+    if !ok {
+        if !write {
+            return nil, nil
+        }
+
+        v = make(set)
+        d.keys[key] = v
+    }
+
+    s, ok := v.(set)
+
+    if !ok {
+        return nil, errWrongType
+    }
+
+    if write {
+        d.touch(key)
+    }
+
+    return s, nil
+}
+
+func (d *db) getZset(key string, write bool) (zset, error) {
+    v, ok := d.get(key)
+
+    if !ok {
+        if !write {
+            return nil, nil
+        }
+
+        v = make(zset)
+        d.keys[key] = v
+    }
+
+    z, ok := v.(zset)
+
+    if !ok {
+        return nil, errWrongType
+    }
+
+    if write {
+        d.touch(key)
+    }
+
+    return z, nil
+}
+
+func typeName(v interface{}) string {
+    switch v.(type) {
+    case string:
+        return "string"
+    case hash:
+        return "hash"
+    case *list:
+        return "list"
+    case set:
+        return "set"
+    case zset:
+        return "zset"
+    }
+
+    return "none"
+}
+
+// match implements the glob-style patterns of KEYS and PSUBSCRIBE.
+func match(pattern, s string) bool {
+    for len(pattern) > 0 {
+        switch pattern[0] {
+        case '*':
+            for len(pattern) > 1 && pattern[1] == '*' {
+                pattern = pattern[1:]
+            }
+
+            if len(pattern) == 1 {
+                return true
+            }
+
+            for i := 0; i <= len(s); i++ {
+                if match(pattern[1:], s[i:]) {
+                    return true
+                }
+            }
+
+            return false
+        case '?':
+            if len(s) == 0 {
+                return false
+            }
+
+            s = s[1:]
+        case '[':
+            if len(s) == 0 {
+                return false
+            }
+
+            end := strings.IndexByte(pattern[1:], ']')
+
+            if end < 0 {
+                return pattern == s
+            }
+
+            class := pattern[1 : end+1]
+            pattern = pattern[end+1:]
+            not := len(class) > 0 && class[0] == '^'
+
+            if not {
+                class = class[1:]
+            }
+
+            if matchClass(class, s[0]) == not {
+                return false
+            }
+
+            s = s[1:]
+        case '\\':
+            if len(pattern) > 1 {
+                pattern = pattern[1:]
+            }
+
+            fallthrough
+        default:
+            if len(s) == 0 || s[0] != pattern[0] {
+                return false
+            }
+
+            s = s[1:]
+        }
+
+        pattern = pattern[1:]
+    }
+
+    return len(s) == 0
+}
+
+func matchClass(class string, c byte) bool {
+    for i := 0; i < len(class); i++ {
+        if i+2 < len(class) && class[i+1] == '-' {
+            if class[i] <= c && c <= class[i+2] {
+                return true
+            }
+
+            i += 2
+        } else if class[i] == c {
+            return true
+        }
+    }
+
+    return false
+}
+
+// argument parsing
+
+func parseInt(s string) (int64, error) {
+    n, e := strconv.ParseInt(s, 10, 64)
+
+    if e != nil {
+        return 0, errNotInt
+    }
+
+    return n, nil
+}
+
+func parseFloat(s string) (float64, error) {
+    switch strings.ToLower(s) {
+    case "inf", "+inf":
+        s = "+Inf"
+    case "-inf":
+        s = "-Inf"
+    }
+
+    f, e := strconv.ParseFloat(s, 64)
+
+    if e != nil {
+        return 0, errNotFloat
+    }
+
+    return f, nil
+}
+
+// normalize turns a Redis start/stop range with negative indexes into a
+// slice range of a sequence of length n. It returns false for empty ranges.
+func normalize(start, stop int64, n int) (int, int, bool) {
+    if start < 0 {
+        start += int64(n)
+    }
+
+    if stop < 0 {
+        stop += int64(n)
+    }
+
+    if start < 0 {
+        start = 0
+    }
+
+    if stop >= int64(n) {
+        stop = int64(n) - 1
+    }
+
+    if start > stop || start >= int64(n) {
+        return 0, 0, false
+    }
+
+    return int(start), int(stop) + 1, true
+}
@@ -0,0 +1,419 @@
+package redistest
+
+import (
+    "bufio"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Command is a command received by a Mock.
+type Command struct {
+    // Conn numbers the connections in the order they were accepted,
+    // starting at 1.
+    Conn int
+    Args []string
+}
+
+// Name returns the upper-cased command name.
+func (c Command) Name() string {
+    if len(c.Args) == 0 {
+        return ""
+    }
+
+    return strings.ToUpper(c.Args[0])
+}
+
+// Mock is a protocol-level server whose replies are scripted per command.
+// It is used to make Redis misbehave in tests of timeouts, retries and
+// redirections:
+//
+//      m := redistest.NewMock(nil)
+//      defer m.Close()
+//
+//      m.On("GET").Times(1).Loading()
+//      m.On("GET", "foo").Delay(time.Second).Bulk("bar")
+//      m.On("SET").Partial("+OK\r\n", 2).Reset()
+//
+// Rules are matched in the order they were added. Commands without a
+// matching rule are passed to the backend Server, or answered with an error
+// if the mock has no backend. Every received command is recorded and
+// returned by Commands.
+type Mock struct {
+    // Addr is the address the mock listens on, e.g. "127.0.0.1:51234".
+    Addr string
+
+    backend  *Server
+    ln       net.Listener
+    mu       sync.Mutex
+    rules    []*Rule
+    received []Command
+    nconn    int
+    conns    map[net.Conn]bool
+    done     chan struct{}
+    wg       sync.WaitGroup
+}
+
+// NewMock starts a mock listening on a random port of 127.0.0.1. Unscripted
+// commands are executed by backend, which may be nil. It panics if no port
+// is available.
+func NewMock(backend *Server) *Mock {
+    ln, e := net.Listen("tcp", "127.0.0.1:0")
+
+    if e != nil {
+        panic("redistest: failed to listen on a port: " + e.Error())
+    }
+
+    m := &Mock{
+        Addr:    ln.Addr().String(),
+        backend: backend,
+        ln:      ln,
+        conns:   make(map[net.Conn]bool),
+        done:    make(chan struct{}),
+    }
+
+    m.wg.Add(1)
+    go m.serve()
+    return m
+}
+
+// NetAddr returns the address in the "net:addr" form expected by the godis
+// clients, e.g. "tcp:127.0.0.1:51234".
+func (m *Mock) NetAddr() string {
+    return "tcp:" + m.Addr
+}
+
+// Close stops the mock, closes all connections and releases commands
+// blocked by Hang. It does not close the backend.
+func (m *Mock) Close() error {
+    e := m.ln.Close()
+    m.mu.Lock()
+    close(m.done)
+
+    for rwc := range m.conns {
+        rwc.Close()
+    }
+
+    m.mu.Unlock()
+    m.wg.Wait()
+    return e
+}
+
+// On adds a rule for commands named name whose leading arguments equal
+// args. The name is case-insensitive, the arguments are not.
+func (m *Mock) On(name string, args ...string) *Rule {
+    r := &Rule{
+        m:    m,
+        name: strings.ToUpper(name),
+        args: args,
+    }
+
+    m.mu.Lock()
+    m.rules = append(m.rules, r)
+    m.mu.Unlock()
+    return r
+}
+
+// Commands returns the commands received so far.
+func (m *Mock) Commands() []Command {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return append([]Command(nil), m.received...)
+}
+
+// Conns returns the number of connections accepted so far.
+func (m *Mock) Conns() int {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.nconn
+}
+
+// Clear removes all rules and forgets the received commands.
+func (m *Mock) Clear() {
+    m.mu.Lock()
+    m.rules = nil
+    m.received = nil
+    m.mu.Unlock()
+}
+
+func (m *Mock) serve() {
+    defer m.wg.Done()
+
+    for {
+        rwc, e := m.ln.Accept()
+
+        if e != nil {
+            return
+        }
+
+        m.mu.Lock()
+        m.nconn++
+        m.conns[rwc] = true
+        mc := &mockConn{m: m, id: m.nconn, rwc: rwc}
+        m.mu.Unlock()
+
+        if m.backend != nil {
+            mc.c = newClient(m.backend, rwc)
+        }
+
+        m.wg.Add(1)
+        go mc.serve()
+    }
+}
+
+// match records args and returns the steps of the first matching rule, or
+// nil if no rule matches.
+func (m *Mock) match(id int, args []string) []step {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.received = append(m.received, Command{id, args})
+
+    for i, r := range m.rules {
+        if !r.matches(args) {
+            continue
+        }
+
+        if r.times > 0 {
+            if r.times--; r.times == 0 {
+                m.rules = append(m.rules[:i:i], m.rules[i+1:]...)
+            }
+        }
+
+        return append([]step(nil), r.steps...)
+    }
+
+    return nil
+}
+
+// A Rule scripts the reply to matching commands. Its methods append steps
+// which are run in order for every matching command, e.g. a delay followed
+// by a reply. A rule without steps sends nothing.
+type Rule struct {
+    m     *Mock
+    name  string
+    args  []string
+    times int
+    steps []step
+}
+
+// step runs one scripted action and returns false if the connection was
+// closed.
+type step func(mc *mockConn, args []string) bool
+
+func (r *Rule) matches(args []string) bool {
+    if strings.ToUpper(args[0]) != r.name || len(args)-1 < len(r.args) {
+        return false
+    }
+
+    for i, a := range r.args {
+        if args[i+1] != a {
+            return false
+        }
+    }
+
+    return true
+}
+
+func (r *Rule) add(s step) *Rule {
+    r.m.mu.Lock()
+    r.steps = append(r.steps, s)
+    r.m.mu.Unlock()
+    return r
+}
+
+// Times limits the rule to the next n matching commands. After that the
+// rule is removed and later rules apply.
+func (r *Rule) Times(n int) *Rule {
+    r.m.mu.Lock()
+    r.times = n
+    r.m.mu.Unlock()
+    return r
+}
+
+// Reply sends raw as is. It can be used to send malformed frames.
+func (r *Rule) Reply(raw string) *Rule {
+    return r.add(func(mc *mockConn, args []string) bool {
+        return mc.write([]byte(raw))
+    })
+}
+
+// Status sends a status reply.
+func (r *Rule) Status(s string) *Rule {
+    return r.Reply("+" + s + "\r\n")
+}
+
+// Error sends an error reply, e.g. "ERR something went wrong".
+func (r *Rule) Error(s string) *Rule {
+    return r.Reply("-" + s + "\r\n")
+}
+
+// Int sends an integer reply.
+func (r *Rule) Int(n int64) *Rule {
+    return r.Reply(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// Bulk sends a bulk reply.
+func (r *Rule) Bulk(s string) *Rule {
+    return r.Reply(string(appendBulk(nil, s)))
+}
+
+// Nil sends a nil bulk reply.
+func (r *Rule) Nil() *Rule {
+    return r.Reply("$-1\r\n")
+}
+
+// Moved sends a cluster MOVED redirection.
+func (r *Rule) Moved(slot int, addr string) *Rule {
+    return r.Error("MOVED " + strconv.Itoa(slot) + " " + addr)
+}
+
+// Ask sends a cluster ASK redirection.
+func (r *Rule) Ask(slot int, addr string) *Rule {
+    return r.Error("ASK " + strconv.Itoa(slot) + " " + addr)
+}
+
+// Loading sends the error Redis replies with while loading its dataset.
+func (r *Rule) Loading() *Rule {
+    return r.Error("LOADING Redis is loading the dataset in memory")
+}
+
+// Malformed sends a frame with an unknown type byte.
+func (r *Rule) Malformed() *Rule {
+    return r.Reply("?malformed\r\n")
+}
+
+// Partial sends the first n bytes of raw. It is usually followed by Reset
+// or Hang.
+func (r *Rule) Partial(raw string, n int) *Rule {
+    if n > len(raw) {
+        n = len(raw)
+    }
+
+    return r.Reply(raw[:n])
+}
+
+// Delay waits for d before the next step.
+func (r *Rule) Delay(d time.Duration) *Rule {
+    return r.add(func(mc *mockConn, args []string) bool {
+        select {
+        case <-time.After(d):
+        case <-mc.m.done:
+        }
+
+        return true
+    })
+}
+
+// Hang stops reading and replying on the connection until the mock is
+// closed.
+func (r *Rule) Hang() *Rule {
+    return r.add(func(mc *mockConn, args []string) bool {
+        <-mc.m.done
+        return false
+    })
+}
+
+// Reset closes the connection. On TCP connections the close is abortive,
+// so the client sees a connection reset rather than EOF.
+func (r *Rule) Reset() *Rule {
+    return r.add(func(mc *mockConn, args []string) bool {
+        if tc, ok := mc.rwc.(*net.TCPConn); ok {
+            tc.SetLinger(0)
+        }
+
+        mc.rwc.Close()
+        return false
+    })
+}
+
+// Pass executes the command on the backend and sends its reply, or an
+// error if the mock has no backend.
+func (r *Rule) Pass() *Rule {
+    return r.add(func(mc *mockConn, args []string) bool {
+        return mc.pass(args)
+    })
+}
+
+// mockConn holds the state of one mock connection.
+type mockConn struct {
+    m   *Mock
+    id  int
+    rwc net.Conn
+
+    // c executes unscripted commands on the backend
+    c *client
+}
+
+func (mc *mockConn) serve() {
+    defer mc.m.wg.Done()
+    defer mc.close()
+
+    r := bufio.NewReader(mc.rwc)
+
+    for {
+        args, e := readCommand(r)
+
+        if e != nil {
+            return
+        }
+
+        if len(args) == 0 {
+            continue
+        }
+
+        steps := mc.m.match(mc.id, args)
+
+        if steps == nil {
+            steps = []step{func(mc *mockConn, args []string) bool {
+                return mc.pass(args)
+            }}
+        }
+
+        for _, s := range steps {
+            if !s(mc, args) {
+                return
+            }
+        }
+    }
+}
+
+func (mc *mockConn) close() {
+    if mc.c != nil {
+        s := mc.c.s
+        s.mu.Lock()
+        s.pubsub.unsubscribeAll(mc.c)
+        s.mu.Unlock()
+    }
+
+    mc.m.mu.Lock()
+    delete(mc.m.conns, mc.rwc)
+    mc.m.mu.Unlock()
+    mc.rwc.Close()
+}
+
+func (mc *mockConn) write(b []byte) bool {
+    if mc.c != nil {
+        // serialized with messages published by the backend
+        mc.c.wmu.Lock()
+        defer mc.c.wmu.Unlock()
+    }
+
+    _, e := mc.rwc.Write(b)
+    return e == nil
+}
+
+func (mc *mockConn) pass(args []string) bool {
+    if mc.c == nil {
+        return mc.write([]byte("-ERR unscripted command '" + args[0] + "'\r\n"))
+    }
+
+    s := mc.c.s
+    s.mu.Lock()
+    quit := mc.c.exec(args)
+    s.mu.Unlock()
+
+    return mc.c.flush() == nil && !quit
+}
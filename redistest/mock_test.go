@@ -0,0 +1,211 @@
+package redistest
+
+import (
+    "net"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "insmo.com/godis/exp"
+)
+
+func dialMock(t *testing.T, m *Mock) *redis.Conn {
+    c, e := redis.NewConn(m.Addr, "tcp", 0, "")
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    return c
+}
+
+func TestMockReplies(t *testing.T) {
+    m := NewMock(nil)
+    defer m.Close()
+
+    m.On("GET", "foo").Bulk("bar")
+    m.On("INCR").Int(42)
+    m.On("GET").Nil()
+    m.On("SET").Status("OK")
+    m.On("CLUSTER").Error("ERR cluster support disabled")
+
+    runCallTests(t, redis.NewClient(m.NetAddr(), 0, ""), []callTest{
+        {[]interface{}{"GET", "foo"}, "bar"},
+        {[]interface{}{"get", "baz"}, nil},
+        {[]interface{}{"INCR", "n"}, 42},
+        {[]interface{}{"SET", "foo", "bar"}, "OK"},
+        {[]interface{}{"CLUSTER", "INFO"}, "-ERR cluster support disabled"},
+        {[]interface{}{"DEL", "foo"}, "-ERR unscripted command"},
+    })
+}
+
+func TestMockRedirects(t *testing.T) {
+    m := NewMock(nil)
+    defer m.Close()
+
+    m.On("GET", "a").Moved(15495, "127.0.0.1:7002")
+    m.On("GET", "b").Ask(3300, "127.0.0.1:7001")
+    m.On("GET", "c").Loading()
+
+    runCallTests(t, redis.NewClient(m.NetAddr(), 0, ""), []callTest{
+        {[]interface{}{"GET", "a"}, "-MOVED 15495 127.0.0.1:7002"},
+        {[]interface{}{"GET", "b"}, "-ASK 3300 127.0.0.1:7001"},
+        {[]interface{}{"GET", "c"}, "-LOADING"},
+    })
+}
+
+func TestMockBackend(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    m := NewMock(s)
+    defer m.Close()
+
+    m.On("GET").Times(2).Loading()
+
+    runCallTests(t, redis.NewClient(m.NetAddr(), 0, ""), []callTest{
+        {[]interface{}{"SET", "foo", "bar"}, "OK"},
+        {[]interface{}{"GET", "foo"}, "-LOADING"},
+        {[]interface{}{"GET", "foo"}, "-LOADING"},
+        {[]interface{}{"GET", "foo"}, "bar"},
+    })
+
+    m.On("GET").Delay(10 * time.Millisecond).Pass()
+    c := dialMock(t, m)
+    defer c.Close()
+    start := time.Now()
+    c.Write("GET", "foo")
+
+    if r, e := c.Read(); e != nil || r.Elem.String() != "bar" {
+        t.Fatalf("expected bar got %v, %v", r, e)
+    }
+
+    if d := time.Since(start); d < 10*time.Millisecond {
+        t.Errorf("expected delayed reply got %v", d)
+    }
+}
+
+func TestMockPubsub(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    m := NewMock(s)
+    defer m.Close()
+
+    sub := dialMock(t, m)
+    defer sub.Close()
+    sub.Write("SUBSCRIBE", "ch")
+
+    if _, e := sub.Read(); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    runCallTests(t, redis.NewClient(s.NetAddr(), 0, ""), []callTest{
+        {[]interface{}{"PUBLISH", "ch", "hello"}, 1},
+    })
+
+    r, e := sub.Read()
+
+    if e != nil || !reflect.DeepEqual(r.StringArray(), []string{"message", "ch", "hello"}) {
+        t.Errorf("expected message got %v, %v", r, e)
+    }
+}
+
+func TestMockFaults(t *testing.T) {
+    m := NewMock(nil)
+    defer m.Close()
+
+    m.On("GET", "partial").Partial("$3\r\nbar\r\n", 5).Reset()
+    m.On("GET", "reset").Reset()
+    m.On("GET", "hang").Hang()
+    m.On("GET", "malformed").Malformed()
+
+    for _, key := range []string{"partial", "reset", "hang", "malformed"} {
+        c := dialMock(t, m)
+        c.Sock().SetDeadline(time.Now().Add(50 * time.Millisecond))
+        c.Write("GET", key)
+
+        if r, e := c.Read(); e == nil {
+            t.Errorf("%s: expected error got %v", key, r)
+        }
+
+        c.Close()
+    }
+}
+
+func TestMockPartial(t *testing.T) {
+    m := NewMock(nil)
+    defer m.Close()
+
+    m.On("GET").Partial("$3\r\nbar\r\n", 6).Delay(10 * time.Millisecond).Reply("r\r\n")
+
+    c, e := net.Dial("tcp", m.Addr)
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    defer c.Close()
+    c.Write([]byte("GET foo\r\n"))
+    buf := make([]byte, 64)
+    n, e := c.Read(buf)
+
+    if e != nil || string(buf[:n]) != "$3\r\nba" {
+        t.Fatalf("expected partial reply got %q, %v", buf[:n], e)
+    }
+
+    n, e = c.Read(buf)
+
+    if e != nil || string(buf[:n]) != "r\r\n" {
+        t.Fatalf("expected rest of reply got %q, %v", buf[:n], e)
+    }
+}
+
+func TestMockCommands(t *testing.T) {
+    m := NewMock(nil)
+    defer m.Close()
+
+    m.On("PING").Status("PONG")
+    m.On("SET").Reset()
+
+    c := dialMock(t, m)
+    c.Write("PING")
+    c.Read()
+    c.Write("SET", "foo", "bar")
+    c.Read()
+    c.Close()
+
+    c = dialMock(t, m)
+    defer c.Close()
+    c.Write("PING")
+    c.Read()
+
+    expected := []Command{
+        {1, []string{"PING"}},
+        {1, []string{"SET", "foo", "bar"}},
+        {2, []string{"PING"}},
+    }
+
+    if cmds := m.Commands(); !reflect.DeepEqual(cmds, expected) {
+        t.Errorf("expected %v got %v", expected, cmds)
+    }
+
+    if m.Conns() != 2 {
+        t.Errorf("expected 2 connections got %d", m.Conns())
+    }
+
+    if name := m.Commands()[1].Name(); name != "SET" {
+        t.Errorf("expected SET got %s", name)
+    }
+
+    m.Clear()
+
+    c.Write("PING")
+
+    if r, e := c.Read(); e == nil || !strings.HasPrefix(e.Error(), "ERR unscripted") {
+        t.Errorf("expected unscripted error got %v, %v", r, e)
+    }
+
+    if len(m.Commands()) != 1 {
+        t.Errorf("expected 1 command got %v", m.Commands())
+    }
+}
@@ -0,0 +1,114 @@
+package redistest
+
+import (
+    "strings"
+)
+
+func init() {
+    register("MULTI", 1, flagNoQueue, cmdMulti)
+    register("EXEC", 1, flagNoQueue, cmdExec)
+    register("DISCARD", 1, flagNoQueue, cmdDiscard)
+    register("WATCH", -2, flagNoQueue, cmdWatch)
+    register("UNWATCH", 1, 0, cmdUnwatch)
+}
+
+func cmdMulti(c *client, args []string) {
+    if c.multi {
+        c.error("ERR MULTI calls can not be nested")
+        return
+    }
+
+    c.multi = true
+    c.ok()
+}
+
+func (c *client) reset() {
+    c.multi = false
+    c.aborted = false
+    c.queued = nil
+    c.watched = nil
+}
+
+// dirty reports whether any of the watched keys has been modified.
+func (c *client) dirty() bool {
+    for d, keys := range c.watched {
+        for k, v := range keys {
+            d.expire(k)
+
+            if d.version[k] != v {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+func cmdExec(c *client, args []string) {
+    if !c.multi {
+        c.error("ERR EXEC without MULTI")
+        return
+    }
+
+    queued, aborted, dirty := c.queued, c.aborted, c.dirty()
+    c.reset()
+
+    switch {
+    case aborted:
+        c.error("EXECABORT Transaction discarded because of previous errors.")
+        return
+    case dirty:
+        c.nullArray()
+        return
+    }
+
+    c.array(len(queued))
+
+    for _, args := range queued {
+        commands[strings.ToUpper(args[0])].fn(c, args[1:])
+    }
+}
+
+func cmdDiscard(c *client, args []string) {
+    if !c.multi {
+        c.error("ERR DISCARD without MULTI")
+        return
+    }
+
+    c.reset()
+    c.ok()
+}
+
+func cmdWatch(c *client, args []string) {
+    if c.multi {
+        c.error("ERR WATCH inside MULTI is not allowed")
+        return
+    }
+
+    d := c.database()
+
+    if c.watched == nil {
+        c.watched = make(map[*db]map[string]int64)
+    }
+
+    if c.watched[d] == nil {
+        c.watched[d] = make(map[string]int64)
+    }
+
+    // a key watched again keeps its first version, so a write between the
+    // two WATCHes still aborts EXEC
+    for _, k := range args {
+        d.expire(k)
+
+        if _, ok := c.watched[d][k]; !ok {
+            c.watched[d][k] = d.version[k]
+        }
+    }
+
+    c.ok()
+}
+
+func cmdUnwatch(c *client, args []string) {
+    c.watched = nil
+    c.ok()
+}
@@ -0,0 +1,128 @@
+package redistest
+
+func init() {
+    register("SUBSCRIBE", -2, flagPubsub, cmdSubscribe)
+    register("UNSUBSCRIBE", -1, flagPubsub, cmdUnsubscribe)
+    register("PSUBSCRIBE", -2, flagPubsub, cmdPsubscribe)
+    register("PUNSUBSCRIBE", -1, flagPubsub, cmdPunsubscribe)
+    register("PUBLISH", 3, 0, cmdPublish)
+}
+
+type pubsub struct {
+    channels map[string]map[*client]bool
+    patterns map[string]map[*client]bool
+}
+
+func newPubsub() *pubsub {
+    return &pubsub{
+        channels: make(map[string]map[*client]bool),
+        patterns: make(map[string]map[*client]bool),
+    }
+}
+
+func (p *pubsub) unsubscribeAll(c *client) {
+    for ch := range c.channels {
+        delete(p.channels[ch], c)
+    }
+
+    for pat := range c.patterns {
+        delete(p.patterns[pat], c)
+    }
+}
+
+// subscribeGen adds the client to channels or patterns and replies with one
+// message per channel.
+func subscribeGen(c *client, kind string, names []string, subs map[string]bool, all map[string]map[*client]bool) {
+    for _, name := range names {
+        subs[name] = true
+
+        if all[name] == nil {
+            all[name] = make(map[*client]bool)
+        }
+
+        all[name][c] = true
+        c.array(3)
+        c.bulk(kind)
+        c.bulk(name)
+        c.int(int64(len(c.channels) + len(c.patterns)))
+    }
+}
+
+func unsubscribeGen(c *client, kind string, names []string, subs map[string]bool, all map[string]map[*client]bool) {
+    if len(names) == 0 {
+        for name := range subs {
+            names = append(names, name)
+        }
+    }
+
+    if len(names) == 0 {
+        c.array(3)
+        c.bulk(kind)
+        c.null()
+        c.int(int64(len(c.channels) + len(c.patterns)))
+        return
+    }
+
+    for _, name := range names {
+        delete(subs, name)
+        delete(all[name], c)
+
+        if len(all[name]) == 0 {
+            delete(all, name)
+        }
+
+        c.array(3)
+        c.bulk(kind)
+        c.bulk(name)
+        c.int(int64(len(c.channels) + len(c.patterns)))
+    }
+}
+
+func cmdSubscribe(c *client, args []string) {
+    subscribeGen(c, "subscribe", args, c.channels, c.s.pubsub.channels)
+}
+
+func cmdUnsubscribe(c *client, args []string) {
+    unsubscribeGen(c, "unsubscribe", args, c.channels, c.s.pubsub.channels)
+}
+
+func cmdPsubscribe(c *client, args []string) {
+    subscribeGen(c, "psubscribe", args, c.patterns, c.s.pubsub.patterns)
+}
+
+func cmdPunsubscribe(c *client, args []string) {
+    unsubscribeGen(c, "punsubscribe", args, c.patterns, c.s.pubsub.patterns)
+}
+
+func cmdPublish(c *client, args []string) {
+    p := c.s.pubsub
+    ch, msg := args[0], args[1]
+    n := 0
+
+    for sub := range p.channels[ch] {
+        b := appendArray(nil, 3)
+        b = appendBulk(b, "message")
+        b = appendBulk(b, ch)
+        b = appendBulk(b, msg)
+        sub.send(b)
+        n++
+    }
+
+    for pat, subs := range p.patterns {
+        if !match(pat, ch) {
+            continue
+        }
+
+        for sub := range subs {
+            b := appendArray(nil, 4)
+            b = appendBulk(b, "pmessage")
+            b = appendBulk(b, pat)
+            b = appendBulk(b, ch)
+            b = appendBulk(b, msg)
+            sub.send(b)
+            n++
+        }
+    }
+
+    c.int(int64(n))
+}
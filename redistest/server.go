@@ -0,0 +1,437 @@
+// Package redistest implements an in-memory Redis server for tests.
+//
+// The server speaks the Redis protocol on a random local port and supports
+// the most common commands on strings, hashes, lists, sets and sorted sets,
+// key expiry, transactions (MULTI/EXEC/WATCH) and pub/sub. It is meant to
+// replace a real Redis in unit tests, not to be a faithful copy of it.
+//
+//      s := redistest.NewServer()
+//      defer s.Close()
+//
+//      c := redis.NewClient(s.NetAddr(), 0, "")
+//      c.Call("SET", "foo", "bar")
+//
+// Like Redis, the server executes one command at a time. Expiry is based on
+// the server clock which can be moved with FastForward.
+//
+// Mock is a server whose replies are scripted per command to inject faults
+// such as delays, partial writes, connection resets and cluster
+// redirections. It can pass unscripted commands on to a Server.
+package redistest
+
+import (
+    "bufio"
+    "errors"
+    "io"
+    "math"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Databases is the number of databases available through SELECT.
+const Databases = 16
+
+var (
+    errSyntax     = errors.New("ERR syntax error")
+    errWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+    errNotInt     = errors.New("ERR value is not an integer or out of range")
+    errNotFloat   = errors.New("ERR value is not a valid float")
+    errMinMax     = errors.New("ERR min or max is not a float")
+    errNoKey      = errors.New("ERR no such key")
+    errOutOfRange = errors.New("ERR index out of range")
+    errNoAuth     = errors.New("NOAUTH Authentication required.")
+)
+
+// Server is an in-memory Redis server.
+type Server struct {
+    // Addr is the address the server listens on, e.g. "127.0.0.1:51234".
+    Addr string
+
+    // Password is required by AUTH if it is set.
+    Password string
+
+    ln     net.Listener
+    mu     sync.Mutex
+    dbs    [Databases]*db
+    offset time.Duration
+    conns  map[*client]bool
+    pubsub *pubsub
+    wg     sync.WaitGroup
+}
+
+// NewServer starts a server listening on a random port of 127.0.0.1. It
+// panics if no port is available.
+func NewServer() *Server {
+    ln, e := net.Listen("tcp", "127.0.0.1:0")
+
+    if e != nil {
+        panic("redistest: failed to listen on a port: " + e.Error())
+    }
+
+    s := &Server{
+        Addr:   ln.Addr().String(),
+        ln:     ln,
+        conns:  make(map[*client]bool),
+        pubsub: newPubsub(),
+    }
+
+    for i := range s.dbs {
+        s.dbs[i] = newDb(s)
+    }
+
+    s.wg.Add(1)
+    go s.serve()
+    return s
+}
+
+// NetAddr returns the address in the "net:addr" form expected by the godis
+// clients, e.g. "tcp:127.0.0.1:51234".
+func (s *Server) NetAddr() string {
+    return "tcp:" + s.Addr
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() error {
+    e := s.ln.Close()
+    s.mu.Lock()
+
+    for c := range s.conns {
+        c.rwc.Close()
+    }
+
+    s.mu.Unlock()
+    s.wg.Wait()
+    return e
+}
+
+// FlushAll removes all keys from all databases.
+func (s *Server) FlushAll() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for _, d := range s.dbs {
+        d.flush()
+    }
+}
+
+// FastForward moves the server clock, which makes keys expire.
+func (s *Server) FastForward(d time.Duration) {
+    s.mu.Lock()
+    s.offset += d
+    s.mu.Unlock()
+}
+
+func (s *Server) now() time.Time {
+    return time.Now().Add(s.offset)
+}
+
+func (s *Server) serve() {
+    defer s.wg.Done()
+
+    for {
+        rwc, e := s.ln.Accept()
+
+        if e != nil {
+            return
+        }
+
+        c := newClient(s, rwc)
+        s.mu.Lock()
+        s.conns[c] = true
+        s.mu.Unlock()
+
+        s.wg.Add(1)
+        go c.serve()
+    }
+}
+
+// client holds the state of one connection.
+type client struct {
+    s     *Server
+    rwc   net.Conn
+    r     *bufio.Reader
+    db    int
+    authd bool
+
+    // reply buffer, sent when no more commands are buffered
+    out []byte
+
+    // writes from other clients, e.g. PUBLISH, are serialized by wmu
+    wmu sync.Mutex
+
+    // MULTI state
+    multi   bool
+    aborted bool
+    queued  [][]string
+    watched map[*db]map[string]int64
+
+    // pub/sub state
+    channels map[string]bool
+    patterns map[string]bool
+}
+
+func newClient(s *Server, rwc net.Conn) *client {
+    return &client{
+        s:        s,
+        rwc:      rwc,
+        r:        bufio.NewReader(rwc),
+        channels: make(map[string]bool),
+        patterns: make(map[string]bool),
+    }
+}
+
+func (c *client) serve() {
+    defer c.s.wg.Done()
+    defer c.close()
+
+    for {
+        args, e := readCommand(c.r)
+
+        if e != nil {
+            return
+        }
+
+        if len(args) == 0 {
+            continue
+        }
+
+        c.s.mu.Lock()
+        quit := c.exec(args)
+        c.s.mu.Unlock()
+
+        if c.r.Buffered() == 0 || quit {
+            if e = c.flush(); e != nil || quit {
+                return
+            }
+        }
+    }
+}
+
+func (c *client) close() {
+    c.s.mu.Lock()
+    c.s.pubsub.unsubscribeAll(c)
+    delete(c.s.conns, c)
+    c.s.mu.Unlock()
+    c.rwc.Close()
+}
+
+func (c *client) flush() error {
+    c.wmu.Lock()
+    defer c.wmu.Unlock()
+
+    if len(c.out) == 0 {
+        return nil
+    }
+
+    _, e := c.rwc.Write(c.out)
+    c.out = c.out[:0]
+    return e
+}
+
+// send writes a message, e.g. a published message, directly to the client.
+func (c *client) send(b []byte) {
+    c.wmu.Lock()
+    c.rwc.Write(b)
+    c.wmu.Unlock()
+}
+
+// readCommand reads a multi-bulk command or an inline command.
+func readCommand(r *bufio.Reader) ([]string, error) {
+    line, e := readLine(r)
+
+    if e != nil {
+        return nil, e
+    }
+
+    if len(line) == 0 || line[0] != '*' {
+        return strings.Fields(line), nil
+    }
+
+    n, e := strconv.Atoi(line[1:])
+
+    if e != nil || n > 1024*1024 {
+        return nil, errors.New("invalid multibulk length")
+    }
+
+    args := make([]string, n)
+
+    for i := range args {
+        line, e = readLine(r)
+
+        if e != nil {
+            return nil, e
+        }
+
+        if len(line) == 0 || line[0] != '$' {
+            return nil, errors.New("expected '$'")
+        }
+
+        l, e := strconv.Atoi(line[1:])
+
+        if e != nil || l < 0 || l > 512*1024*1024 {
+            return nil, errors.New("invalid bulk length")
+        }
+
+        buf := make([]byte, l+2)
+
+        if _, e = io.ReadFull(r, buf); e != nil {
+            return nil, e
+        }
+
+        args[i] = string(buf[:l])
+    }
+
+    return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+    line, e := r.ReadString('\n')
+
+    if e != nil {
+        return "", e
+    }
+
+    return strings.TrimRight(line, "\r\n"), nil
+}
+
+// exec runs one command. It must be called with s.mu held and returns true
+// if the connection should be closed.
+func (c *client) exec(args []string) bool {
+    name := strings.ToUpper(args[0])
+    cmd, ok := commands[name]
+
+    if !ok {
+        c.abort()
+        c.error("ERR unknown command '" + args[0] + "'")
+        return false
+    }
+
+    if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+        c.abort()
+        c.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+        return false
+    }
+
+    if c.s.Password != "" && !c.authd && name != "AUTH" && name != "QUIT" {
+        c.abort()
+        c.err(errNoAuth)
+        return false
+    }
+
+    if len(c.channels)+len(c.patterns) > 0 && cmd.flags&flagPubsub == 0 {
+        c.error("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+        return false
+    }
+
+    if c.multi && cmd.flags&flagNoQueue == 0 {
+        c.queued = append(c.queued, args)
+        c.status("QUEUED")
+        return false
+    }
+
+    cmd.fn(c, args[1:])
+    return name == "QUIT"
+}
+
+// abort marks a transaction as failed, so EXEC returns EXECABORT.
+func (c *client) abort() {
+    if c.multi {
+        c.aborted = true
+    }
+}
+
+func (c *client) database() *db {
+    return c.s.dbs[c.db]
+}
+
+// reply helpers
+
+func (c *client) status(s string) {
+    c.out = append(c.out, '+')
+    c.out = append(c.out, s...)
+    c.out = append(c.out, '\r', '\n')
+}
+
+func (c *client) ok() {
+    c.status("OK")
+}
+
+func (c *client) error(s string) {
+    c.out = append(c.out, '-')
+    c.out = append(c.out, s...)
+    c.out = append(c.out, '\r', '\n')
+}
+
+func (c *client) err(e error) {
+    c.error(e.Error())
+}
+
+func (c *client) int(n int64) {
+    c.out = append(c.out, ':')
+    c.out = strconv.AppendInt(c.out, n, 10)
+    c.out = append(c.out, '\r', '\n')
+}
+
+func (c *client) bool(b bool) {
+    if b {
+        c.int(1)
+    } else {
+        c.int(0)
+    }
+}
+
+func (c *client) bulk(s string) {
+    c.out = appendBulk(c.out, s)
+}
+
+func (c *client) float(f float64) {
+    c.bulk(formatFloat(f))
+}
+
+func (c *client) null() {
+    c.out = append(c.out, "$-1\r\n"...)
+}
+
+func (c *client) nullArray() {
+    c.out = append(c.out, "*-1\r\n"...)
+}
+
+func (c *client) array(n int) {
+    c.out = appendArray(c.out, n)
+}
+
+func (c *client) strings(a []string) {
+    c.array(len(a))
+
+    for _, s := range a {
+        c.bulk(s)
+    }
+}
+
+func appendBulk(b []byte, s string) []byte {
+    b = append(b, '$')
+    b = strconv.AppendInt(b, int64(len(s)), 10)
+    b = append(b, '\r', '\n')
+    b = append(b, s...)
+    return append(b, '\r', '\n')
+}
+
+func appendArray(b []byte, n int) []byte {
+    b = append(b, '*')
+    b = strconv.AppendInt(b, int64(n), 10)
+    return append(b, '\r', '\n')
+}
+
+func formatFloat(f float64) string {
+    switch {
+    case math.IsInf(f, 1):
+        return "inf"
+    case math.IsInf(f, -1):
+        return "-inf"
+    }
+
+    return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,411 @@
+package redistest
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "insmo.com/godis/exp"
+)
+
+type callTest struct {
+    args []interface{}
+    out  interface{}
+}
+
+// runCallTests compares replies with out, which is either a string, an
+// int64, nil for nil replies, an error string prefixed with "-" or a
+// []string for multi-bulk replies.
+func runCallTests(t *testing.T, c *redis.Client, tests []callTest) {
+    for _, test := range tests {
+        r, e := c.Call(test.args...)
+
+        switch out := test.out.(type) {
+        case string:
+            if len(out) > 0 && out[0] == '-' {
+                if e == nil || e.Error()[:len(out)-1] != out[1:] {
+                    t.Errorf("%v: expected error %q got %v", test.args, out[1:], e)
+                }
+            } else if e != nil || r.Elem.String() != out {
+                t.Errorf("%v: expected %q got %v, %v", test.args, out, r, e)
+            }
+        case int:
+            if e != nil || r.Elem.Int64() != int64(out) {
+                t.Errorf("%v: expected %d got %v, %v", test.args, out, r, e)
+            }
+        case []string:
+            if e != nil || !reflect.DeepEqual(r.StringArray(), out) {
+                t.Errorf("%v: expected %q got %v, %v", test.args, out, r, e)
+            }
+        case nil:
+            if e != nil || !r.Nil() {
+                t.Errorf("%v: expected nil got %v, %v", test.args, r, e)
+            }
+        }
+    }
+}
+
+func args(a ...interface{}) []interface{} {
+    return a
+}
+
+func TestStrings(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    runCallTests(t, c, []callTest{
+        {args("PING"), "PONG"},
+        {args("GET", "foo"), nil},
+        {args("SET", "foo", "bar"), "OK"},
+        {args("GET", "foo"), "bar"},
+        {args("SET", "foo", "baz", "NX"), nil},
+        {args("SET", "foo", "baz", "XX"), "OK"},
+        {args("APPEND", "foo", "qux"), 6},
+        {args("STRLEN", "foo"), 6},
+        {args("GETRANGE", "foo", 0, -4), "baz"},
+        {args("INCR", "n"), 1},
+        {args("INCRBY", "n", 10), 11},
+        {args("DECR", "n"), 10},
+        {args("INCRBYFLOAT", "f", "1.5"), "1.5"},
+        {args("INCR", "foo"), "-ERR value is not an integer"},
+        {args("MSET", "a", 1, "b", 2), "OK"},
+        {args("MGET", "a", "b", "c"), []string{"1", "2", ""}},
+        {args("GETSET", "a", 3), "1"},
+        {args("SETNX", "a", 4), 0},
+        {args("DEL", "a", "b", "c"), 2},
+        {args("EXISTS", "a", "foo"), 1},
+        {args("TYPE", "foo"), "string"},
+        {args("KEYS", "*"), []string{"f", "foo", "n"}},
+        {args("KEYS", "f?o"), []string{"foo"}},
+        {args("RENAME", "foo", "bar"), "OK"},
+        {args("GET", "bar"), "bazqux"},
+        {args("RENAME", "foo", "bar"), "-ERR no such key"},
+        {args("DBSIZE"), 3},
+        {args("UNKNOWN"), "-ERR unknown command"},
+        {args("GET"), "-ERR wrong number of arguments"},
+    })
+}
+
+func TestExpire(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    runCallTests(t, c, []callTest{
+        {args("SET", "foo", "bar", "EX", 10), "OK"},
+        {args("TTL", "foo"), 10},
+        {args("SETEX", "baz", 20, "bar"), "OK"},
+        {args("SET", "qux", "bar"), "OK"},
+        {args("TTL", "qux"), -1},
+        {args("TTL", "missing"), -2},
+        {args("EXPIRE", "qux", 30), 1},
+        {args("PERSIST", "qux"), 1},
+        {args("EXPIRE", "missing", 30), 0},
+    })
+
+    s.FastForward(10 * time.Second)
+
+    runCallTests(t, c, []callTest{
+        {args("GET", "foo"), nil},
+        {args("TTL", "baz"), 10},
+        {args("KEYS", "*"), []string{"baz", "qux"}},
+    })
+}
+
+func TestHashes(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    runCallTests(t, c, []callTest{
+        {args("HSET", "h", "a", 1, "b", 2), 2},
+        {args("HMSET", "h", "b", 3, "c", 4), "OK"},
+        {args("HGET", "h", "b"), "3"},
+        {args("HMGET", "h", "a", "x"), []string{"1", ""}},
+        {args("HGETALL", "h"), []string{"a", "1", "b", "3", "c", "4"}},
+        {args("HINCRBY", "h", "a", 5), 6},
+        {args("HSETNX", "h", "a", 0), 0},
+        {args("HEXISTS", "h", "a"), 1},
+        {args("HDEL", "h", "a", "b", "x"), 2},
+        {args("HLEN", "h"), 1},
+        {args("HKEYS", "h"), []string{"c"}},
+        {args("HVALS", "h"), []string{"4"}},
+        {args("HDEL", "h", "c"), 1},
+        {args("EXISTS", "h"), 0},
+        {args("SET", "s", "x"), "OK"},
+        {args("HGET", "s", "x"), "-WRONGTYPE"},
+    })
+}
+
+func TestLists(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    runCallTests(t, c, []callTest{
+        {args("RPUSH", "l", "a", "b", "c"), 3},
+        {args("LPUSH", "l", "z"), 4},
+        {args("LRANGE", "l", 0, -1), []string{"z", "a", "b", "c"}},
+        {args("LRANGE", "l", 1, 2), []string{"a", "b"}},
+        {args("LINDEX", "l", -1), "c"},
+        {args("LSET", "l", 0, "y"), "OK"},
+        {args("LPOP", "l"), "y"},
+        {args("RPOP", "l"), "c"},
+        {args("LLEN", "l"), 2},
+        {args("RPUSH", "l", "a", "a"), 4},
+        {args("LREM", "l", -2, "a"), 2},
+        {args("LRANGE", "l", 0, -1), []string{"a", "b"}},
+        {args("RPOPLPUSH", "l", "m"), "b"},
+        {args("LINSERT", "l", "BEFORE", "a", "x"), 2},
+        {args("LTRIM", "l", 1, 1), "OK"},
+        {args("LRANGE", "l", 0, -1), []string{"a"}},
+        {args("LPUSHX", "missing", "a"), 0},
+        {args("LPOP", "missing"), nil},
+    })
+}
+
+func TestSets(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    runCallTests(t, c, []callTest{
+        {args("SADD", "a", 1, 2, 3, 3), 3},
+        {args("SADD", "b", 2, 3, 4), 3},
+        {args("SMEMBERS", "a"), []string{"1", "2", "3"}},
+        {args("SISMEMBER", "a", 1), 1},
+        {args("SCARD", "a"), 3},
+        {args("SINTER", "a", "b"), []string{"2", "3"}},
+        {args("SUNION", "a", "b"), []string{"1", "2", "3", "4"}},
+        {args("SDIFF", "a", "b"), []string{"1"}},
+        {args("SINTERSTORE", "c", "a", "b"), 2},
+        {args("SMEMBERS", "c"), []string{"2", "3"}},
+        {args("SMOVE", "a", "b", 1), 1},
+        {args("SREM", "a", 2, 3), 2},
+        {args("EXISTS", "a"), 0},
+        {args("TYPE", "b"), "set"},
+    })
+}
+
+func TestSortedSets(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    runCallTests(t, c, []callTest{
+        {args("ZADD", "z", 1, "a", 2, "b", 3, "c"), 3},
+        {args("ZADD", "z", 1.5, "a"), 0},
+        {args("ZSCORE", "z", "a"), "1.5"},
+        {args("ZINCRBY", "z", 1, "a"), "2.5"},
+        {args("ZRANGE", "z", 0, -1), []string{"b", "a", "c"}},
+        {args("ZRANGE", "z", 0, 0, "WITHSCORES"), []string{"b", "2"}},
+        {args("ZREVRANGE", "z", 0, 1), []string{"c", "a"}},
+        {args("ZRANK", "z", "c"), 2},
+        {args("ZREVRANK", "z", "c"), 0},
+        {args("ZCARD", "z"), 3},
+        {args("ZCOUNT", "z", "(2", "+inf"), 2},
+        {args("ZRANGEBYSCORE", "z", "-inf", "(3"), []string{"b", "a"}},
+        {args("ZRANGEBYSCORE", "z", 0, 10, "LIMIT", 1, 1), []string{"a"}},
+        {args("ZREVRANGEBYSCORE", "z", 10, 0, "LIMIT", 0, 2), []string{"c", "a"}},
+        {args("ZADD", "y", 10, "a", 20, "d"), 2},
+        {args("ZINTERSTORE", "i", 2, "z", "y"), 1},
+        {args("ZRANGE", "i", 0, -1, "WITHSCORES"), []string{"a", "12.5"}},
+        {args("ZUNIONSTORE", "u", 2, "z", "y", "WEIGHTS", 1, 2, "AGGREGATE", "MAX"), 4},
+        {args("ZRANGE", "u", 0, -1), []string{"b", "c", "a", "d"}},
+        {args("ZREMRANGEBYSCORE", "z", 2, 2.5), 2},
+        {args("ZREMRANGEBYRANK", "z", 0, -1), 1},
+        {args("EXISTS", "z"), 0},
+    })
+}
+
+func TestSelect(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+
+    c0 := redis.NewClient(s.NetAddr(), 0, "")
+    c9 := redis.NewClient(s.NetAddr(), 9, "")
+
+    runCallTests(t, c9, []callTest{{args("SET", "foo", "bar"), "OK"}})
+    runCallTests(t, c0, []callTest{{args("GET", "foo"), nil}})
+    runCallTests(t, c9, []callTest{{args("GET", "foo"), "bar"}})
+}
+
+func TestAuth(t *testing.T) {
+    s := NewServer()
+    s.Password = "secret"
+    defer s.Close()
+
+    if _, e := redis.NewConn(s.Addr, "tcp", 0, "wrong"); e == nil {
+        t.Errorf("expected auth error")
+    }
+
+    c := redis.NewClient(s.NetAddr(), 0, "secret")
+    runCallTests(t, c, []callTest{{args("PING"), "PONG"}})
+}
+
+func TestMulti(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+
+    p := c.AsyncClient()
+    p.Call("MULTI")
+    p.Call("SET", "foo", "bar")
+    p.Call("INCR", "n")
+    p.Call("GET", "foo")
+    p.Call("EXEC")
+    replies, e := p.ReadAll()
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    exec := replies[4]
+
+    if exec.Len() != 3 || exec.Elems[0].Elem.String() != "OK" || exec.Elems[1].Elem.Int() != 1 || exec.Elems[2].Elem.String() != "bar" {
+        t.Errorf("unexpected exec reply %v", exec)
+    }
+
+    p.Close()
+}
+
+func TestWatch(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+    conn, e := redis.NewConn(s.Addr, "tcp", 0, "")
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    defer conn.Close()
+
+    for i, modify := range []bool{false, true} {
+        for _, cmd := range [][]interface{}{{"WATCH", "foo"}, {"MULTI"}, {"SET", "foo", i}} {
+            conn.Write(cmd...)
+
+            if _, e = conn.Read(); e != nil {
+                t.Fatal(e.Error())
+            }
+        }
+
+        if modify {
+            c.Call("SET", "foo", "other")
+        }
+
+        // the exp client returns an error for the nil multi-bulk reply of
+        // an aborted transaction
+        conn.Write("EXEC")
+        r, e := conn.Read()
+
+        if modify != (e != nil) {
+            t.Errorf("modified %v: unexpected exec reply %v, %v", modify, r, e)
+        }
+    }
+}
+
+func TestWatchTwice(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+    conn, e := redis.NewConn(s.Addr, "tcp", 0, "")
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    defer conn.Close()
+    conn.Write("WATCH", "foo")
+    conn.Read()
+    c.Call("SET", "foo", "other")
+
+    for _, cmd := range [][]interface{}{{"WATCH", "foo"}, {"MULTI"}, {"SET", "foo", "mine"}} {
+        conn.Write(cmd...)
+
+        if _, e = conn.Read(); e != nil {
+            t.Fatal(e.Error())
+        }
+    }
+
+    conn.Write("EXEC")
+
+    if r, e := conn.Read(); e == nil {
+        t.Errorf("expected an aborted transaction got %v", r)
+    }
+
+    if r, _ := c.Call("GET", "foo"); r.Elem.String() != "other" {
+        t.Errorf("expected foo to be kept got %q", r.Elem)
+    }
+}
+
+func TestPubsub(t *testing.T) {
+    s := NewServer()
+    defer s.Close()
+    c := redis.NewClient(s.NetAddr(), 0, "")
+    sub, e := redis.NewConn(s.Addr, "tcp", 0, "")
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    defer sub.Close()
+
+    sub.Write("SUBSCRIBE", "foo")
+    sub.Read()
+    sub.Write("PSUBSCRIBE", "f*")
+    sub.Read()
+
+    runCallTests(t, c, []callTest{
+        {args("PUBLISH", "foo", "hello"), 2},
+        {args("PUBLISH", "bar", "hello"), 0},
+    })
+
+    for _, typ := range []string{"message", "pmessage"} {
+        r, e := sub.Read()
+
+        if e != nil {
+            t.Fatal(e.Error())
+        }
+
+        m := r.Message()
+
+        if r.Elems[0].Elem.String() != typ || m == nil || m.Channel != "foo" || m.Elem.String() != "hello" {
+            t.Errorf("unexpected message %v", r)
+        }
+    }
+
+    sub.Write("GET", "foo")
+
+    if _, e = sub.Read(); e == nil {
+        t.Errorf("expected error for GET in subscribed state")
+    }
+}
+
+func TestMatch(t *testing.T) {
+    tests := []struct {
+        pattern, s string
+        match      bool
+    }{
+        {"*", "", true},
+        {"*", "foo", true},
+        {"f*", "foo", true},
+        {"f*o", "foo", true},
+        {"f*x", "foo", false},
+        {"f?o", "foo", true},
+        {"f?o", "fo", false},
+        {"h[ae]llo", "hello", true},
+        {"h[^e]llo", "hello", false},
+        {"h[a-c]llo", "hbllo", true},
+        {"h\\*llo", "h*llo", true},
+        {"h\\*llo", "hello", false},
+        {"user:*:name", "user:1:name", true},
+    }
+
+    for _, test := range tests {
+        if match(test.pattern, test.s) != test.match {
+            t.Errorf("match(%q, %q): expected %v", test.pattern, test.s, test.match)
+        }
+    }
+}
@@ -0,0 +1,308 @@
+package schema
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "sync"
+)
+
+// field describes a struct field stored as a field of the hash.
+type field struct {
+    name string
+
+    // index is the path of the field through embedded structs, as used by
+    // reflect.Value.FieldByIndex
+    index []int
+    typ   reflect.Type
+
+    omitempty bool
+    unique    bool
+    indexed   bool
+    ranged    bool
+
+    // json is set if the value is stored as JSON, owned names the type
+    // of the owned key storing the value
+    json  bool
+    owned string
+}
+
+// codec describes how a struct type is stored as a hash. It is built from
+// the `redis` tags of the type once:
+//
+//      Name  string `redis:"name,unique,index"`
+//      Score int    `redis:",range,omitempty"`
+//      Cache []byte `redis:"-"`
+//
+// The tag names the hash field, the Go field name is used without it. The
+// options are id, omitempty, unique, index and range, a field tagged "-" is
+// not stored. The fields of embedded structs are stored as fields of the outer
+// struct, an outer field hides an embedded field of the same name.
+//
+// The fields of other struct fields are flattened into hash fields named
+// with a dot, `Address.City`, a struct type containing itself can not be
+// flattened. Slices and maps are stored as JSON, the json option stores a
+// struct as JSON as well. The list, set and hash options store a field in a
+// key owned by the entity instead of the hash.
+type codec struct {
+    fields []*field
+
+    // ptrs are the indexes of pointers to embedded and nested structs,
+    // outer pointers first
+    ptrs [][]int
+
+    // id is the field tagged with the id option, or else the field named
+    // Id closest to the outer struct
+    id     *field
+    tagged bool
+    err    error
+}
+
+func newRecursiveError(t reflect.Type) InternalError {
+    return InternalError(fmt.Sprintf("Type `%s` contains itself, store the field with the json option or skip it with `-`", t))
+}
+
+var (
+    codecsMu sync.Mutex
+    codecs   = make(map[reflect.Type]*codec)
+)
+
+// codecOf returns the codec of the struct type t.
+func codecOf(t reflect.Type) (*codec, error) {
+    codecsMu.Lock()
+    c, ok := codecs[t]
+    codecsMu.Unlock()
+
+    if !ok {
+        c = new(codec)
+        c.err = c.add(t, nil, "", make(map[string]*field), make(map[reflect.Type]bool))
+        codecsMu.Lock()
+        codecs[t] = c
+        codecsMu.Unlock()
+    }
+
+    return c, c.err
+}
+
+// isEmbedded returns whether the fields of the struct field f are stored as
+// fields of the outer struct.
+func isEmbedded(f reflect.StructField) bool {
+    if name, _ := parseTag(f.Tag.Get("redis")); !f.Anonymous || name != "" {
+        return false
+    }
+
+    // a nil pointer to an unexported type can not be allocated
+    if f.Type.Kind() == reflect.Ptr && len(f.PkgPath) > 0 {
+        return false
+    }
+
+    t := elemType(f.Type)
+    return t.Kind() == reflect.Struct && t != timeType && !hasText(t)
+}
+
+// isNested returns whether the fields of a struct field of type t are
+// flattened into the hash.
+func isNested(t reflect.Type) bool {
+    t = elemType(t)
+    return t.Kind() == reflect.Struct && t != timeType && !hasText(t)
+}
+
+// add adds the fields of the struct type t at index to c, their names are
+// prefixed with prefix. expanding holds the struct types whose fields are
+// being added, a type can not be flattened into itself.
+func (c *codec) add(t reflect.Type, index []int, prefix string, names map[string]*field, expanding map[reflect.Type]bool) error {
+    if expanding[t] {
+        return newRecursiveError(t)
+    }
+
+    expanding[t] = true
+    defer delete(expanding, t)
+
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        tag := sf.Tag.Get("redis")
+
+        if tag == "-" {
+            continue
+        }
+
+        idx := append(append([]int{}, index...), i)
+
+        if isEmbedded(sf) {
+            if sf.Type.Kind() == reflect.Ptr {
+                c.ptrs = append(c.ptrs, idx)
+            }
+
+            if e := c.add(elemType(sf.Type), idx, prefix, names, expanding); e != nil {
+                return e
+            }
+
+            continue
+        }
+
+        if len(sf.PkgPath) > 0 {
+            continue
+        }
+
+        name, opt := parseTag(tag)
+
+        if name == "" {
+            name = sf.Name
+        }
+
+        name = prefix + name
+        f := &field{
+            name:      name,
+            index:     idx,
+            typ:       sf.Type,
+            omitempty: opt.Contains("omitempty"),
+            unique:    opt.Contains("unique"),
+            indexed:   opt.Contains("index"),
+            ranged:    opt.Contains("range"),
+            json:      opt.Contains("json"),
+        }
+
+        for _, o := range []string{ownedList, ownedSet, ownedHash} {
+            if opt.Contains(o) {
+                f.owned = o
+            }
+        }
+
+        ft := elemType(sf.Type)
+
+        switch {
+        case f.owned != "":
+            if e := checkOwned(name, f.owned, sf.Type); e != nil {
+                return e
+            }
+
+            for _, o := range []string{"unique", "index", "range", "omitempty", "json"} {
+                if opt.Contains(o) {
+                    return newOptionError(name, o)
+                }
+            }
+        case f.json:
+        case isNested(ft):
+            if sf.Type.Kind() == reflect.Ptr {
+                c.ptrs = append(c.ptrs, idx)
+            }
+
+            if e := c.add(ft, idx, name+".", names, expanding); e != nil {
+                return e
+            }
+
+            continue
+        case ft.Kind() == reflect.Map, ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8:
+            f.json = true
+        default:
+            if e := checkType(name, sf.Type); e != nil {
+                return e
+            }
+
+            switch {
+            case opt.Contains("id"):
+                if !c.tagged {
+                    c.id, c.tagged = f, true
+                }
+            case sf.Name == "Id" && !c.tagged && (c.id == nil || len(idx) < len(c.id.index)):
+                c.id = f
+            }
+        }
+
+        if f.ranged {
+            if _, e := rangeScore(reflect.Zero(elemType(sf.Type))); e != nil {
+                return e
+            }
+        }
+
+        old, ok := names[name]
+
+        if !ok {
+            names[name] = f
+            c.fields = append(c.fields, f)
+            continue
+        }
+
+        // the field closest to the outer struct wins
+        if len(idx) < len(old.index) {
+            names[name] = f
+
+            for j := range c.fields {
+                if c.fields[j] == old {
+                    c.fields[j] = f
+                }
+            }
+        }
+    }
+
+    return nil
+}
+
+// walk returns the field at index of the struct v. ok is false if the
+// field is behind a nil pointer to a struct, unless alloc is set and the
+// pointer is allocated.
+func walk(v reflect.Value, index []int, alloc bool) (fv reflect.Value, ok bool) {
+    for i, x := range index {
+        if i > 0 && v.Kind() == reflect.Ptr {
+            if v.IsNil() {
+                if !alloc {
+                    return v, false
+                }
+
+                v.Set(reflect.New(v.Type().Elem()))
+            }
+
+            v = v.Elem()
+        }
+
+        v = v.Field(x)
+    }
+
+    return v, true
+}
+
+// value returns the field of the struct v. ok is false if the field is in
+// an embedded or nested struct behind a nil pointer.
+func (f *field) value(v reflect.Value) (fv reflect.Value, ok bool) {
+    return walk(v, f.index, false)
+}
+
+// alloc returns the field of the struct v, nil pointers to embedded and
+// nested structs are allocated.
+func (f *field) alloc(v reflect.Value) reflect.Value {
+    fv, _ := walk(v, f.index, true)
+    return fv
+}
+
+// encode returns the hash value of the field value v, ok is false if it is
+// absent.
+func (f *field) encode(v reflect.Value) (value string, ok bool, e error) {
+    if !f.json {
+        return encodeValue(f.name, v)
+    }
+
+    if v.Kind() == reflect.Ptr && v.IsNil() {
+        return "", false, nil
+    }
+
+    b, e := json.Marshal(v.Interface())
+
+    if e != nil {
+        return "", false, newValueError(f.name, e.Error())
+    }
+
+    return string(b), true, nil
+}
+
+// decode sets the field value v to the value decoded from the hash value s.
+func (f *field) decode(v reflect.Value, s string) error {
+    if !f.json {
+        return decodeValue(f.name, v, s)
+    }
+
+    if e := json.Unmarshal([]byte(s), v.Addr().Interface()); e != nil {
+        return newValueError(f.name, s)
+    }
+
+    return nil
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+    "reflect"
+    "testing"
+)
+
+type Tagged struct {
+    Id      int64  `redis:"id"`
+    Name    string `redis:"name,unique"`
+    Score   int    `redis:",range,omitempty"`
+    Comment string `redis:",omitempty"`
+    Cache   []byte `redis:"-"`
+}
+
+type inner struct {
+    Name  string
+    Inner string
+}
+
+type Outer struct {
+    inner
+    Id   int64
+    Name string `redis:"name"`
+}
+
+func TestCodec(t *testing.T) {
+    c, e := codecOf(reflect.TypeOf(Tagged{}))
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if c2, _ := codecOf(reflect.TypeOf(Tagged{})); c2 != c {
+        t.Errorf("expected the cached codec")
+    }
+
+    var names []string
+
+    for _, f := range c.fields {
+        names = append(names, f.name)
+    }
+
+    if !reflect.DeepEqual(names, []string{"id", "name", "Score", "Comment"}) {
+        t.Errorf("unexpected fields %v", names)
+    }
+
+    if f := c.fields[2]; !f.ranged || !f.omitempty || f.unique || f.indexed {
+        t.Errorf("unexpected options %+v", f)
+    }
+
+    // the outer field hides the embedded field of the same name
+    c, _ = codecOf(reflect.TypeOf(Outer{}))
+    names = names[:0]
+
+    for _, f := range c.fields {
+        names = append(names, f.name)
+    }
+
+    if !reflect.DeepEqual(names, []string{"Name", "Inner", "Id", "name"}) {
+        t.Errorf("unexpected fields %v", names)
+    }
+}
+
+func TestCodecTags(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("tagged", 1)
+
+    if _, e := Put(db, k, &Tagged{1, "foo", 10, "", []byte("x")}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    r, _ := db.Call("HGETALL", k.String())
+    h := r.StringMap()
+
+    if len(h) != 3 || h["id"] != "1" || h["name"] != "foo" || h["Score"] != "10" {
+        t.Errorf("unexpected hash %q", h)
+    }
+
+    out := Tagged{Comment: "stale", Cache: []byte("y")}
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if out.Id != 1 || out.Name != "foo" || out.Score != 10 || out.Comment != "" || string(out.Cache) != "y" {
+        t.Errorf("unexpected struct %+v", out)
+    }
+
+    // an empty omitempty field is removed with its range entry
+    if _, e := Put(db, k, &Tagged{1, "foo", 0, "", nil}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if r, _ := db.Call("HEXISTS", k.String(), "Score"); r.Elem.Int() != 0 {
+        t.Errorf("expected Score to be absent")
+    }
+
+    if r, _ := db.Call("ZCARD", k.Range("Score")); r.Elem.Int() != 0 {
+        t.Errorf("expected the range entry of Score to be removed")
+    }
+
+    var u User
+
+    if _, e := Put(db, NewKey("user", 1), &User{1, "foo", "foo@foo.com"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if e := Get(db, NewKey("user", 1), &u); e != nil || u.Username != "foo" || u.Email != "foo@foo.com" {
+        t.Errorf("expected user foo got %+v, %v", u, e)
+    }
+}
+
+func TestCodecEmbedded(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("outer", 1)
+
+    if _, e := Put(db, k, &Outer{inner{"a", "b"}, 1, "c"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    var out Outer
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if out != (Outer{inner{"a", "b"}, 1, "c"}) {
+        t.Errorf("unexpected struct %+v", out)
+    }
+}
+
+type Node struct {
+    Id   int64
+    Next *Node
+}
+
+type Chain struct {
+    *Chain
+    Id int64
+}
+
+type List struct {
+    Id   int64
+    Next *List `redis:",json"`
+}
+
+func TestCodecRecursive(t *testing.T) {
+    if _, e := codecOf(reflect.TypeOf(Node{})); e != newRecursiveError(reflect.TypeOf(Node{})) {
+        t.Errorf("expected a recursive type error got %v", e)
+    }
+
+    if _, e := codecOf(reflect.TypeOf(Chain{})); e != newRecursiveError(reflect.TypeOf(Chain{})) {
+        t.Errorf("expected a recursive type error got %v", e)
+    }
+
+    if _, e := Put(db, NewKey("node", 1), &Node{Id: 1}); e == nil {
+        t.Errorf("expected Put to fail")
+    }
+
+    // a recursive type stored as JSON is not flattened
+    putAll(t, "list", &List{1, &List{2, nil}})
+    var l List
+
+    if e := Get(db, NewKey("list", 1), &l); e != nil || l.Next == nil || l.Next.Id != 2 || l.Next.Next != nil {
+        t.Errorf("expected the list got %+v, %v", l, e)
+    }
+}
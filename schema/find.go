@@ -0,0 +1,242 @@
+package schema
+
+import (
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+
+    "code.google.com/p/tcgl/monitoring"
+    "insmo.com/godis/exp"
+)
+
+var sliceTypeError = InternalError("Invalid type, expected pointer to slice of structs")
+
+// lessId orders integer ids numerically before string ids.
+func lessId(a, b string) bool {
+    x, ae := strconv.ParseInt(a, 10, 64)
+    y, be := strconv.ParseInt(b, 10, 64)
+
+    switch {
+    case ae == nil && be == nil:
+        return x < y
+    case ae == nil || be == nil:
+        return ae == nil
+    }
+
+    return a < b
+}
+
+// sortIds sorts ids by lessId, reversed if desc is set.
+func sortIds(ids []string, desc bool) {
+    sort.Slice(ids, func(i, j int) bool {
+        if desc {
+            return lessId(ids[j], ids[i])
+        }
+
+        return lessId(ids[i], ids[j])
+    })
+}
+
+// indexIds returns the ids in the index of field and value, sorted.
+func indexIds(db *redis.Client, kind, field string, value interface{}) ([]string, error) {
+    v, e := encodeLookup(field, value)
+
+    if e != nil {
+        return nil, e
+    }
+
+    k := NewKey(kind, 0)
+    reply, e := db.Call("SMEMBERS", k.Index(field, v))
+
+    // an index key not yet converted by Put holds a single id
+    if e != nil && strings.HasPrefix(e.Error(), "WRONGTYPE") {
+        if reply, e = db.Call("GET", k.Index(field, v)); e == nil {
+            return []string{reply.Elem.String()}, nil
+        }
+    }
+
+    if e != nil {
+        return nil, e
+    }
+
+    ids := reply.StringArray()
+    sortIds(ids, false)
+    return ids, nil
+}
+
+// Find fills dst, a pointer to a struct, with the entity of kind with the
+// lowest id whose indexed field has value. It returns the key of the
+// entity or a nil-entity error if none matches.
+func Find(db *redis.Client, kind, field string, value interface{}, dst interface{}) (*Key, error) {
+    ids, e := indexIds(db, kind, field, value)
+
+    if e != nil {
+        return nil, e
+    }
+
+    for _, id := range ids {
+        k := NewStringKey(kind, id)
+        e = Get(db, k, dst)
+
+        // the entity was deleted after reading the index
+        if e == nilError {
+            continue
+        }
+
+        if e != nil {
+            return nil, e
+        }
+
+        return k, nil
+    }
+
+    return nil, nilError
+}
+
+// FindAll sets dst, a pointer to a slice of structs or of pointers to
+// structs, to all entities of kind whose indexed field has value, ordered
+// by id. It returns their keys.
+func FindAll(db *redis.Client, kind, field string, value interface{}, dst interface{}) ([]*Key, error) {
+    mon := monitoring.BeginMeasuring("database:findall")
+    defer mon.EndMeasuring()
+    slice, e := sliceOf(dst)
+
+    if e != nil {
+        return nil, e
+    }
+
+    ids, e := indexIds(db, kind, field, value)
+
+    if e != nil {
+        return nil, e
+    }
+
+    keys, hashes, e := load(db, kind, ids)
+
+    if e != nil {
+        return nil, e
+    }
+
+    if e = fill(db, slice, keys, hashes); e != nil {
+        return nil, e
+    }
+
+    return keys, nil
+}
+
+// FindByUnique fills dst, a pointer to a struct, with the entity of kind
+// whose unique field has value. It returns the key of the entity or a
+// nil-entity error if there is none.
+func FindByUnique(db *redis.Client, kind, field string, value interface{}, dst interface{}) (*Key, error) {
+    v, e := encodeLookup(field, value)
+
+    if e != nil {
+        return nil, e
+    }
+
+    k := NewKey(kind, 0)
+    reply, e := db.Call("GET", k.Unique(field, v))
+
+    if e != nil {
+        return nil, e
+    }
+
+    if reply.Nil() {
+        return nil, nilError
+    }
+
+    k.id = reply.Elem.String()
+
+    if e = Get(db, k, dst); e != nil {
+        return nil, e
+    }
+
+    return k, nil
+}
+
+// load reads the hashes of ids in one pipeline. Entities which no longer
+// exist are skipped.
+func load(db *redis.Client, kind string, ids []string) ([]*Key, []map[string]redis.Elem, error) {
+    if len(ids) == 0 {
+        return nil, nil, nil
+    }
+
+    ac := db.AsyncClient()
+    defer ac.Close()
+    keys := make([]*Key, len(ids))
+
+    for i, id := range ids {
+        keys[i] = NewStringKey(kind, id)
+        ac.Call("HGETALL", keys[i].String())
+    }
+
+    replies, e := ac.ReadAll()
+
+    if e != nil {
+        return nil, nil, e
+    }
+
+    found := keys[:0]
+    hashes := make([]map[string]redis.Elem, 0, len(replies))
+
+    for i, r := range replies {
+        if r.Len() > 0 {
+            found = append(found, keys[i])
+            hashes = append(hashes, r.Hash())
+        }
+    }
+
+    return found, hashes, nil
+}
+
+// sliceOf returns the slice dst points to. Its elements must be structs or
+// pointers to structs.
+func sliceOf(dst interface{}) (reflect.Value, error) {
+    v := reflect.ValueOf(dst)
+
+    if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+        return v, sliceTypeError
+    }
+
+    et := v.Elem().Type().Elem()
+
+    if et.Kind() == reflect.Ptr {
+        et = et.Elem()
+    }
+
+    if et.Kind() != reflect.Struct {
+        return v, sliceTypeError
+    }
+
+    return v.Elem(), nil
+}
+
+// fill sets slice to the entities of keys inflated from hashes.
+func fill(db *redis.Client, slice reflect.Value, keys []*Key, hashes []map[string]redis.Elem) error {
+    et := slice.Type().Elem()
+    st := et
+
+    if et.Kind() == reflect.Ptr {
+        st = et.Elem()
+    }
+
+    out := reflect.MakeSlice(slice.Type(), 0, len(hashes))
+
+    for i, h := range hashes {
+        ev := reflect.New(st)
+
+        if e := inflate(db, keys[i], ev.Interface(), h); e != nil {
+            return e
+        }
+
+        if et.Kind() != reflect.Ptr {
+            ev = ev.Elem()
+        }
+
+        out = reflect.Append(out, ev)
+    }
+
+    slice.Set(out)
+    return nil
+}
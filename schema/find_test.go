@@ -0,0 +1,154 @@
+package schema
+
+import (
+    "testing"
+    "time"
+)
+
+type Post struct {
+    Id     int64
+    Author string `redis:",index"`
+    Title  string `redis:",unique"`
+}
+
+func TestFind(t *testing.T) {
+    putAll(t, "post", &Post{3, "foo", "c"}, &Post{1, "bar", "a"}, &Post{2, "foo", "b"})
+    var p Post
+    k, e := Find(db, "post", "Author", "foo", &p)
+
+    if e != nil || k.Id() != 2 || p.Id != 2 || p.Title != "b" {
+        t.Errorf("expected post 2 got %v, %+v, %v", k, p, e)
+    }
+
+    if _, e = Find(db, "post", "Author", "baz", &p); e != nilError {
+        t.Errorf("expected nilError got %v", e)
+    }
+}
+
+func TestFindAll(t *testing.T) {
+    putAll(t, "post", &Post{3, "foo", "c"}, &Post{1, "bar", "a"}, &Post{2, "foo", "b"})
+
+    // a deleted entity is skipped even if its index entry is left behind
+    db.Call("SADD", NewKey("post", 0).Index("Author", "foo"), 4)
+
+    var posts []Post
+    keys, e := FindAll(db, "post", "Author", "foo", &posts)
+
+    if e != nil || len(keys) != 2 || len(posts) != 2 {
+        t.Fatalf("expected 2 posts got %v, %v", posts, e)
+    }
+
+    if posts[0].Id != 2 || posts[1].Id != 3 || keys[1].String() != "post:3" {
+        t.Errorf("expected posts 2 and 3 got %+v", posts)
+    }
+
+    var ptrs []*Post
+
+    if _, e = FindAll(db, "post", "Author", "bar", &ptrs); e != nil || len(ptrs) != 1 || ptrs[0].Title != "a" {
+        t.Errorf("expected post 1 got %v, %v", ptrs, e)
+    }
+
+    if _, e = FindAll(db, "post", "Author", "baz", &ptrs); e != nil || len(ptrs) != 0 {
+        t.Errorf("expected no posts got %v, %v", ptrs, e)
+    }
+
+    if _, e = FindAll(db, "post", "Author", "foo", posts); e != sliceTypeError {
+        t.Errorf("expected sliceTypeError got %v", e)
+    }
+}
+
+func TestFindByUnique(t *testing.T) {
+    putAll(t, "post", &Post{1, "foo", "a"}, &Post{2, "foo", "b"})
+    var p Post
+    k, e := FindByUnique(db, "post", "Title", "b", &p)
+
+    if e != nil || k.Id() != 2 || p.Id != 2 || p.Author != "foo" {
+        t.Errorf("expected post 2 got %v, %+v, %v", k, p, e)
+    }
+
+    if _, e = FindByUnique(db, "post", "Title", "c", &p); e != nilError {
+        t.Errorf("expected nilError got %v", e)
+    }
+}
+
+func TestLegacyIndex(t *testing.T) {
+    putAll(t, "post", &Post{1, "bar", "a"})
+    k := NewKey("post", 0)
+
+    // post 1 as indexed when index keys were strings
+    db.Call("DEL", k.Index("Author", "bar"))
+    db.Call("SET", k.Index("Author", "foo"), 1)
+    db.Call("HSET", "post:1", "Author", "foo")
+    var p Post
+
+    if k, e := Find(db, "post", "Author", "foo", &p); e != nil || k.Id() != 1 {
+        t.Errorf("expected post 1 got %v, %v", k, e)
+    }
+
+    if _, e := Put(db, NewKey("post", 2), &Post{2, "foo", "b"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if r, e := db.Call("SMEMBERS", k.Index("Author", "foo")); e != nil || r.Len() != 2 {
+        t.Errorf("expected posts 1 and 2 got %v, %v", r, e)
+    }
+
+    db.Call("SET", k.Index("Author", "foo"), 1)
+
+    if _, e := Put(db, NewKey("post", 1), &Post{1, "bar", "a"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if r, e := db.Call("EXISTS", k.Index("Author", "foo")); e != nil || r.Elem.Int() != 0 {
+        t.Errorf("expected the index of foo to be removed got %v, %v", r, e)
+    }
+
+    db.Call("SET", k.Index("Author", "bar"), 1)
+
+    if e := Delete(db, NewKey("post", 1), &p); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if r, e := db.Call("EXISTS", k.Index("Author", "bar")); e != nil || r.Elem.Int() != 0 {
+        t.Errorf("expected the index of bar to be removed got %v, %v", r, e)
+    }
+}
+
+type Meeting struct {
+    Id   int64
+    At   time.Time     `redis:",index"`
+    Room *string       `redis:",index"`
+    Slot time.Duration `redis:",unique"`
+}
+
+func TestFindEncoded(t *testing.T) {
+    at := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+    room := "a"
+    putAll(t, "meeting", &Meeting{1, at, &room, time.Hour}, &Meeting{2, at.Add(time.Hour), nil, 2 * time.Hour})
+
+    var m Meeting
+
+    if k, e := Find(db, "meeting", "At", at, &m); e != nil || k.Id() != 1 {
+        t.Errorf("expected meeting 1 got %v, %v", k, e)
+    }
+
+    if k, e := Find(db, "meeting", "Room", &room, &m); e != nil || k.Id() != 1 {
+        t.Errorf("expected meeting 1 got %v, %v", k, e)
+    }
+
+    if k, e := FindByUnique(db, "meeting", "Slot", 2*time.Hour, &m); e != nil || k.Id() != 2 || !m.At.Equal(at.Add(time.Hour)) {
+        t.Errorf("expected meeting 2 got %v, %+v, %v", k, m, e)
+    }
+
+    if keys, e := NewQuery("meeting").WhereIn("At", at, at.Add(time.Hour)).Keys(db); e != nil || len(keys) != 2 {
+        t.Errorf("expected 2 meetings got %v, %v", keys, e)
+    }
+
+    if _, e := Find(db, "meeting", "Room", nil, &m); e == nil {
+        t.Errorf("expected an error for a nil value")
+    }
+
+    if _, e := NewQuery("meeting").Where("At", make(chan int)).Keys(db); e == nil {
+        t.Errorf("expected an error for a chan value")
+    }
+}
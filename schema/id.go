@@ -0,0 +1,87 @@
+package schema
+
+import (
+    "crypto/rand"
+    "fmt"
+    "sync"
+
+    "insmo.com/godis/exp"
+)
+
+// IdGenerator returns a new id for an entity of kind.
+type IdGenerator func(db *redis.Client, kind string) (string, error)
+
+// generator is the IdGenerator of a kind. integer is set if its ids are
+// integers, only then they can be stored in an id field of integer type.
+type generator struct {
+    gen     IdGenerator
+    integer bool
+}
+
+var (
+    generatorsMu sync.Mutex
+    generators   = make(map[string]generator)
+)
+
+// SetIdGenerator sets the generator of the ids of new entities of kind, nil
+// restores the default CounterId. Its ids are strings, they are stored in an
+// id field of string type.
+//
+//      schema.SetIdGenerator("session", schema.UUID)
+func SetIdGenerator(kind string, gen IdGenerator) {
+    setGenerator(kind, generator{gen, false})
+}
+
+// SetIntIdGenerator sets a generator of integer ids for kind, like
+// SetIdGenerator. Its ids are stored in an id field of integer or string
+// type.
+func SetIntIdGenerator(kind string, gen IdGenerator) {
+    setGenerator(kind, generator{gen, true})
+}
+
+func setGenerator(kind string, gen generator) {
+    generatorsMu.Lock()
+    defer generatorsMu.Unlock()
+
+    if gen.gen == nil {
+        delete(generators, kind)
+    } else {
+        generators[kind] = gen
+    }
+}
+
+func generatorOf(kind string) generator {
+    generatorsMu.Lock()
+    defer generatorsMu.Unlock()
+
+    if gen, ok := generators[kind]; ok {
+        return gen
+    }
+
+    return generator{CounterId, true}
+}
+
+// CounterId returns the next integer id of kind, counted by the count key
+// of kind. It is the default generator.
+func CounterId(db *redis.Client, kind string) (string, error) {
+    reply, e := db.Call("INCR", NewKey(kind, 0).Count())
+
+    if e != nil {
+        return "", e
+    }
+
+    return reply.Elem.String(), nil
+}
+
+// UUID returns a random (version 4) UUID.
+func UUID(db *redis.Client, kind string) (string, error) {
+    b := make([]byte, 16)
+
+    if _, e := rand.Read(b); e != nil {
+        return "", e
+    }
+
+    b[6] = b[6]&0x0f | 0x40
+    b[8] = b[8]&0x3f | 0x80
+    return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
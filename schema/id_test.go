@@ -0,0 +1,196 @@
+package schema
+
+import (
+    "regexp"
+    "testing"
+
+    "insmo.com/godis/exp"
+)
+
+type Session struct {
+    Token string `redis:"token,id"`
+    User  string `redis:"user,index"`
+    Hits  int    `redis:"hits,range"`
+}
+
+type Article struct {
+    Num   int32 `redis:",id"`
+    Title string
+}
+
+func TestParseKey(t *testing.T) {
+    tests := []struct {
+        s        string
+        kind, id string
+        e        error
+    }{
+        {"user:1", "user", "1", nil},
+        {"session:4f1c-9a", "session", "4f1c-9a", nil},
+        {"email:foo@foo.com", "email", "foo@foo.com", nil},
+        {"email:foo@foo.com:home", "", "", newIdError("foo@foo.com:home")},
+        {"user:count", "", "", newIdError("count")},
+        {"user", "", "", keyError},
+        {":1", "", "", keyError},
+        {"user:", "", "", keyError},
+    }
+
+    for _, test := range tests {
+        k, e := ParseKey(test.s)
+
+        if e != test.e {
+            t.Errorf("%s: expected error %v got %v", test.s, test.e, e)
+            continue
+        }
+
+        if e == nil && (k.Kind() != test.kind || k.StringId() != test.id || k.String() != test.s) {
+            t.Errorf("%s: unexpected key %q %q", test.s, k.Kind(), k.StringId())
+        }
+    }
+
+    if k, _ := ParseKey("user:42"); k.Id() != 42 {
+        t.Errorf("expected id 42 got %d", k.Id())
+    }
+
+    if k := NewStringKey("session", "abc"); k.Id() != 0 || k.String() != "session:abc" {
+        t.Errorf("unexpected key %v", k)
+    }
+}
+
+func TestStringIds(t *testing.T) {
+    db.Call("FLUSHDB")
+    SetIdGenerator("session", UUID)
+    defer SetIdGenerator("session", nil)
+    uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+    for i := 0; i < 3; i++ {
+        s := &Session{User: "foo", Hits: i}
+        k, e := Put(db, NewStringKey("session", ""), s)
+
+        if e != nil {
+            t.Fatal(e.Error())
+        }
+
+        if !uuid.MatchString(k.StringId()) || s.Token != k.StringId() {
+            t.Errorf("expected a uuid got %q, %q", k.StringId(), s.Token)
+        }
+    }
+
+    if r, _ := db.Call("EXISTS", NewKey("session", 0).Count()); r.Elem.Int() != 0 {
+        t.Errorf("expected no counter for uuid ids")
+    }
+
+    var sessions []Session
+    keys, e := NewQuery("session").Where("user", "foo").SortBy("hits").Desc().Find(db, &sessions)
+
+    if e != nil || len(keys) != 3 || sessions[0].Hits != 2 || sessions[0].Token != keys[0].StringId() {
+        t.Fatalf("expected 3 sessions got %+v, %v", sessions, e)
+    }
+
+    var s Session
+    k, _ := ParseKey(keys[1].String())
+
+    if e = Get(db, k, &s); e != nil || s.Hits != 1 {
+        t.Errorf("expected session 1 got %+v, %v", s, e)
+    }
+
+    if e = Delete(db, k, &Session{}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if keys, _ = FindAll(db, "session", "user", "foo", &sessions); len(keys) != 2 {
+        t.Errorf("expected 2 sessions got %v", keys)
+    }
+}
+
+func TestReservedIds(t *testing.T) {
+    putAll(t, "user", &User{1, "foo", "foo@foo.com"})
+    k := NewKey("user", 1)
+
+    // each id would name the count, an index key or an owned key of user 1
+    for _, id := range []string{"count", "index:username:foo", "1:Tags", "range", "tmp", "tmp:0123456789abcdef"} {
+        if _, e := Put(db, NewStringKey("user", id), &User{}); e != newIdError(id) {
+            t.Errorf("%s: expected an id error got %v", id, e)
+        }
+
+        if e := Get(db, NewStringKey("user", id), &User{}); e != newIdError(id) {
+            t.Errorf("%s: expected an id error got %v", id, e)
+        }
+
+        if e := Delete(db, NewStringKey("user", id), &User{}); e != newIdError(id) {
+            t.Errorf("%s: expected an id error got %v", id, e)
+        }
+    }
+
+    if r, e := db.Call("TYPE", k.Index("username", "foo")); e != nil || r.Elem.String() != "set" {
+        t.Errorf("expected the index set to be kept got %v, %v", r, e)
+    }
+
+    // a generator may not return a reserved id either
+    SetIdGenerator("user", func(db *redis.Client, kind string) (string, error) {
+        return "count", nil
+    })
+    defer SetIdGenerator("user", nil)
+
+    if _, e := Put(db, NewKey("user", 0), &struct {
+        Id   string
+        Name string
+    }{}); e != newIdError("count") {
+        t.Errorf("expected an id error got %v", e)
+    }
+}
+
+func TestIdTag(t *testing.T) {
+    db.Call("FLUSHDB")
+    a := &Article{Title: "foo"}
+    k, e := Put(db, NewKey("article", 0), a)
+
+    if e != nil || k.Id() != 1 || a.Num != 1 {
+        t.Fatalf("expected article 1 got %v, %+v, %v", k, a, e)
+    }
+
+    SetIdGenerator("article", UUID)
+    defer SetIdGenerator("article", nil)
+
+    if _, e = Put(db, NewKey("article", 0), &Article{Title: "bar"}); e != idFieldError {
+        t.Errorf("expected idFieldError got %v", e)
+    }
+
+    // the generator is not called for an id field which can not hold its
+    // ids
+    n := 0
+    counter := func(db *redis.Client, kind string) (string, error) {
+        n++
+        return CounterId(db, kind)
+    }
+
+    SetIdGenerator("article", counter)
+
+    if _, e = Put(db, NewKey("article", 0), &Article{Title: "bar"}); e != idFieldError || n != 0 {
+        t.Errorf("expected idFieldError without a new id got %v, %d", e, n)
+    }
+
+    SetIntIdGenerator("article", counter)
+
+    if k, e = Put(db, NewKey("article", 0), &Article{Title: "bar"}); e != nil || k.Id() != 2 || n != 1 {
+        t.Errorf("expected article 2 got %v, %v", k, e)
+    }
+
+    type noId struct {
+        Name string
+    }
+
+    if _, e = Put(db, NewKey("noid", 0), &noId{}); e != idFieldError {
+        t.Errorf("expected idFieldError got %v", e)
+    }
+}
+
+func TestSortIds(t *testing.T) {
+    ids := []string{"b", "10", "2", "a", "1"}
+    sortIds(ids, false)
+
+    for i, id := range []string{"1", "2", "10", "a", "b"} {
+        if ids[i] != id {
+            t.Fatalf("unexpected order %v", ids)
+        }
+    }
+}
@@ -2,15 +2,34 @@ package schema
 
 import (
     "fmt"
+    "strconv"
+    "strings"
 )
 
+var keyError = InternalError("Invalid key, expected kind:id")
+
+// reservedIds name the keys derived from a kind, `kind:count` and the
+// prefixes of the unique, index, range and temporary query keys.
+var reservedIds = map[string]bool{"count": true, "unique": true, "index": true, "range": true, "tmp": true}
+
+func newIdError(id string) InternalError {
+    return InternalError(fmt.Sprintf("Invalid id `%s`, an id can not contain a colon or name a derived key", id))
+}
+
+// Key names an entity by its kind and id. A key without id refers to a
+// new entity, Put assigns it an id from the generator of its kind.
+//
+// The keys derived from a kind share its namespace with the entities, so a
+// string id can not contain a colon and can not be one of the reserved
+// names count, unique, index, range and tmp. Put, Get and Delete return an
+// error for such an id.
 type Key struct {
     kind string
-    id   int64
+    id   string
 }
 
 func (k *Key) String() string {
-    return fmt.Sprintf("%s:%d", k.kind, k.id)
+    return fmt.Sprintf("%s:%s", k.kind, k.id)
 }
 
 func (k *Key) Count() string {
@@ -25,10 +44,68 @@ func (k *Key) Index(field, value string) string {
     return fmt.Sprintf("%s:index:%s:%s", k.kind, field, value)
 }
 
+func (k *Key) Range(field string) string {
+    return fmt.Sprintf("%s:range:%s", k.kind, field)
+}
+
+func (k *Key) Owned(field string) string {
+    return fmt.Sprintf("%s:%s:%s", k.kind, k.id, field)
+}
+
+func (k *Key) Kind() string {
+    return k.kind
+}
+
+// Id returns the id of k as an integer, 0 if k has no id or a string id.
 func (k *Key) Id() int64 {
+    id, _ := strconv.ParseInt(k.id, 10, 64)
+    return id
+}
+
+// StringId returns the id of k, "" if k has no id.
+func (k *Key) StringId() string {
     return k.id
 }
 
+// NewKey returns the key of an entity with an integer id, 0 for a new
+// entity.
 func NewKey(kind string, id int64) *Key {
+    if id == 0 {
+        return &Key{kind, ""}
+    }
+
+    return &Key{kind, strconv.FormatInt(id, 10)}
+}
+
+// NewStringKey returns the key of an entity with a string id, "" for a new
+// entity.
+func NewStringKey(kind, id string) *Key {
     return &Key{kind, id}
 }
+
+// check returns an error if the id of k could collide with a key derived
+// from its kind.
+func (k *Key) check() error {
+    if strings.Contains(k.id, ":") || reservedIds[k.id] {
+        return newIdError(k.id)
+    }
+
+    return nil
+}
+
+// ParseKey parses the `kind:id` form of a key returned by Key.String.
+func ParseKey(s string) (*Key, error) {
+    i := strings.Index(s, ":")
+
+    if i <= 0 || i == len(s)-1 {
+        return nil, keyError
+    }
+
+    k := &Key{s[:i], s[i+1:]}
+
+    if e := k.check(); e != nil {
+        return nil, e
+    }
+
+    return k, nil
+}
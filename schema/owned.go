@@ -0,0 +1,199 @@
+package schema
+
+import (
+    "fmt"
+    "reflect"
+
+    "insmo.com/godis/exp"
+)
+
+// Composite fields tagged with one of these options are stored in a key
+// of their own, owned by the entity:
+//
+//      Tags   []string          `redis:",list"`
+//      Groups []string          `redis:",set"`
+//      Props  map[string]string `redis:",hash"`
+//
+// The key is Key.Owned(name), it is replaced on every Put of the field and
+// deleted with the entity.
+const (
+    ownedList = "list"
+    ownedSet  = "set"
+    ownedHash = "hash"
+)
+
+func newOptionError(field, option string) InternalError {
+    return InternalError(fmt.Sprintf("Option `%s` not supported on field `%s`", option, field))
+}
+
+// ownedField holds the values of an owned key to write.
+type ownedField struct {
+    *field
+
+    // args are the elements of a list or set, or the field value pairs of
+    // a hash
+    args []interface{}
+}
+
+// checkOwned returns an error if values of t can not be stored in an owned
+// key of typ.
+func checkOwned(name, typ string, t reflect.Type) error {
+    t = elemType(t)
+
+    switch typ {
+    case ownedList, ownedSet:
+        if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+            return newValueTypeError(name, t)
+        }
+
+        return checkType(name, t.Elem())
+    }
+
+    if t.Kind() != reflect.Map {
+        return newValueTypeError(name, t)
+    }
+
+    if e := checkType(name, t.Key()); e != nil {
+        return e
+    }
+
+    return checkType(name, t.Elem())
+}
+
+// encodeOwned returns the arguments writing v to the owned key of f.
+func (f *field) encodeOwned(v reflect.Value) ([]interface{}, error) {
+    v = reflect.Indirect(v)
+
+    if !v.IsValid() {
+        return nil, nil
+    }
+
+    var args []interface{}
+
+    if f.owned != ownedHash {
+        for i := 0; i < v.Len(); i++ {
+            s, ok, e := encodeValue(f.name, v.Index(i))
+
+            if e != nil {
+                return nil, e
+            }
+
+            if ok {
+                args = append(args, s)
+            }
+        }
+
+        return args, nil
+    }
+
+    iter := v.MapRange()
+
+    for iter.Next() {
+        mk, _, e := encodeValue(f.name, iter.Key())
+
+        if e != nil {
+            return nil, e
+        }
+
+        mv, ok, e := encodeValue(f.name, iter.Value())
+
+        if e != nil {
+            return nil, e
+        }
+
+        if ok {
+            args = append(args, mk, mv)
+        }
+    }
+
+    return args, nil
+}
+
+// writeOwned returns the commands replacing the owned key of o.
+func writeOwned(k *Key, o *ownedField) [][]interface{} {
+    key := k.Owned(o.name)
+    cmds := [][]interface{}{{"DEL", key}}
+
+    if len(o.args) == 0 {
+        return cmds
+    }
+
+    cmd := "RPUSH"
+
+    switch o.owned {
+    case ownedSet:
+        cmd = "SADD"
+    case ownedHash:
+        cmd = "HMSET"
+    }
+
+    return append(cmds, append([]interface{}{cmd, key}, o.args...))
+}
+
+// readOwned sends the command reading the owned key of f.
+func readOwned(ac *redis.AsyncClient, k *Key, f *field) error {
+    key := k.Owned(f.name)
+
+    switch f.owned {
+    case ownedSet:
+        return ac.Call("SMEMBERS", key)
+    case ownedHash:
+        return ac.Call("HGETALL", key)
+    }
+
+    return ac.Call("LRANGE", key, 0, -1)
+}
+
+// decodeOwned sets v to the contents of an owned key read by readOwned. An
+// empty or missing key sets v to its zero value.
+func (f *field) decodeOwned(v reflect.Value, reply *redis.Reply) error {
+    if reply.Len() == 0 {
+        v.Set(reflect.Zero(v.Type()))
+        return nil
+    }
+
+    if v.Kind() == reflect.Ptr {
+        v.Set(reflect.New(v.Type().Elem()))
+        v = v.Elem()
+    }
+
+    t := v.Type()
+
+    if f.owned != ownedHash {
+        elems := reply.StringArray()
+
+        if t.Kind() == reflect.Slice {
+            v.Set(reflect.MakeSlice(t, len(elems), len(elems)))
+        } else {
+            v.Set(reflect.Zero(t))
+        }
+
+        for i := 0; i < len(elems) && i < v.Len(); i++ {
+            if e := decodeValue(f.name, v.Index(i), elems[i]); e != nil {
+                return e
+            }
+        }
+
+        return nil
+    }
+
+    m := reflect.MakeMap(t)
+
+    for mk, mv := range reply.Hash() {
+        kv := reflect.New(t.Key()).Elem()
+        vv := reflect.New(t.Elem()).Elem()
+
+        if e := decodeValue(f.name, kv, mk); e != nil {
+            return e
+        }
+
+        if e := decodeValue(f.name, vv, mv.String()); e != nil {
+            return e
+        }
+
+        m.SetMapIndex(kv, vv)
+    }
+
+    v.Set(m)
+    return nil
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+    "reflect"
+    "sort"
+    "testing"
+)
+
+type Address struct {
+    Street string
+    City   string `redis:"city,index"`
+}
+
+type Customer struct {
+    Id      int64
+    Home    Address
+    Work    *Address `redis:"work"`
+    Billing Address  `redis:",json"`
+    Labels  []string
+    Scores  map[string]int
+    Tags    []string          `redis:",list"`
+    Groups  []int             `redis:",set"`
+    Props   map[string]string `redis:",hash"`
+}
+
+func TestComposite(t *testing.T) {
+    db.Call("FLUSHDB")
+    in := &Customer{
+        Id:      1,
+        Home:    Address{"Main St 1", "Stockholm"},
+        Work:    &Address{"Side St 2", "Oslo"},
+        Billing: Address{"Box 3", "Bergen"},
+        Labels:  []string{"a", "b"},
+        Scores:  map[string]int{"x": 1},
+        Tags:    []string{"new", "vip", "new"},
+        Groups:  []int{3, 1, 2},
+        Props:   map[string]string{"color": "red"},
+    }
+    k := NewKey("customer", 1)
+
+    if _, e := Put(db, k, in); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    r, _ := db.Call("HGETALL", k.String())
+    h := r.StringMap()
+
+    expected := map[string]string{
+        "Id":          "1",
+        "Home.Street": "Main St 1",
+        "Home.city":   "Stockholm",
+        "work.Street": "Side St 2",
+        "work.city":   "Oslo",
+        "Billing":     `{"Street":"Box 3","City":"Bergen"}`,
+        "Labels":      `["a","b"]`,
+        "Scores":      `{"x":1}`,
+    }
+
+    if !reflect.DeepEqual(h, expected) {
+        t.Errorf("expected %q got %q", expected, h)
+    }
+
+    if r, _ := db.Call("LRANGE", k.Owned("Tags"), 0, -1); !reflect.DeepEqual(r.StringArray(), []string{"new", "vip", "new"}) {
+        t.Errorf("unexpected list %q", r.StringArray())
+    }
+
+    var keys []*Key
+
+    if keys, _ = FindAll(db, "customer", "Home.city", "Stockholm", &[]Customer{}); len(keys) != 1 {
+        t.Errorf("expected an index on a nested field got %v", keys)
+    }
+
+    var out Customer
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    sort.Ints(out.Groups)
+    in.Groups = []int{1, 2, 3}
+
+    if !reflect.DeepEqual(in, &out) {
+        t.Errorf("expected %+v got %+v", in, out)
+    }
+
+    // nil composites are absent, owned keys are replaced
+    if _, e := Put(db, k, &Customer{Id: 1, Tags: []string{"old"}}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    out = Customer{Work: &Address{}, Groups: []int{9}}
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if out.Work != nil || out.Groups != nil || out.Props != nil || !reflect.DeepEqual(out.Tags, []string{"old"}) {
+        t.Errorf("unexpected struct %+v", out)
+    }
+
+    if e := Delete(db, k, &Customer{}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if r, _ := db.Call("KEYS", "customer:1*"); r.Len() != 0 {
+        t.Errorf("expected owned keys to be deleted got %q", r.StringArray())
+    }
+}
+
+func TestCompositeMask(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("customer", 1)
+
+    if _, e := Put(db, k, &Customer{Id: 1, Tags: []string{"a"}, Props: map[string]string{"b": "c"}}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if _, e := Put(db, k, &Customer{Id: 1, Tags: []string{"d"}}, "Tags"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    var out Customer
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if !reflect.DeepEqual(out.Tags, []string{"d"}) || out.Props["b"] != "c" {
+        t.Errorf("expected a partial update got %+v", out)
+    }
+
+    type bad struct {
+        Id   int64
+        Tags []string `redis:",list,index"`
+    }
+
+    if _, e := Put(db, NewKey("bad", 1), &bad{}); e != newOptionError("Tags", "index") {
+        t.Errorf("expected an option error got %v", e)
+    }
+
+    type badHash struct {
+        Id    int64
+        Props []string `redis:",hash"`
+    }
+
+    if _, e := Put(db, NewKey("bad", 1), &badHash{}); e != newValueTypeError("Props", reflect.TypeOf([]string{})) {
+        t.Errorf("expected a value type error got %v", e)
+    }
+}
@@ -0,0 +1,378 @@
+package schema
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+
+    "code.google.com/p/tcgl/monitoring"
+    "insmo.com/godis/exp"
+)
+
+// tempTTL is the time to live in seconds of the temporary keys of a query,
+// they are deleted by the query itself unless the client fails.
+const tempTTL = 60
+
+var emptyQueryError = InternalError("Query needs a clause or a sort field")
+
+// Query selects the entities of a kind matching all of its clauses.
+// Equality clauses use the index sets of `index` fields, range clauses and
+// sorting the sorted sets of `range` fields.
+//
+//      var users []User
+//      keys, e := schema.NewQuery("user").
+//          Where("status", "active").
+//          WhereIn("country", "SE", "NO").
+//          Between("age", 18, 30).
+//          SortBy("created").
+//          Desc().
+//          Limit(10).
+//          Find(db, &users)
+//
+// Equality clauses are intersected with SINTER, the values of WhereIn are
+// united with SUNIONSTORE first. Range clauses and sorting intersect the
+// sets with copies of the range indexes trimmed to the range, stored in
+// temporary keys. A query runs in a single MULTI/EXEC and removes its
+// temporary keys. Without a sort field entities are ordered by id.
+type Query struct {
+    kind   string
+    equal  []*equalClause
+    ranges []*rangeClause
+    sortBy string
+    desc   bool
+    offset int
+    limit  int
+    err    error
+}
+
+type equalClause struct {
+    field string
+
+    // values are encoded as stored by Put
+    values []string
+}
+
+type rangeClause struct {
+    field    string
+    min, max string
+}
+
+// NewQuery returns a query over the entities of kind.
+func NewQuery(kind string) *Query {
+    return &Query{kind: kind, limit: -1}
+}
+
+// Where selects entities whose indexed field has value.
+func (q *Query) Where(field string, value interface{}) *Query {
+    return q.WhereIn(field, value)
+}
+
+// WhereIn selects entities whose indexed field has any of values.
+func (q *Query) WhereIn(field string, values ...interface{}) *Query {
+    c := &equalClause{field, make([]string, len(values))}
+
+    for i, v := range values {
+        s, e := encodeLookup(field, v)
+
+        if e != nil {
+            q.err = e
+        }
+
+        c.values[i] = s
+    }
+
+    q.equal = append(q.equal, c)
+    return q
+}
+
+func (q *Query) rangeOf(field string) *rangeClause {
+    for _, r := range q.ranges {
+        if r.field == field {
+            return r
+        }
+    }
+
+    r := &rangeClause{field, "-inf", "+inf"}
+    q.ranges = append(q.ranges, r)
+    return r
+}
+
+func (q *Query) bound(v interface{}, exclusive bool) string {
+    s, e := formatBound(v, exclusive)
+
+    if e != nil {
+        q.err = e
+    }
+
+    return s
+}
+
+// Between selects entities whose range field is from min to max,
+// inclusive.
+func (q *Query) Between(field string, min, max interface{}) *Query {
+    r := q.rangeOf(field)
+    r.min = q.bound(min, false)
+    r.max = q.bound(max, false)
+    return q
+}
+
+// GreaterThan selects entities whose range field is greater than v.
+func (q *Query) GreaterThan(field string, v interface{}) *Query {
+    q.rangeOf(field).min = q.bound(v, true)
+    return q
+}
+
+// LessThan selects entities whose range field is less than v.
+func (q *Query) LessThan(field string, v interface{}) *Query {
+    q.rangeOf(field).max = q.bound(v, true)
+    return q
+}
+
+// SortBy orders the entities by a range field.
+func (q *Query) SortBy(field string) *Query {
+    q.sortBy = field
+    return q
+}
+
+// Desc reverses the order of the entities.
+func (q *Query) Desc() *Query {
+    q.desc = true
+    return q
+}
+
+// Offset skips the first n entities.
+func (q *Query) Offset(n int) *Query {
+    q.offset = n
+    return q
+}
+
+// Limit returns at most n entities.
+func (q *Query) Limit(n int) *Query {
+    q.limit = n
+    return q
+}
+
+// tempKey returns a new random key below kind. It can not name an entity
+// or its owned keys, an id can not be tmp or contain a colon.
+func tempKey(kind string) string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return kind + ":tmp:" + hex.EncodeToString(b)
+}
+
+// trim returns the ZREMRANGEBYSCORE commands removing the scores of key
+// outside of r.
+func (r *rangeClause) trim(key string) [][]interface{} {
+    var cmds [][]interface{}
+
+    if r.min != "-inf" {
+        if r.min[0] == '(' {
+            cmds = append(cmds, []interface{}{"ZREMRANGEBYSCORE", key, "-inf", r.min[1:]})
+        } else {
+            cmds = append(cmds, []interface{}{"ZREMRANGEBYSCORE", key, "-inf", "(" + r.min})
+        }
+    }
+
+    if r.max != "+inf" {
+        if r.max[0] == '(' {
+            cmds = append(cmds, []interface{}{"ZREMRANGEBYSCORE", key, r.max[1:], "+inf"})
+        } else {
+            cmds = append(cmds, []interface{}{"ZREMRANGEBYSCORE", key, "(" + r.max, "+inf"})
+        }
+    }
+
+    return cmds
+}
+
+// plan returns the commands of the query, the index of the command whose
+// reply holds the ids and the temporary keys.
+func (q *Query) plan() (cmds [][]interface{}, fetch int, tmp []interface{}) {
+    k := NewKey(q.kind, 0)
+
+    temp := func() string {
+        t := tempKey(q.kind)
+        tmp = append(tmp, t)
+        return t
+    }
+
+    var sets []interface{}
+
+    for _, c := range q.equal {
+        if len(c.values) == 1 {
+            sets = append(sets, k.Index(c.field, c.values[0]))
+            continue
+        }
+
+        t := temp()
+        union := []interface{}{"SUNIONSTORE", t}
+
+        for _, v := range c.values {
+            union = append(union, k.Index(c.field, v))
+        }
+
+        cmds = append(cmds, union, []interface{}{"EXPIRE", t, tempTTL})
+        sets = append(sets, t)
+    }
+
+    if len(q.ranges) == 0 && q.sortBy == "" {
+        cmds = append(cmds, append([]interface{}{"SINTER"}, sets...))
+        return cmds, len(cmds) - 1, tmp
+    }
+
+    // the score of the result is the score of the sort field, all other
+    // inputs are weighted 0
+    var keys, weights []interface{}
+
+    if q.sortBy != "" {
+        keys = append(keys, k.Range(q.sortBy))
+        weights = append(weights, 1)
+    }
+
+    for _, r := range q.ranges {
+        t := temp()
+        cmds = append(cmds, []interface{}{"ZUNIONSTORE", t, 1, k.Range(r.field)}, []interface{}{"EXPIRE", t, tempTTL})
+        cmds = append(cmds, r.trim(t)...)
+        keys = append(keys, t)
+        weights = append(weights, 0)
+    }
+
+    for _, s := range sets {
+        keys = append(keys, s)
+        weights = append(weights, 0)
+    }
+
+    out := temp()
+    inter := append([]interface{}{"ZINTERSTORE", out, len(keys)}, keys...)
+    inter = append(append(inter, "WEIGHTS"), weights...)
+    cmds = append(cmds, inter, []interface{}{"EXPIRE", out, tempTTL})
+
+    if q.sortBy == "" {
+        cmds = append(cmds, []interface{}{"ZRANGE", out, 0, -1})
+        return cmds, len(cmds) - 1, tmp
+    }
+
+    stop := -1
+
+    if q.limit >= 0 {
+        stop = q.offset + q.limit - 1
+    }
+
+    if q.desc {
+        cmds = append(cmds, []interface{}{"ZREVRANGE", out, q.offset, stop})
+    } else {
+        cmds = append(cmds, []interface{}{"ZRANGE", out, q.offset, stop})
+    }
+
+    return cmds, len(cmds) - 1, tmp
+}
+
+func (q *Query) ids(db *redis.Client) ([]string, error) {
+    if q.err != nil {
+        return nil, q.err
+    }
+
+    if len(q.equal) == 0 && len(q.ranges) == 0 && q.sortBy == "" {
+        return nil, emptyQueryError
+    }
+
+    if q.limit == 0 {
+        return nil, nil
+    }
+
+    cmds, fetch, tmp := q.plan()
+
+    if len(tmp) > 0 {
+        cmds = append(cmds, append([]interface{}{"DEL"}, tmp...))
+    }
+
+    ac := db.AsyncClient()
+    defer ac.Close()
+    ac.Call("MULTI")
+
+    for _, cmd := range cmds {
+        ac.Call(cmd...)
+    }
+
+    ac.Call("EXEC")
+    replies, e := ac.ReadAll()
+
+    if e != nil {
+        return nil, e
+    }
+
+    exec := replies[len(replies)-1]
+
+    if fetch >= exec.Len() {
+        return nil, redis.ErrNilMultiBulk
+    }
+
+    if e = exec.Elems[fetch].Err; e != nil {
+        return nil, e
+    }
+
+    ids := exec.Elems[fetch].StringArray()
+
+    if q.sortBy != "" {
+        return ids, nil
+    }
+
+    sortIds(ids, q.desc)
+
+    if q.offset >= len(ids) {
+        return nil, nil
+    }
+
+    ids = ids[q.offset:]
+
+    if q.limit >= 0 && q.limit < len(ids) {
+        ids = ids[:q.limit]
+    }
+
+    return ids, nil
+}
+
+// Keys returns the keys of the selected entities.
+func (q *Query) Keys(db *redis.Client) ([]*Key, error) {
+    ids, e := q.ids(db)
+
+    if e != nil {
+        return nil, e
+    }
+
+    keys := make([]*Key, len(ids))
+
+    for i, id := range ids {
+        keys[i] = NewStringKey(q.kind, id)
+    }
+
+    return keys, nil
+}
+
+// Find sets dst, a pointer to a slice of structs or of pointers to
+// structs, to the selected entities and returns their keys.
+func (q *Query) Find(db *redis.Client, dst interface{}) ([]*Key, error) {
+    mon := monitoring.BeginMeasuring("database:query")
+    defer mon.EndMeasuring()
+    slice, e := sliceOf(dst)
+
+    if e != nil {
+        return nil, e
+    }
+
+    ids, e := q.ids(db)
+
+    if e != nil {
+        return nil, e
+    }
+
+    keys, hashes, e := load(db, q.kind, ids)
+
+    if e != nil {
+        return nil, e
+    }
+
+    if e = fill(db, slice, keys, hashes); e != nil {
+        return nil, e
+    }
+
+    return keys, nil
+}
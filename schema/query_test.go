@@ -0,0 +1,99 @@
+package schema
+
+import (
+    "testing"
+)
+
+type Item struct {
+    Id    int64
+    Color string `redis:",index"`
+    Size  string `redis:",index"`
+    Price int    `redis:",range"`
+    Stock int    `redis:",range"`
+}
+
+func putItems(t *testing.T) {
+    putAll(t, "item",
+        &Item{1, "red", "S", 30, 5},
+        &Item{2, "blue", "M", 10, 0},
+        &Item{3, "red", "M", 50, 2},
+        &Item{4, "green", "L", 20, 7},
+        &Item{5, "red", "L", 40, 1},
+        &Item{6, "blue", "S", 60, 9},
+    )
+}
+
+func TestQuery(t *testing.T) {
+    putItems(t)
+
+    tests := []struct {
+        q   *Query
+        ids []int64
+    }{
+        {NewQuery("item").Where("Color", "red"), []int64{1, 3, 5}},
+        {NewQuery("item").Where("Color", "red").Where("Size", "M"), []int64{3}},
+        {NewQuery("item").Where("Color", "red").Where("Size", "XL"), []int64{}},
+        {NewQuery("item").WhereIn("Color", "blue", "green"), []int64{2, 4, 6}},
+        {NewQuery("item").WhereIn("Color", "blue", "green").Where("Size", "S"), []int64{6}},
+        {NewQuery("item").Where("Color", "red").Desc().Offset(1).Limit(1), []int64{3}},
+        {NewQuery("item").Between("Price", 20, 40), []int64{1, 4, 5}},
+        {NewQuery("item").Where("Color", "red").GreaterThan("Price", 30), []int64{3, 5}},
+        {NewQuery("item").Where("Color", "red").LessThan("Price", 50).GreaterThan("Price", 30), []int64{5}},
+        {NewQuery("item").GreaterThan("Price", 10).LessThan("Stock", 5), []int64{3, 5}},
+        {NewQuery("item").SortBy("Price"), []int64{2, 4, 1, 5, 3, 6}},
+        {NewQuery("item").SortBy("Stock").Desc().Limit(3), []int64{6, 4, 1}},
+        {NewQuery("item").WhereIn("Size", "S", "M").SortBy("Price").Offset(1).Limit(2), []int64{1, 3}},
+        {NewQuery("item").Where("Color", "red").Between("Stock", 1, 5).SortBy("Price").Desc(), []int64{3, 5, 1}},
+        {NewQuery("item").SortBy("Price").Limit(0), []int64{}},
+    }
+
+    for i, test := range tests {
+        keys, e := test.q.Keys(db)
+
+        if e != nil {
+            t.Fatal(e.Error())
+        }
+
+        if got := ids(keys); len(got) != len(test.ids) {
+            t.Errorf("%d: expected %v got %v", i, test.ids, got)
+        } else {
+            for j := range got {
+                if got[j] != test.ids[j] {
+                    t.Errorf("%d: expected %v got %v", i, test.ids, got)
+                    break
+                }
+            }
+        }
+    }
+
+    // temporary keys are removed by the query
+    if reply, _ := db.Call("KEYS", "item:tmp:*"); reply.Len() != 0 {
+        t.Errorf("expected no temporary keys got %d", reply.Len())
+    }
+
+    if _, e := NewQuery("item").Keys(db); e != emptyQueryError {
+        t.Errorf("expected emptyQueryError got %v", e)
+    }
+
+    if _, e := NewQuery("item").Between("Price", "a", "b").Keys(db); e != rangeTypeError {
+        t.Errorf("expected rangeTypeError got %v", e)
+    }
+}
+
+func TestQueryFind(t *testing.T) {
+    putItems(t)
+    var items []*Item
+    keys, e := NewQuery("item").Where("Size", "L").SortBy("Price").Desc().Find(db, &items)
+
+    if e != nil || len(keys) != 2 || len(items) != 2 {
+        t.Fatalf("expected 2 items got %v, %v", items, e)
+    }
+
+    if it := items[0]; it.Id != 5 || it.Color != "red" || it.Price != 40 || it.Stock != 1 {
+        t.Errorf("expected item 5 got %+v", it)
+    }
+
+    if keys[1].String() != "item:4" {
+        t.Errorf("expected item:4 got %v", keys[1])
+    }
+}
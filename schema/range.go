@@ -0,0 +1,198 @@
+package schema
+
+import (
+    "reflect"
+    "strconv"
+    "time"
+
+    "code.google.com/p/tcgl/monitoring"
+    "insmo.com/godis/exp"
+)
+
+var (
+    timeType       = reflect.TypeOf(time.Time{})
+    rangeTypeError = InternalError("Invalid range type, expected a number or time.Time")
+)
+
+// rangeScore returns the score of v in a range index. Times are scored as
+// seconds since the Unix epoch, with a fraction for sub-second precision.
+func rangeScore(v reflect.Value) (float64, error) {
+    switch v.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(v.Int()), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return float64(v.Uint()), nil
+    case reflect.Float32, reflect.Float64:
+        return v.Float(), nil
+    }
+
+    if v.Type() == timeType {
+        t := v.Interface().(time.Time)
+        return float64(t.Unix()) + float64(t.Nanosecond())/1e9, nil
+    }
+
+    return 0, rangeTypeError
+}
+
+// RangeQuery selects the entities of a kind by the range index of a field,
+// which is maintained for fields tagged with the `range` option.
+//
+//      var events []Event
+//      keys, e := schema.NewRangeQuery("event", "created").
+//          GreaterThan(time.Now().Add(-time.Hour)).
+//          Desc().
+//          Limit(10).
+//          Find(db, &events)
+//
+// Without bounds all entities in the index are selected.
+type RangeQuery struct {
+    kind     string
+    field    string
+    min, max string
+    offset   int
+    limit    int
+    desc     bool
+    err      error
+}
+
+// NewRangeQuery returns a query over the range index of field.
+func NewRangeQuery(kind, field string) *RangeQuery {
+    return &RangeQuery{kind: kind, field: field, min: "-inf", max: "+inf", limit: -1}
+}
+
+// formatBound formats v as a ZRANGEBYSCORE bound.
+func formatBound(v interface{}, exclusive bool) (string, error) {
+    f, e := rangeScore(reflect.ValueOf(v))
+
+    if e != nil {
+        return "", e
+    }
+
+    s := strconv.FormatFloat(f, 'f', -1, 64)
+
+    if exclusive {
+        s = "(" + s
+    }
+
+    return s, nil
+}
+
+func (q *RangeQuery) bound(v interface{}, exclusive bool) string {
+    s, e := formatBound(v, exclusive)
+
+    if e != nil {
+        q.err = e
+    }
+
+    return s
+}
+
+// Between selects values from min to max, inclusive.
+func (q *RangeQuery) Between(min, max interface{}) *RangeQuery {
+    q.min = q.bound(min, false)
+    q.max = q.bound(max, false)
+    return q
+}
+
+// GreaterThan selects values greater than v.
+func (q *RangeQuery) GreaterThan(v interface{}) *RangeQuery {
+    q.min = q.bound(v, true)
+    return q
+}
+
+// LessThan selects values less than v.
+func (q *RangeQuery) LessThan(v interface{}) *RangeQuery {
+    q.max = q.bound(v, true)
+    return q
+}
+
+// Offset skips the first n entities.
+func (q *RangeQuery) Offset(n int) *RangeQuery {
+    q.offset = n
+    return q
+}
+
+// Limit returns at most n entities.
+func (q *RangeQuery) Limit(n int) *RangeQuery {
+    q.limit = n
+    return q
+}
+
+// Desc orders the entities from the highest value to the lowest.
+func (q *RangeQuery) Desc() *RangeQuery {
+    q.desc = true
+    return q
+}
+
+func (q *RangeQuery) ids(db *redis.Client) ([]string, error) {
+    if q.err != nil {
+        return nil, q.err
+    }
+
+    cmd, min, max := "ZRANGEBYSCORE", q.min, q.max
+
+    if q.desc {
+        cmd, min, max = "ZREVRANGEBYSCORE", max, min
+    }
+
+    args := []interface{}{cmd, NewKey(q.kind, 0).Range(q.field), min, max}
+
+    if q.offset > 0 || q.limit >= 0 {
+        args = append(args, "LIMIT", q.offset, q.limit)
+    }
+
+    reply, e := db.Call(args...)
+
+    if e != nil {
+        return nil, e
+    }
+
+    return reply.StringArray(), nil
+}
+
+// Keys returns the keys of the selected entities.
+func (q *RangeQuery) Keys(db *redis.Client) ([]*Key, error) {
+    ids, e := q.ids(db)
+
+    if e != nil {
+        return nil, e
+    }
+
+    keys := make([]*Key, len(ids))
+
+    for i, id := range ids {
+        keys[i] = NewStringKey(q.kind, id)
+    }
+
+    return keys, nil
+}
+
+// Find sets dst, a pointer to a slice of structs or of pointers to
+// structs, to the selected entities and returns their keys.
+func (q *RangeQuery) Find(db *redis.Client, dst interface{}) ([]*Key, error) {
+    mon := monitoring.BeginMeasuring("database:range")
+    defer mon.EndMeasuring()
+    slice, e := sliceOf(dst)
+
+    if e != nil {
+        return nil, e
+    }
+
+    ids, e := q.ids(db)
+
+    if e != nil {
+        return nil, e
+    }
+
+    keys, hashes, e := load(db, q.kind, ids)
+
+    if e != nil {
+        return nil, e
+    }
+
+    if e = fill(db, slice, keys, hashes); e != nil {
+        return nil, e
+    }
+
+    return keys, nil
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+    "testing"
+    "time"
+)
+
+type Event struct {
+    Id      int64
+    Score   int       `redis:",range"`
+    Rating  float64   `redis:",range"`
+    Created time.Time `redis:",range"`
+}
+
+var epoch = time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func putEvents(t *testing.T) {
+    var events []interface{}
+
+    for i := int64(1); i <= 5; i++ {
+        events = append(events, &Event{i, int(10 * i), 1 / float64(i), epoch.Add(time.Duration(i) * time.Hour)})
+    }
+
+    putAll(t, "event", events...)
+}
+
+func ids(keys []*Key) []int64 {
+    out := make([]int64, len(keys))
+
+    for i, k := range keys {
+        out[i] = k.Id()
+    }
+
+    return out
+}
+
+func TestRangeQuery(t *testing.T) {
+    putEvents(t)
+
+    tests := []struct {
+        q   *RangeQuery
+        ids []int64
+    }{
+        {NewRangeQuery("event", "Score"), []int64{1, 2, 3, 4, 5}},
+        {NewRangeQuery("event", "Score").Between(20, 40), []int64{2, 3, 4}},
+        {NewRangeQuery("event", "Score").GreaterThan(20), []int64{3, 4, 5}},
+        {NewRangeQuery("event", "Score").LessThan(20), []int64{1}},
+        {NewRangeQuery("event", "Score").Desc().Offset(1).Limit(2), []int64{4, 3}},
+        {NewRangeQuery("event", "Score").GreaterThan(10).LessThan(50).Desc(), []int64{4, 3, 2}},
+        {NewRangeQuery("event", "Rating").LessThan(0.5), []int64{5, 4, 3}},
+        {NewRangeQuery("event", "Created").Between(epoch, epoch.Add(2*time.Hour)), []int64{1, 2}},
+        {NewRangeQuery("event", "Score").Between(60, 70), []int64{}},
+    }
+
+    for i, test := range tests {
+        keys, e := test.q.Keys(db)
+
+        if e != nil {
+            t.Fatal(e.Error())
+        }
+
+        if got := ids(keys); len(got) != len(test.ids) {
+            t.Errorf("%d: expected %v got %v", i, test.ids, got)
+        } else {
+            for j := range got {
+                if got[j] != test.ids[j] {
+                    t.Errorf("%d: expected %v got %v", i, test.ids, got)
+                    break
+                }
+            }
+        }
+    }
+
+    if _, e := NewRangeQuery("event", "Score").GreaterThan("x").Keys(db); e != rangeTypeError {
+        t.Errorf("expected rangeTypeError got %v", e)
+    }
+}
+
+func TestRangeFind(t *testing.T) {
+    putEvents(t)
+    var events []*Event
+    keys, e := NewRangeQuery("event", "Created").GreaterThan(epoch.Add(3*time.Hour)).Find(db, &events)
+
+    if e != nil || len(keys) != 2 || len(events) != 2 {
+        t.Fatalf("expected 2 events got %v, %v", events, e)
+    }
+
+    if ev := events[1]; ev.Id != 5 || ev.Score != 50 || ev.Rating != 0.2 || !ev.Created.Equal(epoch.Add(5*time.Hour)) {
+        t.Errorf("expected event 5 got %+v", ev)
+    }
+}
+
+func TestRangeUpdate(t *testing.T) {
+    putEvents(t)
+    k := NewKey("event", 1)
+
+    if _, e := Put(db, k, &Event{1, 100, 1, epoch}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if keys, _ := NewRangeQuery("event", "Score").GreaterThan(50).Keys(db); len(keys) != 1 || keys[0].Id() != 1 {
+        t.Errorf("expected the new score of event 1 got %v", keys)
+    }
+
+    if e := Delete(db, k, &Event{}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if keys, _ := NewRangeQuery("event", "Score").Keys(db); len(keys) != 4 {
+        t.Errorf("expected event 1 to be removed got %v", keys)
+    }
+
+    type bad struct {
+        Id   int64
+        Name string `redis:",range"`
+    }
+
+    if _, e := Put(db, NewKey("bad", 1), &bad{1, "x"}); e != rangeTypeError {
+        t.Errorf("expected rangeTypeError got %v", e)
+    }
+}
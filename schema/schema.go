@@ -24,10 +24,14 @@ func newUniqueError(field string, value interface{}) UserError {
     return UserError(fmt.Sprintf("Expected unique `%s`:`%v`", field, value))
 }
 
+func newFieldError(field string) InternalError {
+    return InternalError(fmt.Sprintf("Unknown field `%s`", field))
+}
+
 var (
     nilError     = UserError("Key requested returned a nil reply")
     typeError    = InternalError("Invalid type, expected pointer to struct")
-    idFieldError = InternalError("Expected Id field of type int64 on struct")
+    idFieldError = InternalError("Expected an id field of integer or string type on struct")
 )
 
 func IsUserError(e error) bool {
@@ -41,7 +45,7 @@ func IsInternalError(e error) bool {
 }
 
 /* logic:
-   input: key, *struct
+   input: key, *struct, optional field mask
 
    a) Check if key has Id; yes/no
        no) INCR k:count
@@ -49,80 +53,231 @@ func IsInternalError(e error) bool {
 
    b) Parse struct; returns [field, value, field, value, ...], [hashField, ...].
       the hashField slice contains any optional properties which should be applied
-      to before or after setting the struct in Redis (uniquity, index).
-
-   c) Check unique fields and set unique fields
-
-   d) Add the actual data from struct to a redis hash
-
-   e) Add optional indexes
-
-   f) if c, d, e did raise an error we need to cleanup the things 
-      we changed in the database.
+      to before or after setting the struct in Redis (uniquity, index). With
+      a field mask only the masked fields are kept.
+
+   c) WATCH and read the stored hash. For every unique and index field
+      whose value changed, the key of the stored value is released and
+      the key of the new value claimed. Index keys are sets of ids, index
+      keys still holding a single id as a string are WATCHed and converted
+      to sets.
+
+   d) WATCH the unique keys and check that they are free or already ours
+
+   e) In one MULTI/EXEC: release the old keys, claim the new unique keys,
+      add the actual data from the struct to a redis hash and add optional
+      indexes and range scores
+
+   f) if a watched key changed before EXEC nothing was written, retry c
+      to e. A unique error in d also leaves the database untouched, as
+      does a written key of the wrong type, checked before MULTI. Only
+      a command failing inside EXEC for another reason, e.g. out of
+      memory, leaves the other commands applied.
 */
-func Put(db *redis.Client, k *Key, s interface{}) (*Key, error) {
+
+// Put stores s as the hash of k and maintains its unique and index keys.
+// A key without id creates a new entity. Putting an existing entity
+// updates it, keys of changed unique and index values are moved.
+//
+// fields is an optional mask of hash field names, only these fields are
+// written. A masked Put updates an existing entity, it returns a nil-entity
+// error if k does not exist.
+//
+// Index keys used to be strings holding the id of the last entity put. Put
+// and Delete convert such a key to a set of that id before they change it,
+// Find reads it as is. A Query fails with a WRONGTYPE error on an index key
+// which was not converted yet.
+func Put(db *redis.Client, k *Key, s interface{}, fields ...string) (*Key, error) {
     mon := monitoring.BeginMeasuring("database:put")
     defer mon.EndMeasuring()
     prep := new(prepare)
     prep.key = k
 
+    if len(fields) > 0 && k.id == "" {
+        return nil, nilError
+    }
+
     e := setId(db, s, prep)
 
     if e != nil {
         return nil, e
     }
 
+    if e = k.check(); e != nil {
+        return nil, e
+    }
+
     e = parseStruct(db, s, prep)
 
     if e != nil {
         return nil, e
     }
 
-    for _, o := range prep.unique {
-        uk := k.Unique(o.name, fmt.Sprintf("%v", *o.value))
-        var reply *redis.Reply
-        // TODO: Watch key
-        reply, e = db.Call("GET", uk)
+    if len(fields) > 0 {
+        if e = prep.mask(fields); e != nil {
+            return nil, e
+        }
+    }
+
+    e = transaction(db, func(ac *redis.AsyncClient) ([][]interface{}, error) {
+        if _, e := call(ac, "WATCH", k.String()); e != nil {
+            return nil, e
+        }
+
+        reply, e := call(ac, "HGETALL", k.String())
 
         if e != nil {
-            goto Error
+            return nil, e
         }
 
-        if !reply.Nil() && reply.Elem.Int64() != k.Id() {
-            e = newUniqueError(o.name, *o.value)
-            goto Error
+        stored := reply.Hash()
+
+        if prep.masked && len(stored) == 0 {
+            return nil, nilError
         }
 
-        reply, e = db.Call("SET", uk, k.Id())
+        // unique keys are checked before they are claimed, released unique
+        // keys are only deleted while they refer to k. Index keys are sets
+        // of ids, k is added to or removed from them. The keys of absent
+        // fields are released.
+        var claimed []*hashField
+        var unique, index, released, unindexed []interface{}
 
-        if e != nil {
-            goto Error
+        for _, o := range prep.unique {
+            old, ok := stored[o.name]
+
+            if o.value == nil {
+                if ok {
+                    released = append(released, k.Unique(o.name, old.String()))
+                }
+
+                continue
+            }
+
+            v := *o.value
+            claimed = append(claimed, o)
+            unique = append(unique, k.Unique(o.name, v))
+
+            if ok && old.String() != v {
+                released = append(released, k.Unique(o.name, old.String()))
+            }
         }
 
-        prep.dirty = append(prep.dirty, uk)
-    }
+        for _, o := range prep.index {
+            old, ok := stored[o.name]
 
-    _, e = db.Call(append([]interface{}{"HMSET", k.String()}, prep.args...)...)
+            if o.value == nil {
+                if ok {
+                    unindexed = append(unindexed, k.Index(o.name, old.String()))
+                }
 
-    for _, o := range prep.index {
-        ik := fmt.Sprintf("%v", *o.value)
-        _, e = db.Call("SET", k.Index(o.name, ik), k.Id())
+                continue
+            }
+
+            v := *o.value
+            index = append(index, k.Index(o.name, v))
+
+            if ok && old.String() != v {
+                unindexed = append(unindexed, k.Index(o.name, old.String()))
+            }
+        }
+
+        cmds, e := convertIndexes(ac, append(append([]interface{}{}, index...), unindexed...))
 
         if e != nil {
-            goto Error
+            return nil, e
+        }
+
+        for _, ik := range unindexed {
+            cmds = append(cmds, []interface{}{"SREM", ik, k.id})
+        }
+
+        check := append(append([]interface{}{}, unique...), released...)
+
+        if len(check) > 0 {
+            if _, e = call(ac, append([]interface{}{"WATCH"}, check...)...); e != nil {
+                return nil, e
+            }
+
+            reply, e = call(ac, append([]interface{}{"MGET"}, check...)...)
+
+            if e != nil {
+                return nil, e
+            }
+
+            del := []interface{}{"DEL"}
+
+            for i, r := range reply.Elems {
+                owned := !r.Nil() && r.Elem.String() == k.id
+
+                if i < len(unique) && !r.Nil() && !owned {
+                    o := claimed[i]
+                    return nil, newUniqueError(o.name, *o.value)
+                }
+
+                if i >= len(unique) && owned {
+                    del = append(del, check[i])
+                }
+            }
+
+            if len(del) > 1 {
+                cmds = append(cmds, del)
+            }
+        }
+
+        for _, uk := range unique {
+            cmds = append(cmds, []interface{}{"SET", uk, k.id})
+        }
+
+        hdel := []interface{}{"HDEL", k.String()}
+
+        for _, name := range prep.absent {
+            if _, ok := stored[name]; ok {
+                hdel = append(hdel, name)
+            }
+        }
+
+        if len(hdel) > 2 {
+            cmds = append(cmds, hdel)
         }
 
-        prep.dirty = append(prep.dirty, ik)
+        if len(prep.args) > 0 {
+            cmds = append(cmds, append([]interface{}{"HMSET", k.String()}, prep.args...))
+        }
+
+        for _, ik := range index {
+            cmds = append(cmds, []interface{}{"SADD", ik, k.id})
+        }
+
+        for _, o := range prep.ranges {
+            if o.value == nil {
+                cmds = append(cmds, []interface{}{"ZREM", k.Range(o.name), k.id})
+            } else {
+                cmds = append(cmds, []interface{}{"ZADD", k.Range(o.name), o.score, k.id})
+            }
+        }
+
+        for _, o := range prep.owned {
+            cmds = append(cmds, writeOwned(k, o)...)
+        }
+
+        return cmds, nil
+    })
+
+    if e != nil {
+        return nil, e
     }
 
-    return k, e
-Error:
-    cleanup(db, prep)
-    return nil, e
+    return k, nil
 }
 
 func Get(db *redis.Client, k *Key, s interface{}) error {
     mon := monitoring.BeginMeasuring("database:get")
+
+    if e := k.check(); e != nil {
+        return e
+    }
+
     reply, e := db.Call("HGETALL", k.String())
 
     if e != nil {
@@ -133,43 +288,151 @@ func Get(db *redis.Client, k *Key, s interface{}) error {
         return nilError
     }
 
-    e = inflate(db, s, reply.Hash())
+    e = inflate(db, k, s, reply.Hash())
     mon.EndMeasuring()
     return e
 }
 
-func cleanup(db *redis.Client, prep *prepare) error {
-    p := db.AsyncClient()
+// Delete removes the hash of k together with the unique keys, index and
+// range entries derived from its stored values and its owned keys, in a
+// single transaction. s is a pointer to a struct of the kind of k, its tags
+// name the unique, index, range and owned fields. A unique key is only
+// removed while it still refers to k.
+func Delete(db *redis.Client, k *Key, s interface{}) error {
+    mon := monitoring.BeginMeasuring("database:delete")
+    defer mon.EndMeasuring()
 
-    for _, k := range prep.dirty {
-        p.Call("DEL", k)
+    if e := k.check(); e != nil {
+        return e
     }
 
-    if prep.isNew {
-        p.Call("DEL", prep.key.String())
+    prep := new(prepare)
+    prep.key = k
+
+    if e := parseStruct(db, s, prep); e != nil {
+        return e
     }
 
-    _, e := p.ReadAll()
-    return e
+    return transaction(db, func(ac *redis.AsyncClient) ([][]interface{}, error) {
+        if _, e := call(ac, "WATCH", k.String()); e != nil {
+            return nil, e
+        }
+
+        reply, e := call(ac, "HGETALL", k.String())
+
+        if e != nil {
+            return nil, e
+        }
+
+        if reply.Len() == 0 {
+            return nil, nilError
+        }
+
+        keys, index := derivedKeys(k, prep, reply.Hash())
+        convert, e := convertIndexes(ac, index)
+
+        if e != nil {
+            return nil, e
+        }
+
+        cmds := append([][]interface{}{{"DEL", k.String()}}, convert...)
+
+        for _, o := range prep.owned {
+            cmds[0] = append(cmds[0], k.Owned(o.name))
+        }
+
+        for _, ik := range index {
+            cmds = append(cmds, []interface{}{"SREM", ik, k.id})
+        }
+
+        for _, o := range prep.ranges {
+            cmds = append(cmds, []interface{}{"ZREM", k.Range(o.name), k.id})
+        }
+
+        if len(keys) == 0 {
+            return cmds, nil
+        }
+
+        if _, e = call(ac, append([]interface{}{"WATCH"}, keys...)...); e != nil {
+            return nil, e
+        }
+
+        reply, e = call(ac, append([]interface{}{"MGET"}, keys...)...)
+
+        if e != nil {
+            return nil, e
+        }
+
+        for i, r := range reply.Elems {
+            if !r.Nil() && r.Elem.String() == k.id {
+                cmds[0] = append(cmds[0], keys[i])
+            }
+        }
+
+        return cmds, nil
+    })
 }
 
-func setId(db *redis.Client, s interface{}, prep *prepare) error {
-    if prep.key.Id() != 0 {
-        return nil
+// derivedKeys returns the unique and the index keys of the values in hash.
+func derivedKeys(k *Key, prep *prepare, hash map[string]redis.Elem) (keys, index []interface{}) {
+    for _, o := range prep.unique {
+        if v, ok := hash[o.name]; ok {
+            keys = append(keys, k.Unique(o.name, v.String()))
+        }
     }
 
-    prep.isNew = true
-    reply, e := db.Call("INCR", prep.key.Count())
+    for _, o := range prep.index {
+        if v, ok := hash[o.name]; ok {
+            index = append(index, k.Index(o.name, v.String()))
+        }
+    }
 
-    if e != nil {
-        return e
+    return keys, index
+}
+
+// convertIndexes WATCHes the index keys and returns the commands converting
+// those stored as a string holding a single id to a set of the id.
+func convertIndexes(ac *redis.AsyncClient, keys []interface{}) ([][]interface{}, error) {
+    if len(keys) == 0 {
+        return nil, nil
     }
 
-    prep.key.id = reply.Elem.Int64()
+    if _, e := call(ac, append([]interface{}{"WATCH"}, keys...)...); e != nil {
+        return nil, e
+    }
 
-    if e != nil {
-        return e
+    var cmds [][]interface{}
+
+    for _, ik := range keys {
+        reply, e := call(ac, "TYPE", ik)
+
+        if e != nil {
+            return nil, e
+        }
+
+        if reply.Elem.String() != "string" {
+            continue
+        }
+
+        reply, e = call(ac, "GET", ik)
+
+        if e != nil {
+            return nil, e
+        }
+
+        cmds = append(cmds, []interface{}{"DEL", ik}, []interface{}{"SADD", ik, reply.Elem.String()})
+    }
+
+    return cmds, nil
+}
+
+// setId assigns a new id to a key without id and sets the id field of s,
+// the field tagged with the id option or else named Id.
+func setId(db *redis.Client, s interface{}, prep *prepare) error {
+    if prep.key.id != "" {
+        return nil
     }
+
     v := reflect.ValueOf(s)
 
     if v.Kind() != reflect.Ptr {
@@ -182,28 +445,130 @@ func setId(db *redis.Client, s interface{}, prep *prepare) error {
         return typeError
     }
 
-    idField := v.FieldByName("Id")
+    c, e := codecOf(v.Type())
+
+    if e != nil {
+        return e
+    }
 
-    if !idField.IsValid() || !idField.CanSet() || idField.Kind() != reflect.Int64 {
+    if c.id == nil {
         return idFieldError
     }
 
-    idField.SetInt(prep.key.Id())
+    // the id field must hold the ids of the generator before one is taken
+    gen := generatorOf(prep.key.kind)
+
+    switch c.id.typ.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        if !gen.integer {
+            return idFieldError
+        }
+    case reflect.String:
+    default:
+        return idFieldError
+    }
+
+    id, e := gen.gen(db, prep.key.kind)
+
+    if e != nil {
+        return e
+    }
+
+    // an integer id may still overflow a small id field
+    if e = decodeValue(c.id.name, c.id.alloc(v), id); e != nil {
+        return idFieldError
+    }
+
+    prep.isNew = true
+    prep.key.id = id
     return nil
 }
 
 type hashField struct {
-    name  string
-    value *interface{}
+    name string
+
+    // value is nil for an absent field
+    value *string
+
+    // score in the range index of the field
+    score float64
 }
 
 type prepare struct {
     key    *Key
     unique []*hashField
     index  []*hashField
+    ranges []*hashField
+    owned  []*ownedField
     args   []interface{}
-    dirty  []string
+    absent []string
     isNew  bool
+    masked bool
+}
+
+// mask keeps the fields of prep which are named in fields.
+func (prep *prepare) mask(fields []string) error {
+    keep := make(map[string]bool, len(fields))
+
+    for _, name := range fields {
+        keep[name] = false
+    }
+
+    args := make([]interface{}, 0, len(fields)*2)
+
+    for i := 0; i < len(prep.args); i += 2 {
+        name := prep.args[i].(string)
+
+        if _, ok := keep[name]; ok {
+            keep[name] = true
+            args = append(args, prep.args[i], prep.args[i+1])
+        }
+    }
+
+    owned := make([]*ownedField, 0, len(prep.owned))
+
+    for _, o := range prep.owned {
+        if _, ok := keep[o.name]; ok {
+            keep[o.name] = true
+            owned = append(owned, o)
+        }
+    }
+
+    absent := make([]string, 0, len(prep.absent))
+
+    for _, name := range prep.absent {
+        if _, ok := keep[name]; ok {
+            keep[name] = true
+            absent = append(absent, name)
+        }
+    }
+
+    for _, name := range fields {
+        if !keep[name] {
+            return newFieldError(name)
+        }
+    }
+
+    filter := func(in []*hashField) []*hashField {
+        out := make([]*hashField, 0, len(in))
+
+        for _, o := range in {
+            if keep[o.name] {
+                out = append(out, o)
+            }
+        }
+
+        return out
+    }
+
+    prep.args = args
+    prep.absent = absent
+    prep.owned = owned
+    prep.unique = filter(prep.unique)
+    prep.index = filter(prep.index)
+    prep.ranges = filter(prep.ranges)
+    prep.masked = true
+    return nil
 }
 
 // parseStruct takes a pointer to a struct or a struct.
@@ -221,58 +586,78 @@ func parseStruct(db *redis.Client, s interface{}, prep *prepare) error {
         return typeError
     }
 
-    t := v.Type()
-    n := v.NumField()
-    args := make([]interface{}, 0, n*2)
-    argsLen := cap(args)
+    c, e := codecOf(v.Type())
+
+    if e != nil {
+        return e
+    }
+
+    prep.args = make([]interface{}, 0, len(c.fields)*2)
     prep.unique = make([]*hashField, 0)
     prep.index = make([]*hashField, 0)
 
-    for i := 0; i < n; i++ {
-        fieldType := t.Field(i)
+    for _, f := range c.fields {
+        var value string
+        fv, ok := f.value(v)
+
+        if f.owned != "" {
+            o := &ownedField{field: f}
 
-        if len(fieldType.PkgPath) > 0 || fieldType.Anonymous {
-            fmt.Printf("unexported field `%s`\n", fieldType.Name)
-            argsLen -= 2
+            if ok {
+                if o.args, e = f.encodeOwned(fv); e != nil {
+                    return e
+                }
+            }
+
+            prep.owned = append(prep.owned, o)
             continue
         }
 
-        name, opt := parseTag(fieldType.Tag.Get("redis"))
-
-        if name == "" {
-            name = fieldType.Name
+        if ok {
+            if value, ok, e = f.encode(fv); e != nil {
+                return e
+            }
         }
 
-        fieldValue := v.Field(i).Interface()
-        args = append(args, name, fieldValue)
+        // nil pointers, fields of nil embedded structs and empty omitempty
+        // fields are absent, their derived keys are released
+        ok = ok && !(f.omitempty && fv.IsZero())
+        hf := &hashField{name: f.name}
 
-        unique := opt.Contains("unique")
-        index := opt.Contains("index")
+        if ok {
+            hf.value = &value
+            prep.args = append(prep.args, f.name, value)
+        } else {
+            prep.absent = append(prep.absent, f.name)
+        }
 
-        if index || unique {
-            hf := &hashField{
-                name:  name,
-                value: &fieldValue,
+        if f.ranged {
+            if ok {
+                hf.score, _ = rangeScore(reflect.Indirect(fv))
             }
 
-            if index {
-                prep.index = append(prep.index, hf)
-            }
+            prep.ranges = append(prep.ranges, hf)
+        }
 
-            if unique {
-                prep.unique = append(prep.unique, hf)
-            }
+        if f.indexed {
+            prep.index = append(prep.index, hf)
+        }
+
+        if f.unique {
+            prep.unique = append(prep.unique, hf)
         }
     }
 
-    prep.args = args[:argsLen]
     return nil
 }
 
 // inflate takes a pointer to a struct as dst and a map with 
 // values as src. The struct is then filled with the values 
-// from the map. 
-func inflate(db *redis.Client, dst interface{}, src map[string]redis.Elem) error {
+// from the map. Pointer and omitempty fields absent from src are set to
+// their zero value, other fields are left unchanged. A pointer to an
+// embedded or nested struct is nil unless one of its fields is stored.
+// Owned keys are read from the entity k.
+func inflate(db *redis.Client, k *Key, dst interface{}, src map[string]redis.Elem) error {
     v := reflect.ValueOf(dst)
 
     if v.Kind() != reflect.Ptr {
@@ -285,43 +670,65 @@ func inflate(db *redis.Client, dst interface{}, src map[string]redis.Elem) error
         return typeError
     }
 
-    t := v.Type()
+    c, e := codecOf(v.Type())
 
-    for i := 0; i < v.NumField(); i++ {
-        fieldValue := v.Field(i)
-        fieldType := t.Field(i)
+    if e != nil {
+        return e
+    }
 
-        if !fieldValue.CanSet() {
-            fmt.Println("Field not setable", fieldType.Name)
-            continue
+    // pointers to structs are allocated again if one of their fields is
+    // stored
+    for _, index := range c.ptrs {
+        if pv, ok := walk(v, index, false); ok {
+            pv.Set(reflect.Zero(pv.Type()))
         }
+    }
 
-        name, _ := parseTag(fieldType.Tag.Get("json"))
+    var owned []*field
 
-        if name == "" {
-            name = fieldType.Name
+    for _, f := range c.fields {
+        if f.owned != "" {
+            owned = append(owned, f)
+            continue
         }
 
-        value, ok := src[name]
+        value, ok := src[f.name]
 
         if !ok {
-            fmt.Println("Value for field not in map:", name)
+            if f.typ.Kind() == reflect.Ptr || f.omitempty {
+                if fv, ok := f.value(v); ok {
+                    fv.Set(reflect.Zero(f.typ))
+                }
+            }
+
             continue
         }
 
-        switch fieldValue.Kind() {
-        case reflect.Int:
-            fieldValue.SetInt(int64(value.Int()))
-        case reflect.Int64:
-            fieldValue.SetInt(value.Int64())
-        case reflect.Float64:
-            fieldValue.SetFloat(value.Float64())
-        case reflect.Bool:
-            fieldValue.SetBool(value.Bool())
-        case reflect.String:
-            fieldValue.SetString(value.String())
-        default:
-            panic("type not supported: " + fieldValue.Type().String())
+        if e = f.decode(f.alloc(v), value.String()); e != nil {
+            return e
+        }
+    }
+
+    if len(owned) == 0 {
+        return nil
+    }
+
+    ac := db.AsyncClient()
+    defer ac.Close()
+
+    for _, f := range owned {
+        readOwned(ac, k, f)
+    }
+
+    replies, e := ac.ReadAll()
+
+    if e != nil {
+        return e
+    }
+
+    for i, f := range owned {
+        if e = f.decodeOwned(f.alloc(v), replies[i]); e != nil {
+            return e
         }
     }
 
@@ -1,16 +1,61 @@
 package schema
 
 import (
+    "fmt"
+    "reflect"
+    "sync"
     "testing"
 
     "insmo.com/godis/exp"
+    "insmo.com/godis/redistest"
 )
 
 var db *redis.Client
 
 func init() {
     redis.MaxConnections = 1
-    db = redis.NewClient("tcp:localhost:6379", 9, "")
+    db = redis.NewClient(redistest.NewServer().NetAddr(), 9, "")
+}
+
+// putAll deletes the keys of kind and puts entities, pointers to structs
+// with their id set, as entities of kind. Other kinds are left alone.
+func putAll(t *testing.T, kind string, entities ...interface{}) {
+    reply, e := db.Call("KEYS", kind+":*")
+
+    if e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if keys := reply.StringArray(); len(keys) > 0 {
+        args := make([]interface{}, len(keys))
+
+        for i, k := range keys {
+            args[i] = k
+        }
+
+        if _, e = db.Call(append([]interface{}{"DEL"}, args...)...); e != nil {
+            t.Fatal(e.Error())
+        }
+    }
+
+    for _, s := range entities {
+        v := reflect.ValueOf(s).Elem()
+        c, e := codecOf(v.Type())
+
+        if e != nil {
+            t.Fatal(e.Error())
+        }
+
+        id, _, e := encodeValue(c.id.name, c.id.alloc(v))
+
+        if e != nil {
+            t.Fatal(e.Error())
+        }
+
+        if _, e = Put(db, NewStringKey(kind, id), s); e != nil {
+            t.Fatal(e.Error())
+        }
+    }
 }
 
 type S1 struct {
@@ -31,7 +76,7 @@ type putTest struct {
 
 var putTests = [][]putTest{
     {{&S1{1}, NewKey("s1", 1), nil}},
-    {{S1{1}, NewKey("s1", 1), TypeError}},
+    {{S1{1}, NewKey("s1", 1), typeError}},
     {{&S1{1}, NewKey("s1", 1), nil}, {&S1{2}, NewKey("s1", 2), nil}},
     {{&S1{1}, NewKey("s1", 1), nil}, {&S1{1}, NewKey("s1", 1), nil}},
     {{&User{1, "foo", "foo@foo.com"}, NewKey("user", 1), nil}},
@@ -78,3 +123,167 @@ func TestPut(t *testing.T) {
         }
     }
 }
+
+func TestDelete(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("user", 0)
+
+    if _, e := Put(db, k, &User{0, "foo", "foo@foo.com"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    // a unique key taken over by another entity is left alone
+    db.Call("SET", k.Unique("email", "foo@foo.com"), 2)
+
+    if e := Delete(db, k, &User{}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    for _, key := range []string{k.String(), k.Unique("username", "foo"), k.Index("username", "foo")} {
+        if r, _ := db.Call("EXISTS", key); r.Elem.Int() != 0 {
+            t.Errorf("expected `%s` to be deleted", key)
+        }
+    }
+
+    if r, _ := db.Call("GET", k.Unique("email", "foo@foo.com")); r.Elem.Int64() != 2 {
+        t.Errorf("expected unique email of entity 2 to be kept")
+    }
+
+    if e := Delete(db, k, &User{}); e != nilError {
+        t.Errorf("expected nilError got %v", e)
+    }
+
+    if _, e := Put(db, NewKey("user", 0), &User{0, "foo", "bar@foo.com"}); e != nil {
+        t.Errorf("expected username to be free again got %v", e)
+    }
+}
+
+func TestPutConcurrent(t *testing.T) {
+    db.Call("FLUSHDB")
+    var wg sync.WaitGroup
+    errs := make(chan error, 10)
+
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+
+        go func(i int) {
+            defer wg.Done()
+            email := fmt.Sprintf("foo%d@foo.com", i)
+            _, e := Put(db, NewKey("user", 0), &User{0, "foo", email})
+            errs <- e
+        }(i)
+    }
+
+    wg.Wait()
+    close(errs)
+    n := 0
+
+    for e := range errs {
+        if e == nil {
+            n++
+        } else if e != newUniqueError("username", "foo") {
+            t.Errorf("expected unique error got %v", e)
+        }
+    }
+
+    if n != 1 {
+        t.Errorf("expected exactly one Put to succeed got %d", n)
+    }
+
+    // the losing Puts did not leave any keys behind
+    if r, _ := db.Call("KEYS", "user:*"); r.Len() != 5 {
+        t.Errorf("expected count, hash, two unique and one index key got %q", r.StringArray())
+    }
+}
+
+func TestPutKeyType(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("user", 1)
+    db.Call("RPUSH", k.Index("username", "foo"), "x")
+
+    if _, e := Put(db, k, &User{1, "foo", "foo@foo.com"}); e == nil || !IsInternalError(e) {
+        t.Fatalf("expected a key type error got %v", e)
+    }
+
+    // nothing was written
+    if r, _ := db.Call("KEYS", "user:*"); r.Len() != 1 {
+        t.Errorf("expected only the index key got %q", r.StringArray())
+    }
+}
+
+func TestPutUpdate(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("user", 1)
+
+    if _, e := Put(db, k, &User{1, "foo", "foo@foo.com"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if _, e := Put(db, k, &User{1, "bar", "foo@foo.com"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    for _, key := range []string{k.Unique("username", "foo"), k.Index("username", "foo")} {
+        if r, _ := db.Call("EXISTS", key); r.Elem.Int() != 0 {
+            t.Errorf("expected `%s` to be released", key)
+        }
+    }
+
+    for _, key := range []string{k.Unique("username", "bar"), k.Unique("email", "foo@foo.com")} {
+        if r, _ := db.Call("GET", key); r.Elem.Int64() != 1 {
+            t.Errorf("expected `%s` to refer to 1", key)
+        }
+    }
+
+    if r, _ := db.Call("SMEMBERS", k.Index("username", "bar")); len(r.Elems) != 1 || r.Elems[0].Elem.Int64() != 1 {
+        t.Errorf("expected index bar to contain 1 got %q", r.StringArray())
+    }
+
+    if _, e := Put(db, NewKey("user", 2), &User{2, "foo", "bar@foo.com"}); e != nil {
+        t.Errorf("expected username foo to be free got %v", e)
+    }
+
+    if _, e := Put(db, k, &User{1, "foo", "foo@foo.com"}); e != newUniqueError("username", "foo") {
+        t.Errorf("expected unique error got %v", e)
+    }
+
+    if r, _ := db.Call("HGET", k.String(), "username"); r.Elem.String() != "bar" {
+        t.Errorf("expected failed update to keep bar got %q", r.Elem.String())
+    }
+}
+
+func TestPutMask(t *testing.T) {
+    db.Call("FLUSHDB")
+    k := NewKey("user", 1)
+
+    if _, e := Put(db, k, &User{1, "foo", "foo@foo.com"}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if _, e := Put(db, k, &User{1, "", "new@foo.com"}, "email"); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    r, _ := db.Call("HGETALL", k.String())
+    h := r.StringMap()
+
+    if h["username"] != "foo" || h["email"] != "new@foo.com" {
+        t.Errorf("expected a partial update got %v", h)
+    }
+
+    if r, _ := db.Call("EXISTS", k.Unique("email", "foo@foo.com")); r.Elem.Int() != 0 {
+        t.Errorf("expected old email to be released")
+    }
+
+    if r, _ := db.Call("GET", k.Unique("username", "foo")); r.Elem.Int64() != 1 {
+        t.Errorf("expected username to stay claimed")
+    }
+
+    if _, e := Put(db, k, &User{}, "nickname"); e != newFieldError("nickname") {
+        t.Errorf("expected field error got %v", e)
+    }
+
+    if _, e := Put(db, NewKey("user", 9), &User{9, "baz", ""}, "username"); e != nilError {
+        t.Errorf("expected nilError got %v", e)
+    }
+}
@@ -0,0 +1,144 @@
+package schema
+
+import (
+    "fmt"
+
+    "insmo.com/godis/exp"
+)
+
+// MaxRetries is how often a transaction is retried when one of its
+// watched keys was modified by another client before EXEC.
+var MaxRetries = 16
+
+var conflictError = InternalError("Transaction aborted, watched keys kept changing")
+
+// call sends a single command on the connection of ac and reads its reply.
+func call(ac *redis.AsyncClient, args ...interface{}) (*redis.Reply, error) {
+    if e := ac.Call(args...); e != nil {
+        return nil, e
+    }
+
+    return ac.Read()
+}
+
+// keyTypes are the types of the keys written by the commands of a
+// transaction, DEL and SET write keys of any type.
+var keyTypes = map[string]string{
+    "HMSET": "hash",
+    "HDEL":  "hash",
+    "SADD":  "set",
+    "SREM":  "set",
+    "ZADD":  "zset",
+    "ZREM":  "zset",
+    "RPUSH": "list",
+}
+
+func newKeyTypeError(key interface{}, typ, expected string) InternalError {
+    return InternalError(fmt.Sprintf("Key `%v` holds a %s, expected a %s", key, typ, expected))
+}
+
+// checkTypes WATCHes the keys written by cmds and returns an error if one
+// holds a value of another type than its commands expect. Keys deleted by
+// an earlier command are not checked.
+func checkTypes(ac *redis.AsyncClient, cmds [][]interface{}) error {
+    deleted := make(map[interface{}]bool)
+    var keys []interface{}
+    var types []string
+
+    for _, cmd := range cmds {
+        name := cmd[0].(string)
+
+        if name == "DEL" {
+            for _, key := range cmd[1:] {
+                deleted[key] = true
+            }
+
+            continue
+        }
+
+        if typ, ok := keyTypes[name]; ok && !deleted[cmd[1]] {
+            keys = append(keys, cmd[1])
+            types = append(types, typ)
+        }
+    }
+
+    if len(keys) == 0 {
+        return nil
+    }
+
+    ac.Call(append([]interface{}{"WATCH"}, keys...)...)
+
+    for _, key := range keys {
+        ac.Call("TYPE", key)
+    }
+
+    replies, e := ac.ReadAll()
+
+    if e != nil {
+        return e
+    }
+
+    for i, r := range replies[1:] {
+        if typ := r.Elem.String(); typ != "none" && typ != types[i] {
+            return newKeyTypeError(keys[i], typ, types[i])
+        }
+    }
+
+    return nil
+}
+
+// transaction runs an optimistic transaction on a dedicated connection. fn
+// WATCHes and reads the keys it depends on, and returns the commands to run
+// between MULTI and EXEC. The transaction is retried with a new call to fn
+// as long as EXEC is aborted because a watched key changed. An error
+// returned by fn ends the transaction without writing anything.
+//
+// Redis does not roll back the other commands of an EXEC if one of them
+// fails. The keys written by the commands are WATCHed and their types are
+// checked before MULTI, so a key of the wrong type ends the transaction
+// without writing anything as well. A command may still fail inside EXEC
+// for other reasons, e.g. when Redis runs out of memory, the commands before
+// and after it are applied and transaction returns its error.
+func transaction(db *redis.Client, fn func(ac *redis.AsyncClient) ([][]interface{}, error)) error {
+    ac := db.AsyncClient()
+    defer ac.Close()
+
+    for i := 0; i < MaxRetries; i++ {
+        cmds, e := fn(ac)
+
+        if e == nil {
+            e = checkTypes(ac, cmds)
+        }
+
+        if e != nil {
+            return e
+        }
+
+        ac.Call("MULTI")
+
+        for _, cmd := range cmds {
+            ac.Call(cmd...)
+        }
+
+        ac.Call("EXEC")
+        replies, e := ac.ReadAll()
+
+        if e == redis.ErrNilMultiBulk {
+            continue
+        }
+
+        if e != nil {
+            return e
+        }
+
+        for _, r := range replies[len(replies)-1].Elems {
+            if r.Err != nil {
+                return r.Err
+            }
+        }
+
+        return nil
+    }
+
+    return conflictError
+}
@@ -0,0 +1,225 @@
+package schema
+
+import (
+    "encoding"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strconv"
+    "time"
+)
+
+var (
+    durationType        = reflect.TypeOf(time.Duration(0))
+    textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+    textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func newValueTypeError(field string, t reflect.Type) InternalError {
+    return InternalError(fmt.Sprintf("Unsupported type `%s` of field `%s`", t, field))
+}
+
+func newValueError(field, value string) InternalError {
+    return InternalError(fmt.Sprintf("Invalid value `%s` for field `%s`", value, field))
+}
+
+// isText returns whether values of t are stored as their text form, a
+// pointer to t implements both encoding.TextMarshaler and
+// encoding.TextUnmarshaler.
+func isText(t reflect.Type) bool {
+    p := reflect.PtrTo(t)
+    return p.Implements(textMarshalerType) && p.Implements(textUnmarshalerType)
+}
+
+// hasText returns whether a pointer to t implements one of
+// encoding.TextMarshaler and encoding.TextUnmarshaler.
+func hasText(t reflect.Type) bool {
+    p := reflect.PtrTo(t)
+    return p.Implements(textMarshalerType) || p.Implements(textUnmarshalerType)
+}
+
+// elemType returns the type a pointer type t points to, or t.
+func elemType(t reflect.Type) reflect.Type {
+    if t.Kind() == reflect.Ptr {
+        return t.Elem()
+    }
+
+    return t
+}
+
+// checkType returns an error if values of t can not be stored. A type
+// implementing only one of the text interfaces can not be stored.
+func checkType(field string, t reflect.Type) error {
+    t = elemType(t)
+
+    if t == timeType || isText(t) {
+        return nil
+    }
+
+    if hasText(t) {
+        return newValueTypeError(field, t)
+    }
+
+    switch t.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+        reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+        return nil
+    case reflect.Slice:
+        if t.Elem().Kind() == reflect.Uint8 {
+            return nil
+        }
+    }
+
+    return newValueTypeError(field, t)
+}
+
+// encodeValue returns the hash value of v. A nil pointer has no value, ok
+// is false. Times are stored as RFC 3339 and durations as nanoseconds, types
+// implementing encoding.TextMarshaler and encoding.TextUnmarshaler as their
+// text.
+func encodeValue(field string, v reflect.Value) (value string, ok bool, e error) {
+    if v.Kind() == reflect.Ptr {
+        if v.IsNil() {
+            return "", false, nil
+        }
+
+        v = v.Elem()
+    }
+
+    t := v.Type()
+
+    switch {
+    case t == timeType:
+        return v.Interface().(time.Time).Format(time.RFC3339Nano), true, nil
+    case t == durationType:
+        return strconv.FormatInt(v.Int(), 10), true, nil
+    case isText(t):
+        if !v.CanAddr() {
+            c := reflect.New(t).Elem()
+            c.Set(v)
+            v = c
+        }
+
+        b, e := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+        return string(b), e == nil, e
+    }
+
+    switch v.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return strconv.FormatInt(v.Int(), 10), true, nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return strconv.FormatUint(v.Uint(), 10), true, nil
+    case reflect.Float32, reflect.Float64:
+        return strconv.FormatFloat(v.Float(), 'g', -1, t.Bits()), true, nil
+    case reflect.Bool:
+        return strconv.FormatBool(v.Bool()), true, nil
+    case reflect.String:
+        return v.String(), true, nil
+    case reflect.Slice:
+        if t.Elem().Kind() == reflect.Uint8 {
+            return string(v.Bytes()), true, nil
+        }
+    }
+
+    return "", false, newValueTypeError(field, t)
+}
+
+// encodeLookup returns the stored form of value, a value of an indexed or
+// unique field looked up by Find or a Query. It is encoded as by Put, maps
+// and slices other than []byte as JSON.
+func encodeLookup(field string, value interface{}) (string, error) {
+    v := reflect.ValueOf(value)
+
+    if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+        return "", newValueError(field, "nil")
+    }
+
+    if t := elemType(v.Type()); t.Kind() == reflect.Map || t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+        b, e := json.Marshal(value)
+
+        if e != nil {
+            return "", newValueError(field, e.Error())
+        }
+
+        return string(b), nil
+    }
+
+    s, _, e := encodeValue(field, v)
+    return s, e
+}
+
+// decodeValue sets v to the value decoded from the hash value s, a nil
+// pointer is allocated.
+func decodeValue(field string, v reflect.Value, s string) error {
+    if v.Kind() == reflect.Ptr {
+        if v.IsNil() {
+            v.Set(reflect.New(v.Type().Elem()))
+        }
+
+        v = v.Elem()
+    }
+
+    t := v.Type()
+
+    switch {
+    case t == timeType:
+        tm, e := time.Parse(time.RFC3339Nano, s)
+
+        if e != nil {
+            return newValueError(field, s)
+        }
+
+        v.Set(reflect.ValueOf(tm))
+        return nil
+    case isText(t):
+        return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+    }
+
+    switch v.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, e := strconv.ParseInt(s, 10, t.Bits())
+
+        if e != nil {
+            return newValueError(field, s)
+        }
+
+        v.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        n, e := strconv.ParseUint(s, 10, t.Bits())
+
+        if e != nil {
+            return newValueError(field, s)
+        }
+
+        v.SetUint(n)
+    case reflect.Float32, reflect.Float64:
+        f, e := strconv.ParseFloat(s, t.Bits())
+
+        if e != nil {
+            return newValueError(field, s)
+        }
+
+        v.SetFloat(f)
+    case reflect.Bool:
+        b, e := strconv.ParseBool(s)
+
+        if e != nil {
+            return newValueError(field, s)
+        }
+
+        v.SetBool(b)
+    case reflect.String:
+        v.SetString(s)
+    case reflect.Slice:
+        if t.Elem().Kind() != reflect.Uint8 {
+            return newValueTypeError(field, t)
+        }
+
+        v.SetBytes([]byte(s))
+    default:
+        return newValueTypeError(field, t)
+    }
+
+    return nil
+}
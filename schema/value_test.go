@@ -0,0 +1,218 @@
+package schema
+
+import (
+    "net"
+    "reflect"
+    "testing"
+    "time"
+)
+
+type Base struct {
+    Id      int64
+    Created time.Time `redis:",range"`
+}
+
+type Meta struct {
+    Note string
+}
+
+type Typed struct {
+    Base
+    *Meta
+    I8       int8
+    I16      int16
+    I32      int32
+    U        uint
+    U8       uint8
+    U16      uint16
+    U32      uint32
+    U64      uint64
+    F32      float32
+    Flag     bool
+    Data     []byte
+    Timeout  time.Duration `redis:",range"`
+    Addr     net.IP
+    Nick     *string `redis:",index"`
+    Age      *int    `redis:",range"`
+    internal int
+}
+
+func TestValueTypes(t *testing.T) {
+    db.Call("FLUSHDB")
+    nick, age := "foo", 42
+    in := &Typed{
+        Base:    Base{1, epoch},
+        Meta:    &Meta{"note"},
+        I8:      -8,
+        I16:     -16,
+        I32:     -32,
+        U:       1,
+        U8:      8,
+        U16:     16,
+        U32:     32,
+        U64:     1 << 63,
+        F32:     0.25,
+        Flag:    true,
+        Data:    []byte{0, 1, 2, 255},
+        Timeout: 90 * time.Second,
+        Addr:    net.ParseIP("10.0.0.1"),
+        Nick:    &nick,
+        Age:     &age,
+    }
+    k := NewKey("typed", 1)
+
+    if _, e := Put(db, k, in); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    r, _ := db.Call("HGETALL", k.String())
+    h := r.StringMap()
+
+    if h["Id"] != "1" || h["Note"] != "note" || h["Timeout"] != "90000000000" || h["Addr"] != "10.0.0.1" {
+        t.Errorf("unexpected hash %q", h)
+    }
+
+    var out Typed
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if !out.Created.Equal(epoch) {
+        t.Errorf("expected %v got %v", epoch, out.Created)
+    }
+
+    out.Created = in.Created
+
+    if !reflect.DeepEqual(in, &out) {
+        t.Errorf("expected %+v got %+v", in, out)
+    }
+
+    if keys, _ := NewRangeQuery("typed", "Timeout").GreaterThan(time.Minute).Keys(db); len(keys) != 1 {
+        t.Errorf("expected a range on the duration got %v", keys)
+    }
+
+    if keys, _ := NewRangeQuery("typed", "Created").Between(epoch, epoch).Keys(db); len(keys) != 1 {
+        t.Errorf("expected a range on the embedded time got %v", keys)
+    }
+}
+
+func TestValueAbsent(t *testing.T) {
+    db.Call("FLUSHDB")
+    nick, age := "foo", 42
+    k := NewKey("typed", 1)
+
+    if _, e := Put(db, k, &Typed{Base: Base{Id: 1}, Meta: &Meta{"note"}, Nick: &nick, Age: &age}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    // nil pointers remove the fields and their index and range entries,
+    // a nil embedded struct all of its fields
+    if _, e := Put(db, k, &Typed{Base: Base{Id: 1}}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    for _, f := range []string{"Nick", "Age", "Note"} {
+        if r, _ := db.Call("HEXISTS", k.String(), f); r.Elem.Int() != 0 {
+            t.Errorf("expected field %s to be absent", f)
+        }
+    }
+
+    if r, _ := db.Call("EXISTS", k.Index("Nick", "foo")); r.Elem.Int() != 0 {
+        t.Errorf("expected index of Nick to be released")
+    }
+
+    if r, _ := db.Call("ZCARD", k.Range("Age")); r.Elem.Int() != 0 {
+        t.Errorf("expected range of Age to be removed")
+    }
+
+    out := Typed{Nick: &nick}
+
+    if e := Get(db, k, &out); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    if out.Nick != nil || out.Age != nil || out.Meta != nil {
+        t.Errorf("expected absent fields got %+v", out)
+    }
+}
+
+func TestValueErrors(t *testing.T) {
+    db.Call("FLUSHDB")
+
+    type queue struct {
+        Id int64
+        C  chan int
+    }
+
+    if _, e := Put(db, NewKey("queue", 1), &queue{1, nil}); e != newValueTypeError("C", reflect.TypeOf(make(chan int))) {
+        t.Errorf("expected a value type error got %v", e)
+    }
+
+    type partial struct {
+        Id    int64
+        Level onlyUnmarshal
+    }
+
+    if _, e := Put(db, NewKey("partial", 1), &partial{Id: 1}); e != newValueTypeError("Level", reflect.TypeOf(onlyUnmarshal{})) {
+        t.Errorf("expected a value type error got %v", e)
+    }
+
+    k := NewKey("typed", 1)
+    db.Call("HMSET", k.String(), "Id", 1, "I8", 300)
+
+    if e := Get(db, k, &Typed{}); e != newValueError("I8", "300") {
+        t.Errorf("expected a value error got %v", e)
+    }
+
+    db.Call("HMSET", k.String(), "I8", 1, "Addr", "x")
+
+    if e := Get(db, k, &Typed{}); e == nil {
+        t.Errorf("expected an error from UnmarshalText")
+    }
+}
+
+// onlyUnmarshal implements encoding.TextUnmarshaler but not
+// encoding.TextMarshaler.
+type onlyUnmarshal struct {
+    n int
+}
+
+func (o *onlyUnmarshal) UnmarshalText(b []byte) error {
+    o.n = len(b)
+    return nil
+}
+
+// level implements both text interfaces with pointer receivers.
+type level struct {
+    name string
+}
+
+func (l *level) MarshalText() ([]byte, error) {
+    return []byte(l.name), nil
+}
+
+func (l *level) UnmarshalText(b []byte) error {
+    l.name = string(b)
+    return nil
+}
+
+func TestValueText(t *testing.T) {
+    db.Call("FLUSHDB")
+
+    type ticket struct {
+        Id    int64
+        Level level `redis:",index"`
+    }
+
+    if _, e := Put(db, NewKey("ticket", 1), &ticket{1, level{"high"}}); e != nil {
+        t.Fatal(e.Error())
+    }
+
+    var out ticket
+
+    // the lookup value is not addressable
+    if k, e := Find(db, "ticket", "Level", level{"high"}, &out); e != nil || k.Id() != 1 || out.Level.name != "high" {
+        t.Errorf("expected ticket 1 got %v, %+v, %v", k, out, e)
+    }
+}